@@ -0,0 +1,85 @@
+package agentlogger
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type RegisterTeamReq struct {
+	ID          string `json:"id,omitempty"` // generated by RegisterTeam if empty
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	CreatedBy   string `json:"created_by,omitempty"`
+	TeamName    string `json:"team_name,omitempty"`
+	Status      string `json:"status,omitempty"` // running, stopped, idle
+}
+
+type RegisterAgentReq struct {
+	ID        string `json:"id,omitempty"` // generated by RegisterAgent if empty
+	TeamID    string `json:"team_id"`
+	Role      string `json:"role,omitempty"` // lead, teammate
+	Name      string `json:"name"`
+	Specialty string `json:"specialty,omitempty"`
+	Status    string `json:"status,omitempty"`
+}
+
+type StartConversationReq struct {
+	ID        string     `json:"id,omitempty"` // generated by StartConversation if empty
+	TeamID    string     `json:"team_id"`
+	AgentID   string     `json:"agent_id"`
+	Title     string     `json:"title,omitempty"`
+	StartedAt *time.Time `json:"started_at,omitempty"`
+}
+
+// RegisterTeam creates or updates a team and returns its ID.
+func (l *Logger) RegisterTeam(req RegisterTeamReq) (string, error) {
+	if req.ID == "" {
+		req.ID = uuid.New().String()
+	}
+	if err := l.postJSON("/internal/teams", req.ID, req); err != nil {
+		return "", fmt.Errorf("failed to send register team request: %w", err)
+	}
+	return req.ID, nil
+}
+
+// UpdateTeamStatus sets a team's status (running, stopped or idle).
+func (l *Logger) UpdateTeamStatus(teamID, status string) error {
+	payload := map[string]string{"status": status}
+	if err := l.patchJSON("/internal/teams/"+teamID+"/status", uuid.New().String(), payload); err != nil {
+		return fmt.Errorf("failed to send update team status request: %w", err)
+	}
+	return nil
+}
+
+// RegisterAgent creates or updates an agent and returns its ID.
+func (l *Logger) RegisterAgent(req RegisterAgentReq) (string, error) {
+	if req.ID == "" {
+		req.ID = uuid.New().String()
+	}
+	if err := l.postJSON("/internal/agents", req.ID, req); err != nil {
+		return "", fmt.Errorf("failed to send register agent request: %w", err)
+	}
+	return req.ID, nil
+}
+
+// StartConversation creates a conversation and returns its ID.
+func (l *Logger) StartConversation(req StartConversationReq) (string, error) {
+	if req.ID == "" {
+		req.ID = uuid.New().String()
+	}
+	if err := l.postJSON("/internal/conversations", req.ID, req); err != nil {
+		return "", fmt.Errorf("failed to send start conversation request: %w", err)
+	}
+	return req.ID, nil
+}
+
+// EndConversation marks a conversation as ended now.
+func (l *Logger) EndConversation(conversationID string) error {
+	payload := map[string]interface{}{"ended_at": time.Now()}
+	if err := l.patchJSON("/internal/conversations/"+conversationID+"/end", uuid.New().String(), payload); err != nil {
+		return fmt.Errorf("failed to send end conversation request: %w", err)
+	}
+	return nil
+}
@@ -3,17 +3,23 @@ package agentlogger
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"time"
+
+	"github.com/google/uuid"
 )
 
 type Logger struct {
 	BaseURL string
 	Client  *http.Client
+	spool   *spool
 }
 
 type LogMessageReq struct {
+	ID             string          `json:"id,omitempty"` // generated by LogMessage if empty
 	ConversationID string          `json:"conversation_id"`
 	TeamID         string          `json:"team_id"`
 	AgentID        *string         `json:"agent_id,omitempty"`
@@ -23,6 +29,7 @@ type LogMessageReq struct {
 }
 
 type LogTraceReq struct {
+	ID             string          `json:"id,omitempty"` // generated by LogTrace if empty
 	TeamID         string          `json:"team_id"`
 	AgentID        string          `json:"agent_id"`
 	ConversationID string          `json:"conversation_id"`
@@ -31,10 +38,19 @@ type LogTraceReq struct {
 	Attributes     json.RawMessage `json:"attributes,omitempty"`
 	StartTime      *time.Time      `json:"start_time,omitempty"`
 	EndTime        *time.Time      `json:"end_time,omitempty"`
+	Status         StatusCode      `json:"status,omitempty"`
+	StatusMessage  string          `json:"status_message,omitempty"`
+	Events         []SpanEvent     `json:"events,omitempty"`
 }
 
-type traceResponse struct {
-	ID string `json:"id"`
+// EndSpanReq describes how a span finished. Attributes are merged into the
+// span's existing attributes and Events are appended to any already recorded.
+type EndSpanReq struct {
+	EndTime       *time.Time             `json:"end_time,omitempty"`
+	Attributes    map[string]interface{} `json:"attributes,omitempty"`
+	Status        StatusCode             `json:"status,omitempty"`
+	StatusMessage string                 `json:"status_message,omitempty"`
+	Events        []SpanEvent            `json:"events,omitempty"`
 }
 
 func NewLogger(baseURL string) *Logger {
@@ -46,129 +62,123 @@ func NewLogger(baseURL string) *Logger {
 	}
 }
 
+// LogMessage records a message. If req.ID is empty one is generated locally;
+// the ID doubles as the idempotency key, so retries never duplicate it.
 func (l *Logger) LogMessage(req LogMessageReq) error {
-	body, err := json.Marshal(req)
-	if err != nil {
-		return fmt.Errorf("failed to marshal message request: %w", err)
+	if req.ID == "" {
+		req.ID = uuid.New().String()
 	}
-
-	resp, err := l.Client.Post(
-		l.BaseURL+"/internal/log_message",
-		"application/json",
-		bytes.NewReader(body),
-	)
-	if err != nil {
+	if err := l.postJSON("/internal/log_message", req.ID, req); err != nil {
 		return fmt.Errorf("failed to send log message request: %w", err)
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusCreated {
-		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
-	}
-
 	return nil
 }
 
+// LogTrace records a span. If req.ID is empty one is generated locally;
+// the ID doubles as the idempotency key, so retries never duplicate it.
 func (l *Logger) LogTrace(req LogTraceReq) error {
-	body, err := json.Marshal(req)
-	if err != nil {
-		return fmt.Errorf("failed to marshal trace request: %w", err)
+	if req.ID == "" {
+		req.ID = uuid.New().String()
 	}
-
-	resp, err := l.Client.Post(
-		l.BaseURL+"/internal/log_trace",
-		"application/json",
-		bytes.NewReader(body),
-	)
-	if err != nil {
+	if err := l.postJSON("/internal/log_trace", req.ID, req); err != nil {
 		return fmt.Errorf("failed to send log trace request: %w", err)
 	}
-	defer resp.Body.Close()
+	return nil
+}
 
-	if resp.StatusCode != http.StatusCreated {
-		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+// EndSpan closes the span with the given ID. EndTime defaults to now.
+func (l *Logger) EndSpan(spanID string, req EndSpanReq) error {
+	if req.EndTime == nil {
+		now := time.Now()
+		req.EndTime = &now
+	}
+	if err := l.patchJSON("/internal/traces/"+spanID+"/end", uuid.New().String(), req); err != nil {
+		return fmt.Errorf("failed to send end span request: %w", err)
 	}
-
 	return nil
 }
 
-func (l *Logger) StartSpan(teamID, agentID, conversationID, spanName string, attributes map[string]interface{}) (string, error) {
-	var attrsJSON json.RawMessage
-	if attributes != nil {
-		var err error
-		attrsJSON, err = json.Marshal(attributes)
-		if err != nil {
-			return "", fmt.Errorf("failed to marshal attributes: %w", err)
-		}
-	}
+// postJSON POSTs payload to path with the given idempotency key.
+func (l *Logger) postJSON(path, key string, payload interface{}) error {
+	return l.send(http.MethodPost, path, key, payload)
+}
 
-	now := time.Now()
-	req := LogTraceReq{
-		TeamID:         teamID,
-		AgentID:        agentID,
-		ConversationID: conversationID,
-		SpanName:       spanName,
-		Attributes:     attrsJSON,
-		StartTime:      &now,
-	}
+// patchJSON PATCHes payload to path with the given idempotency key.
+func (l *Logger) patchJSON(path, key string, payload interface{}) error {
+	return l.send(http.MethodPatch, path, key, payload)
+}
 
-	body, err := json.Marshal(req)
-	if err != nil {
-		return "", fmt.Errorf("failed to marshal start span request: %w", err)
-	}
+// statusError is returned when the server answers with an unexpected status.
+type statusError struct {
+	code int
+}
 
-	resp, err := l.Client.Post(
-		l.BaseURL+"/internal/log_trace",
-		"application/json",
-		bytes.NewReader(body),
-	)
-	if err != nil {
-		return "", fmt.Errorf("failed to send start span request: %w", err)
-	}
-	defer resp.Body.Close()
+func (e *statusError) Error() string {
+	return fmt.Sprintf("unexpected status code: %d", e.code)
+}
 
-	if resp.StatusCode != http.StatusCreated {
-		return "", fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+// isRetryable reports whether a failed request may succeed if sent again
+// later: transport errors, server errors and rate limiting.
+func isRetryable(err error) bool {
+	var se *statusError
+	if errors.As(err, &se) {
+		return se.code >= 500 || se.code == http.StatusTooManyRequests
 	}
+	return err != nil
+}
 
-	var traceResp traceResponse
-	if err := json.NewDecoder(resp.Body).Decode(&traceResp); err != nil {
-		return "", fmt.Errorf("failed to decode response: %w", err)
+// send delivers a request, falling back to the spool (when enabled) if the
+// server can't be reached. Any 2xx response counts as delivered: the server
+// answers 200 instead of 201 when it recognises a retry.
+func (l *Logger) send(method, path, key string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	return traceResp.ID, nil
-}
+	rec := spoolRecord{
+		Key:       key,
+		Method:    method,
+		Path:      path,
+		Body:      body,
+		CreatedAt: time.Now(),
+	}
 
-func (l *Logger) EndSpan(spanID string) error {
-	now := time.Now()
-	payload := map[string]interface{}{
-		"end_time": now,
+	// Keep ordering: nothing may overtake records already in the spool.
+	if l.spool != nil && l.spool.pending() {
+		return l.spool.append(rec)
 	}
 
-	body, err := json.Marshal(payload)
-	if err != nil {
-		return fmt.Errorf("failed to marshal end span request: %w", err)
+	err = l.deliverRecord(rec)
+	if err != nil && l.spool != nil && isRetryable(err) {
+		return l.spool.append(rec)
 	}
+	return err
+}
 
-	req, err := http.NewRequest(
-		http.MethodPatch,
-		l.BaseURL+"/internal/traces/"+spanID+"/end",
-		bytes.NewReader(body),
-	)
+// do sends a single request. The idempotency key lets the server discard
+// duplicates when a request is retried from the spool.
+func (l *Logger) do(rec spoolRecord) (*http.Response, error) {
+	req, err := http.NewRequest(rec.Method, l.BaseURL+rec.Path, bytes.NewReader(rec.Body))
 	if err != nil {
-		return fmt.Errorf("failed to create end span request: %w", err)
+		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Idempotency-Key", rec.Key)
+	return l.Client.Do(req)
+}
 
-	resp, err := l.Client.Do(req)
+// deliverRecord sends rec, treating any 2xx as delivered.
+func (l *Logger) deliverRecord(rec spoolRecord) error {
+	resp, err := l.do(rec)
 	if err != nil {
-		return fmt.Errorf("failed to send end span request: %w", err)
+		return err
 	}
 	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
 
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return &statusError{code: resp.StatusCode}
 	}
-
 	return nil
 }
@@ -0,0 +1,268 @@
+package agentlogger
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// StatusCode is the outcome recorded on a span.
+type StatusCode string
+
+const (
+	StatusUnset StatusCode = "unset"
+	StatusOK    StatusCode = "ok"
+	StatusError StatusCode = "error"
+)
+
+// SpanEvent is a timestamped annotation recorded while a span is open.
+type SpanEvent struct {
+	Name       string                 `json:"name"`
+	Timestamp  time.Time              `json:"timestamp"`
+	Attributes map[string]interface{} `json:"attributes,omitempty"`
+}
+
+// Span is an in-flight trace span. It is safe for concurrent use, and all
+// methods are no-ops on a nil *Span so callers don't need to nil-check the
+// result of SpanFromContext.
+type Span struct {
+	logger         *Logger
+	id             string
+	teamID         string
+	agentID        string
+	conversationID string
+	parentID       *string
+	name           string
+	startErr       error
+
+	mu            sync.Mutex
+	attributes    map[string]interface{}
+	events        []SpanEvent
+	status        StatusCode
+	statusMessage string
+	ended         bool
+}
+
+type contextKey int
+
+const (
+	spanKey contextKey = iota
+	teamKey
+	agentKey
+	conversationKey
+)
+
+// WithTeam returns a copy of ctx carrying the team ID used by StartSpan.
+func WithTeam(ctx context.Context, teamID string) context.Context {
+	return context.WithValue(ctx, teamKey, teamID)
+}
+
+// WithAgent returns a copy of ctx carrying the agent ID used by StartSpan.
+func WithAgent(ctx context.Context, agentID string) context.Context {
+	return context.WithValue(ctx, agentKey, agentID)
+}
+
+// WithConversation returns a copy of ctx carrying the conversation ID used by StartSpan.
+func WithConversation(ctx context.Context, conversationID string) context.Context {
+	return context.WithValue(ctx, conversationKey, conversationID)
+}
+
+// ContextWithSpan returns a copy of ctx with span set as the current span.
+func ContextWithSpan(ctx context.Context, span *Span) context.Context {
+	return context.WithValue(ctx, spanKey, span)
+}
+
+// SpanFromContext returns the current span in ctx, or nil if there is none.
+func SpanFromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanKey).(*Span)
+	return span
+}
+
+func stringFromContext(ctx context.Context, key contextKey) string {
+	s, _ := ctx.Value(key).(string)
+	return s
+}
+
+// StartSpan opens a new span as a child of the span in ctx (if any) and
+// returns a context carrying the new span. Team, agent and conversation IDs
+// are inherited from the parent span, falling back to the values set with
+// WithTeam, WithAgent and WithConversation.
+//
+// StartSpan never fails: if the server can't be reached the span is still
+// returned so that instrumentation doesn't change control flow, and the
+// error is reported by End. Span IDs are generated locally, so children can
+// reference their parent even while requests are being spooled.
+func (l *Logger) StartSpan(ctx context.Context, name string, attributes map[string]interface{}) (context.Context, *Span) {
+	span := &Span{
+		logger:         l,
+		id:             uuid.New().String(),
+		teamID:         stringFromContext(ctx, teamKey),
+		agentID:        stringFromContext(ctx, agentKey),
+		conversationID: stringFromContext(ctx, conversationKey),
+		name:           name,
+		attributes:     make(map[string]interface{}),
+		status:         StatusUnset,
+	}
+	if parent := SpanFromContext(ctx); parent != nil {
+		parentID := parent.id
+		span.parentID = &parentID
+		if span.teamID == "" {
+			span.teamID = parent.teamID
+		}
+		if span.agentID == "" {
+			span.agentID = parent.agentID
+		}
+		if span.conversationID == "" {
+			span.conversationID = parent.conversationID
+		}
+	}
+	for k, v := range attributes {
+		span.attributes[k] = v
+	}
+
+	span.startErr = l.startSpan(span, attributes)
+	if span.startErr != nil {
+		log.Printf("agentlogger: failed to start span %s: %v", name, span.startErr)
+	}
+
+	return ContextWithSpan(ctx, span), span
+}
+
+// startSpan sends the span creation request.
+func (l *Logger) startSpan(span *Span, attributes map[string]interface{}) error {
+	var attrsJSON json.RawMessage
+	if attributes != nil {
+		var err error
+		attrsJSON, err = json.Marshal(attributes)
+		if err != nil {
+			return fmt.Errorf("failed to marshal attributes: %w", err)
+		}
+	}
+
+	now := time.Now()
+	req := LogTraceReq{
+		ID:             span.id,
+		TeamID:         span.teamID,
+		AgentID:        span.agentID,
+		ConversationID: span.conversationID,
+		ParentSpanID:   span.parentID,
+		SpanName:       span.name,
+		Attributes:     attrsJSON,
+		StartTime:      &now,
+	}
+
+	if err := l.postJSON("/internal/log_trace", span.id, req); err != nil {
+		return fmt.Errorf("failed to send start span request: %w", err)
+	}
+	return nil
+}
+
+// ID returns the span's ID.
+func (s *Span) ID() string {
+	if s == nil {
+		return ""
+	}
+	return s.id
+}
+
+// SetAttributes merges attrs into the span's attributes. They are sent when
+// the span ends; calls after End have no effect.
+func (s *Span) SetAttributes(attrs map[string]interface{}) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ended {
+		return
+	}
+	for k, v := range attrs {
+		s.attributes[k] = v
+	}
+}
+
+// AddEvent records a named event at the current time, unless the span has
+// ended.
+func (s *Span) AddEvent(name string, attrs map[string]interface{}) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ended {
+		return
+	}
+	s.events = append(s.events, SpanEvent{
+		Name:       name,
+		Timestamp:  time.Now(),
+		Attributes: attrs,
+	})
+}
+
+// RecordError records err as an "exception" event and marks the span as failed.
+func (s *Span) RecordError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.AddEvent("exception", map[string]interface{}{
+		"message": err.Error(),
+	})
+	s.SetStatus(StatusError, err.Error())
+}
+
+// SetStatus sets the span's status. The message is only kept for StatusError.
+// Calls after End have no effect.
+func (s *Span) SetStatus(code StatusCode, message string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ended {
+		return
+	}
+	s.status = code
+	if code == StatusError {
+		s.statusMessage = message
+	} else {
+		s.statusMessage = ""
+	}
+}
+
+// End closes the span, sending its final attributes, events and status.
+// Calling End more than once has no effect.
+func (s *Span) End() error {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return nil
+	}
+	s.ended = true
+	now := time.Now()
+	// The request is encoded after unlocking, so it gets its own copies.
+	attrs := make(map[string]interface{}, len(s.attributes))
+	for k, v := range s.attributes {
+		attrs[k] = v
+	}
+	req := EndSpanReq{
+		EndTime:       &now,
+		Attributes:    attrs,
+		Status:        s.status,
+		StatusMessage: s.statusMessage,
+		Events:        append([]SpanEvent(nil), s.events...),
+	}
+	s.mu.Unlock()
+
+	if s.startErr != nil {
+		return s.startErr
+	}
+	return s.logger.EndSpan(s.id, req)
+}
@@ -0,0 +1,401 @@
+package agentlogger
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultMaxSegmentBytes = 4 << 20  // 4MB
+	defaultMaxTotalBytes   = 64 << 20 // 64MB
+	defaultReplayInterval  = 5 * time.Second
+
+	segmentExt     = ".seg"
+	cursorFileName = "cursor.json"
+)
+
+// SpoolConfig configures the offline spool. Zero values use the defaults.
+type SpoolConfig struct {
+	Dir             string        // directory holding segment files (required)
+	MaxSegmentBytes int64         // rotate to a new segment after this many bytes; capped at MaxTotalBytes
+	MaxTotalBytes   int64         // evict oldest segments once the spool, active segment included, exceeds this
+	ReplayInterval  time.Duration // how often to retry delivery
+}
+
+// spoolRecord is one undelivered request, stored as a JSON line in a segment file.
+type spoolRecord struct {
+	Key       string          `json:"key"` // sent as the Idempotency-Key header
+	Method    string          `json:"method"`
+	Path      string          `json:"path"`
+	Body      json.RawMessage `json:"body"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// spoolCursor records how far into the oldest segment replay has got, so a
+// restart doesn't resend everything that was already delivered.
+type spoolCursor struct {
+	Segment uint64 `json:"segment"`
+	Offset  int64  `json:"offset"`
+}
+
+// spool is an append-only, segmented on-disk queue of undelivered requests.
+// Segments are replayed oldest first; once a segment is fully delivered it is
+// deleted.
+type spool struct {
+	cfg     SpoolConfig
+	deliver func(spoolRecord) error
+
+	mu         sync.Mutex
+	segments   []uint64         // sequence numbers on disk, oldest first
+	sizes      map[uint64]int64 // segment sizes in bytes
+	active     *os.File         // segment currently being appended to
+	activeSeq  uint64
+	activeSize int64
+	nextSeq    uint64
+	cursor     spoolCursor
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// EnableSpool turns on the offline spool. Requests that fail because the
+// server is unreachable are written to cfg.Dir and replayed in order by a
+// background goroutine once the server is back. While anything is spooled,
+// new requests are queued behind it so ordering is preserved. Spooled calls
+// report success.
+func (l *Logger) EnableSpool(cfg SpoolConfig) error {
+	if l.spool != nil {
+		return fmt.Errorf("spool already enabled")
+	}
+	s, err := openSpool(cfg, l.deliverRecord)
+	if err != nil {
+		return err
+	}
+	l.spool = s
+	s.start()
+	return nil
+}
+
+// Close stops spool replay and closes the active segment. Undelivered
+// records stay on disk and are replayed the next time the spool is enabled.
+func (l *Logger) Close() error {
+	if l.spool == nil {
+		return nil
+	}
+	return l.spool.close()
+}
+
+func openSpool(cfg SpoolConfig, deliver func(spoolRecord) error) (*spool, error) {
+	if cfg.Dir == "" {
+		return nil, fmt.Errorf("spool directory is required")
+	}
+	if cfg.MaxSegmentBytes <= 0 {
+		cfg.MaxSegmentBytes = defaultMaxSegmentBytes
+	}
+	if cfg.MaxTotalBytes <= 0 {
+		cfg.MaxTotalBytes = defaultMaxTotalBytes
+	}
+	if cfg.ReplayInterval <= 0 {
+		cfg.ReplayInterval = defaultReplayInterval
+	}
+	// The active segment can't be evicted, so it must fit in the budget on
+	// its own.
+	if cfg.MaxSegmentBytes > cfg.MaxTotalBytes {
+		cfg.MaxSegmentBytes = cfg.MaxTotalBytes
+	}
+	if err := os.MkdirAll(cfg.Dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create spool directory %s: %w", cfg.Dir, err)
+	}
+
+	s := &spool{
+		cfg:     cfg,
+		deliver: deliver,
+		sizes:   make(map[uint64]int64),
+		nextSeq: 1,
+		done:    make(chan struct{}),
+	}
+
+	entries, err := os.ReadDir(cfg.Dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read spool directory %s: %w", cfg.Dir, err)
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, segmentExt) {
+			continue
+		}
+		seq, err := strconv.ParseUint(strings.TrimSuffix(name, segmentExt), 10, 64)
+		if err != nil {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		s.segments = append(s.segments, seq)
+		s.sizes[seq] = info.Size()
+		if seq >= s.nextSeq {
+			s.nextSeq = seq + 1
+		}
+	}
+	sort.Slice(s.segments, func(i, j int) bool { return s.segments[i] < s.segments[j] })
+
+	if b, err := os.ReadFile(filepath.Join(cfg.Dir, cursorFileName)); err == nil {
+		if err := json.Unmarshal(b, &s.cursor); err != nil {
+			log.Printf("agentlogger: ignoring corrupt spool cursor: %v", err)
+			s.cursor = spoolCursor{}
+		}
+	}
+
+	return s, nil
+}
+
+func (s *spool) start() {
+	s.wg.Add(1)
+	go s.replayLoop()
+}
+
+func (s *spool) close() error {
+	close(s.done)
+	s.wg.Wait()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.active != nil {
+		err := s.active.Close()
+		s.active = nil
+		return err
+	}
+	return nil
+}
+
+// pending reports whether any records are waiting to be replayed.
+func (s *spool) pending() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.segments) > 0
+}
+
+// append writes rec to the active segment, rotating and evicting as needed.
+func (s *spool) append(rec spoolRecord) error {
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("failed to marshal spool record: %w", err)
+	}
+	line = append(line, '\n')
+	if int64(len(line)) > s.cfg.MaxTotalBytes {
+		return fmt.Errorf("spool record of %d bytes exceeds the spool size of %d bytes", len(line), s.cfg.MaxTotalBytes)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.active == nil || s.activeSize+int64(len(line)) > s.cfg.MaxSegmentBytes {
+		if err := s.rotateLocked(); err != nil {
+			return err
+		}
+	}
+
+	n, err := s.active.Write(line)
+	s.activeSize += int64(n)
+	s.sizes[s.activeSeq] = s.activeSize
+	if err != nil {
+		return fmt.Errorf("failed to write spool record: %w", err)
+	}
+
+	s.evictLocked()
+	return nil
+}
+
+// rotateLocked seals the active segment and opens a new one.
+func (s *spool) rotateLocked() error {
+	s.sealLocked()
+
+	seq := s.nextSeq
+	f, err := os.OpenFile(s.segmentPath(seq), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to create spool segment: %w", err)
+	}
+	s.nextSeq++
+	s.active = f
+	s.activeSeq = seq
+	s.activeSize = 0
+	s.segments = append(s.segments, seq)
+	s.sizes[seq] = 0
+	return nil
+}
+
+// sealLocked closes the active segment so no more records are appended to it.
+func (s *spool) sealLocked() {
+	if s.active == nil {
+		return
+	}
+	if err := s.active.Close(); err != nil {
+		log.Printf("agentlogger: failed to close spool segment %d: %v", s.activeSeq, err)
+	}
+	s.active = nil
+}
+
+// evictLocked deletes the oldest sealed segments until the spool fits in
+// MaxTotalBytes. The active segment counts towards the total but is never
+// evicted; since it is no bigger than MaxTotalBytes, dropping the sealed
+// segments is always enough.
+func (s *spool) evictLocked() {
+	var total int64
+	for _, size := range s.sizes {
+		total += size
+	}
+	for total > s.cfg.MaxTotalBytes && len(s.segments) > 1 {
+		oldest := s.segments[0]
+		if s.active != nil && oldest == s.activeSeq {
+			break
+		}
+		log.Printf("agentlogger: spool over %d bytes, dropping oldest segment %d", s.cfg.MaxTotalBytes, oldest)
+		total -= s.sizes[oldest]
+		s.removeSegmentLocked(oldest)
+	}
+}
+
+func (s *spool) removeSegmentLocked(seq uint64) {
+	if err := os.Remove(s.segmentPath(seq)); err != nil && !os.IsNotExist(err) {
+		log.Printf("agentlogger: failed to remove spool segment %d: %v", seq, err)
+	}
+	for i, v := range s.segments {
+		if v == seq {
+			s.segments = append(s.segments[:i], s.segments[i+1:]...)
+			break
+		}
+	}
+	delete(s.sizes, seq)
+	if s.cursor.Segment == seq {
+		s.cursor = spoolCursor{}
+		s.saveCursorLocked()
+	}
+}
+
+func (s *spool) saveCursorLocked() {
+	b, err := json.Marshal(s.cursor)
+	if err != nil {
+		return
+	}
+	path := filepath.Join(s.cfg.Dir, cursorFileName)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o644); err != nil {
+		log.Printf("agentlogger: failed to write spool cursor: %v", err)
+		return
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		log.Printf("agentlogger: failed to write spool cursor: %v", err)
+	}
+}
+
+func (s *spool) segmentPath(seq uint64) string {
+	return filepath.Join(s.cfg.Dir, fmt.Sprintf("%020d%s", seq, segmentExt))
+}
+
+// replayLoop periodically tries to deliver spooled records.
+func (s *spool) replayLoop() {
+	defer s.wg.Done()
+	ticker := time.NewTicker(s.cfg.ReplayInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+			s.replay()
+		}
+	}
+}
+
+// replay delivers segments oldest first until the spool is empty or the
+// server becomes unreachable again.
+func (s *spool) replay() {
+	for {
+		select {
+		case <-s.done:
+			return
+		default:
+		}
+
+		s.mu.Lock()
+		if len(s.segments) == 0 {
+			s.mu.Unlock()
+			return
+		}
+		seq := s.segments[0]
+		if s.active != nil && seq == s.activeSeq {
+			// Seal the segment being replayed; new records go to a fresh one.
+			s.sealLocked()
+		}
+		var offset int64
+		if s.cursor.Segment == seq {
+			offset = s.cursor.Offset
+		}
+		s.mu.Unlock()
+
+		if err := s.replaySegment(seq, offset); err != nil {
+			return
+		}
+
+		s.mu.Lock()
+		s.removeSegmentLocked(seq)
+		s.mu.Unlock()
+	}
+}
+
+// replaySegment delivers the records of one segment starting at offset. It
+// returns an error if delivery should be retried later.
+func (s *spool) replaySegment(seq uint64, offset int64) error {
+	f, err := os.Open(s.segmentPath(seq))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil // evicted while we weren't looking
+		}
+		return err
+	}
+	defer f.Close()
+
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+
+	reader := bufio.NewReader(f)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			// A trailing line without a newline is a write that was cut short.
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		var rec spoolRecord
+		if err := json.Unmarshal(line, &rec); err != nil {
+			log.Printf("agentlogger: skipping corrupt spool record in segment %d: %v", seq, err)
+		} else if err := s.deliver(rec); err != nil {
+			if isRetryable(err) {
+				return err
+			}
+			log.Printf("agentlogger: dropping spooled %s %s: %v", rec.Method, rec.Path, err)
+		}
+
+		offset += int64(len(line))
+		s.mu.Lock()
+		s.cursor = spoolCursor{Segment: seq, Offset: offset}
+		s.saveCursorLocked()
+		s.mu.Unlock()
+	}
+}
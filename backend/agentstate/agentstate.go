@@ -0,0 +1,318 @@
+// Package agentstate derives agent and team statuses from transcript and
+// hook events, and periodically marks agents that have gone quiet as stale.
+package agentstate
+
+import (
+	"log"
+	"strings"
+	"time"
+
+	"agent-observer/db"
+	"agent-observer/events"
+	"agent-observer/models"
+	"agent-observer/parser"
+)
+
+// Agent statuses.
+const (
+	Idle               = "idle"                // no activity yet
+	Thinking           = "thinking"            // the model is generating
+	RunningTool        = "running_tool"        // a tool call has no result yet
+	AwaitingPermission = "awaiting_permission" // blocked on a permission prompt (reported by hooks)
+	AwaitingUser       = "awaiting_user"       // finished its turn, waiting for the user
+	Errored            = "errored"             // the last turn failed with an API error
+	Completed          = "completed"           // a sub-agent that returned its result
+	Stale              = "stale"               // no activity for StaleAfter mid-turn
+)
+
+// Team statuses.
+const (
+	TeamRunning = "running"
+	TeamIdle    = "idle"
+	TeamStopped = "stopped"
+	TeamError   = "error"
+)
+
+// StaleAfter is how long an agent may go without activity mid-turn before
+// it is considered stale.
+var StaleAfter = 10 * time.Minute
+
+// Transition is published on the event bus when an agent's status changes.
+type Transition struct {
+	TeamID  string    `json:"team_id"`
+	AgentID string    `json:"agent_id"`
+	From    string    `json:"from"`
+	To      string    `json:"to"`
+	At      time.Time `json:"at"`
+}
+
+// TeamTransition is published on the event bus when a team's status changes.
+type TeamTransition struct {
+	TeamID string `json:"team_id"`
+	From   string `json:"from"`
+	To     string `json:"to"`
+}
+
+// ActiveStatuses are the statuses of an agent that is currently working or
+// blocked mid-turn.
+var ActiveStatuses = []string{Thinking, RunningTool, AwaitingPermission}
+
+// IsActive reports whether an agent with this status is currently working
+// or blocked mid-turn.
+func IsActive(status string) bool {
+	for _, s := range ActiveStatuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// canGoStale reports whether an agent with this status becomes stale after
+// StaleAfter without activity: only agents that died mid-turn. Finished
+// agents and ones waiting on the user never do.
+func canGoStale(status string) bool {
+	return IsActive(status)
+}
+
+// WithStaleness returns Stale if an unfinished agent's last activity is
+// older than StaleAfter, otherwise status unchanged.
+func WithStaleness(status string, lastActivity, now time.Time) string {
+	if canGoStale(status) && !lastActivity.IsZero() && now.Sub(lastActivity) > StaleAfter {
+		return Stale
+	}
+	return status
+}
+
+// Derive replays an agent's transcript through the state machine and
+// returns the resulting status and the time of the last event. Staleness is
+// not applied. Transcripts can't show permission prompts, so
+// AwaitingPermission only comes from hooks.
+func Derive(messages []parser.ParsedMessage, isSubagent bool) (string, time.Time) {
+	status := Idle
+	var last time.Time
+	pending := make(map[string]bool) // tool calls without a result
+
+	for _, msg := range messages {
+		if !msg.Timestamp.IsZero() {
+			last = msg.Timestamp
+		}
+
+		switch msg.Role {
+		case "user":
+			rejected := false
+			for id, result := range msg.ToolResults {
+				delete(pending, id)
+				if isRejection(result) {
+					rejected = true
+				}
+			}
+			switch {
+			case rejected || isInterruption(msg.Content):
+				status = AwaitingUser
+				pending = make(map[string]bool)
+			case len(msg.ToolResults) > 0 || msg.Content != "":
+				status = Thinking
+			}
+
+		case "assistant":
+			for _, tc := range msg.ToolCalls {
+				pending[tc.ID] = true
+			}
+			switch {
+			case msg.IsAPIError || strings.HasPrefix(msg.Content, "API Error"):
+				status = Errored
+			case len(msg.ToolCalls) > 0:
+				status = RunningTool
+			case msg.Content != "":
+				if isSubagent {
+					status = Completed
+				} else {
+					status = AwaitingUser
+				}
+			default:
+				status = Thinking
+			}
+		}
+	}
+
+	// Parallel tool calls: some results are in but others are still running.
+	if status == Thinking && len(pending) > 0 {
+		status = RunningTool
+	}
+	return status, last
+}
+
+// isInterruption reports whether a user message is Claude Code's marker for
+// the user pressing Esc.
+func isInterruption(content string) bool {
+	return strings.HasPrefix(content, "[Request interrupted by user")
+}
+
+// isRejection reports whether a tool result says the user declined the call.
+func isRejection(result string) bool {
+	return strings.HasPrefix(result, "The user doesn't want to proceed with this tool use") ||
+		strings.HasPrefix(result, "[Request interrupted by user")
+}
+
+// UpdateAgent records a new status for an agent, publishing a Transition if
+// it changed. at is when the event that caused the status happened.
+// Transcript-derived statuses (fromHook false) are ignored if a hook has
+// reported something at or after at, because hooks see states the
+// transcript can't.
+func UpdateAgent(agentID, status string, at time.Time, fromHook bool) error {
+	var agent models.Agent
+	if err := db.DB.First(&agent, "id = ?", agentID).Error; err != nil {
+		return err
+	}
+	if !fromHook && agent.HookEventAt != nil && !agent.HookEventAt.Before(at) {
+		return nil
+	}
+
+	status = WithStaleness(status, at, time.Now())
+	updates := map[string]interface{}{"status": status}
+	if !at.IsZero() {
+		updates["last_activity_at"] = at
+	}
+	if fromHook {
+		updates["hook_event_at"] = at
+	}
+	if err := db.DB.Model(&agent).Updates(updates).Error; err != nil {
+		return err
+	}
+
+	if agent.Status != status {
+		events.Publish(events.AgentStatusChanged, Transition{
+			TeamID:  agent.TeamID,
+			AgentID: agent.ID,
+			From:    agent.Status,
+			To:      status,
+			At:      at,
+		})
+	}
+	return nil
+}
+
+// TeamStatus derives a team's status from its agents: running while any
+// agent is active, otherwise following the lead agent.
+func TeamStatus(agents []models.Agent) string {
+	var lead *models.Agent
+	for i := range agents {
+		if IsActive(agents[i].Status) {
+			return TeamRunning
+		}
+		if agents[i].Role == "lead" {
+			lead = &agents[i]
+		}
+	}
+	if lead != nil {
+		switch lead.Status {
+		case Errored:
+			return TeamError
+		case Completed, Stale:
+			return TeamStopped
+		}
+	}
+	return TeamIdle
+}
+
+// RefreshTeam recomputes a team's status from its agents, publishing a
+// TeamTransition if it changed.
+func RefreshTeam(teamID string) error {
+	var team models.Team
+	if err := db.DB.First(&team, "id = ?", teamID).Error; err != nil {
+		return err
+	}
+	var agents []models.Agent
+	if err := db.DB.Where("team_id = ?", teamID).Find(&agents).Error; err != nil {
+		return err
+	}
+
+	status := TeamStatus(agents)
+	if status == team.Status {
+		return nil
+	}
+	if err := db.DB.Model(&team).Update("status", status).Error; err != nil {
+		return err
+	}
+	events.Publish(events.TeamStatusChanged, TeamTransition{
+		TeamID: team.ID,
+		From:   team.Status,
+		To:     status,
+	})
+	return nil
+}
+
+// Evaluator periodically marks agents that have gone quiet as stale, since
+// nothing else would re-evaluate them once their transcript stops changing.
+type Evaluator struct {
+	Interval time.Duration
+	done     chan struct{}
+}
+
+// NewEvaluator creates an Evaluator that runs every interval.
+func NewEvaluator(interval time.Duration) *Evaluator {
+	return &Evaluator{
+		Interval: interval,
+		done:     make(chan struct{}),
+	}
+}
+
+// Start begins periodic evaluation in a background goroutine.
+func (e *Evaluator) Start() {
+	go e.loop()
+	log.Printf("Agent status evaluator started (every %s, stale after %s)", e.Interval, StaleAfter)
+}
+
+// Stop terminates the evaluator.
+func (e *Evaluator) Stop() {
+	close(e.done)
+}
+
+func (e *Evaluator) loop() {
+	ticker := time.NewTicker(e.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-e.done:
+			return
+		case <-ticker.C:
+			e.evaluate()
+		}
+	}
+}
+
+// evaluate marks active agents with no activity for StaleAfter as stale
+// and refreshes the status of their teams.
+func (e *Evaluator) evaluate() {
+	cutoff := time.Now().Add(-StaleAfter)
+
+	var agents []models.Agent
+	if err := db.DB.Where("status IN ? AND last_activity_at < ?", ActiveStatuses, cutoff).Find(&agents).Error; err != nil {
+		log.Printf("Error evaluating agent statuses: %v", err)
+		return
+	}
+
+	teams := make(map[string]bool)
+	for _, agent := range agents {
+		if err := db.DB.Model(&agent).Update("status", Stale).Error; err != nil {
+			log.Printf("Warning: failed to mark agent %s stale: %v", agent.ID, err)
+			continue
+		}
+		events.Publish(events.AgentStatusChanged, Transition{
+			TeamID:  agent.TeamID,
+			AgentID: agent.ID,
+			From:    agent.Status,
+			To:      Stale,
+			At:      time.Now(),
+		})
+		teams[agent.TeamID] = true
+	}
+
+	for teamID := range teams {
+		if err := RefreshTeam(teamID); err != nil {
+			log.Printf("Warning: failed to refresh status of team %s: %v", teamID, err)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+// Package alerts evaluates alert rules against synced data, tracks alert
+// state (firing or resolved, deduplicated by rule and labels, optionally
+// silenced) and delivers notifications to webhooks.
+//
+// Rules and webhooks are read from a YAML or JSON file:
+//
+//	webhooks:
+//	  - name: ops
+//	    url: https://hooks.example.com/observer
+//	    template: '{"text": "[{{.Status | upper}}] {{.Alert.Summary}}"}'
+//	rules:
+//	  - name: team-spend
+//	    type: token_spend
+//	    threshold: 20      # USD
+//	    window: 1h
+//	  - name: dangerous-commands
+//	    type: dangerous_command
+//	    min_severity: high
+//	    webhooks: [ops]
+package alerts
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"agent-observer/audit"
+	"agent-observer/models"
+	"agent-observer/webhook"
+
+	"github.com/goccy/go-yaml"
+)
+
+// Rule types.
+const (
+	TokenSpend       = "token_spend"       // USD spent by a team in the window above threshold
+	ToolErrorRate    = "tool_error_rate"   // fraction of a team's tool calls failing in the window above threshold
+	AgentStuck       = "agent_stuck"       // a tool call open for threshold minutes
+	AgentLooping     = "agent_looping"     // an open loop annotation
+	DangerousCommand = "dangerous_command" // a command audit finding of min_severity or worse in the window
+	SessionOutcome   = "session_outcome"   // a conversation ending with one of outcomes in the window
+)
+
+// Alert severities.
+const (
+	SeverityInfo     = "info"
+	SeverityWarning  = "warning"
+	SeverityCritical = "critical"
+)
+
+// defaultWindows is the window of each rule type when a rule sets none.
+var defaultWindows = map[string]time.Duration{
+	TokenSpend:       time.Hour,
+	ToolErrorRate:    time.Hour,
+	AgentStuck:       24 * time.Hour,
+	AgentLooping:     24 * time.Hour,
+	DangerousCommand: time.Hour,
+	SessionOutcome:   24 * time.Hour,
+}
+
+// Rule is an alert rule.
+type Rule struct {
+	Name      string  `json:"name"`
+	Type      string  `json:"type"`
+	Threshold float64 `json:"threshold,omitempty"`
+	// Window limits the data a rule looks at to the last Window, e.g. "1h".
+	Window      string            `json:"window,omitempty"`
+	MinCalls    int               `json:"min_calls,omitempty"`    // tool_error_rate: fewer calls never fire (default 10)
+	MinSeverity string            `json:"min_severity,omitempty"` // dangerous_command: audit severity (default high)
+	Outcomes    []string          `json:"outcomes,omitempty"`     // session_outcome (default errored, hit_limits)
+	Match       map[string]string `json:"match,omitempty"`        // only alerts with these labels
+	Severity    string            `json:"severity,omitempty"`     // info, warning (default), critical
+	Webhooks    []string          `json:"webhooks,omitempty"`     // webhook names; empty means all
+	Disabled    bool              `json:"disabled,omitempty"`
+
+	window time.Duration
+}
+
+// Config is the alerts configuration file.
+type Config struct {
+	Rules    []Rule           `json:"rules"`
+	Webhooks []webhook.Target `json:"webhooks"`
+}
+
+// LoadConfig reads and validates a YAML or JSON config file.
+func LoadConfig(path string) (Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, fmt.Errorf("failed to read alerts config: %w", err)
+	}
+	if !strings.HasSuffix(path, ".json") {
+		if data, err = yaml.YAMLToJSON(data); err != nil {
+			return Config{}, fmt.Errorf("failed to parse alerts config %s: %w", path, err)
+		}
+	}
+	var config Config
+	if err := json.Unmarshal(data, &config); err != nil {
+		return Config{}, fmt.Errorf("failed to parse alerts config %s: %w", path, err)
+	}
+	if err := config.validate(); err != nil {
+		return Config{}, fmt.Errorf("invalid alerts config %s: %w", path, err)
+	}
+	return config, nil
+}
+
+// validate checks the config and fills in defaults.
+func (c *Config) validate() error {
+	webhooks := make(map[string]bool)
+	for _, w := range c.Webhooks {
+		if w.Name == "" || webhooks[w.Name] {
+			return fmt.Errorf("webhook names must be unique and not empty: %q", w.Name)
+		}
+		if err := w.Validate(); err != nil {
+			return err
+		}
+		webhooks[w.Name] = true
+	}
+
+	names := make(map[string]bool)
+	for i := range c.Rules {
+		r := &c.Rules[i]
+		if r.Name == "" || names[r.Name] {
+			return fmt.Errorf("rule names must be unique and not empty: %q", r.Name)
+		}
+		names[r.Name] = true
+
+		def, ok := defaultWindows[r.Type]
+		if !ok {
+			return fmt.Errorf("rule %s: unknown type %q", r.Name, r.Type)
+		}
+		r.window = def
+		if r.Window != "" {
+			d, err := time.ParseDuration(r.Window)
+			if err != nil || d <= 0 {
+				return fmt.Errorf("rule %s: invalid window %q", r.Name, r.Window)
+			}
+			r.window = d
+		}
+
+		switch r.Severity {
+		case "":
+			r.Severity = SeverityWarning
+		case SeverityInfo, SeverityWarning, SeverityCritical:
+		default:
+			return fmt.Errorf("rule %s: invalid severity %q", r.Name, r.Severity)
+		}
+
+		switch r.Type {
+		case TokenSpend, ToolErrorRate:
+			if r.Threshold <= 0 {
+				return fmt.Errorf("rule %s: %s needs a positive threshold", r.Name, r.Type)
+			}
+			if r.Type == ToolErrorRate && r.MinCalls == 0 {
+				r.MinCalls = 10
+			}
+		case AgentStuck:
+			if r.Threshold <= 0 {
+				r.Threshold = 10
+			}
+		case DangerousCommand:
+			if r.MinSeverity == "" {
+				r.MinSeverity = audit.SeverityHigh
+			}
+			if audit.SeverityRank(r.MinSeverity) == 0 {
+				return fmt.Errorf("rule %s: invalid min_severity %q", r.Name, r.MinSeverity)
+			}
+		case SessionOutcome:
+			if len(r.Outcomes) == 0 {
+				r.Outcomes = []string{models.OutcomeErrored, models.OutcomeHitLimits}
+			}
+		}
+
+		for _, w := range r.Webhooks {
+			if !webhooks[w] {
+				return fmt.Errorf("rule %s: unknown webhook %q", r.Name, w)
+			}
+		}
+	}
+	return nil
+}
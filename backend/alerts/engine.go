@@ -0,0 +1,361 @@
+package alerts
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"agent-observer/db"
+	"agent-observer/events"
+	"agent-observer/models"
+	"agent-observer/webhook"
+
+	"github.com/google/uuid"
+)
+
+var (
+	mu     sync.RWMutex
+	config Config
+)
+
+// SetConfig replaces the rules and webhooks.
+func SetConfig(c Config) {
+	mu.Lock()
+	defer mu.Unlock()
+	config = c
+}
+
+// Rules returns the configured rules.
+func Rules() []Rule {
+	mu.RLock()
+	defer mu.RUnlock()
+	return append([]Rule(nil), config.Rules...)
+}
+
+// Webhooks returns the configured webhooks.
+func Webhooks() []webhook.Target {
+	mu.RLock()
+	defer mu.RUnlock()
+	return append([]webhook.Target(nil), config.Webhooks...)
+}
+
+// Notification is what a webhook is sent when an alert fires or resolves:
+// the JSON body when the webhook has no template, and the template's data
+// otherwise.
+type Notification struct {
+	Status string            `json:"status"` // firing, resolved, or test
+	Alert  models.Alert      `json:"alert"`
+	Labels map[string]string `json:"labels"`
+}
+
+// evalMu serializes evaluations.
+var evalMu sync.Mutex
+
+// Evaluate runs every enabled rule once, firing alerts for new instances
+// and resolving firing alerts whose instance is gone. Alerts of disabled
+// rules, and of rules no longer configured, are resolved.
+func Evaluate() {
+	evalMu.Lock()
+	defer evalMu.Unlock()
+
+	now := time.Now()
+	silences := activeSilences(now)
+	rules := Rules()
+	names := make([]string, 0, len(rules))
+	for _, r := range rules {
+		names = append(names, r.Name)
+		if r.Disabled {
+			if err := reconcile(r, nil, silences, now); err != nil {
+				log.Printf("Error resolving alerts of disabled rule %s: %v", r.Name, err)
+			}
+			continue
+		}
+		found, err := evaluators[r.Type](r, now)
+		if err != nil {
+			log.Printf("Error evaluating alert rule %s: %v", r.Name, err)
+			continue
+		}
+		if err := reconcile(r, found, silences, now); err != nil {
+			log.Printf("Error updating alerts of rule %s: %v", r.Name, err)
+		}
+	}
+	if err := resolveRemoved(names, now); err != nil {
+		log.Printf("Error resolving alerts of removed rules: %v", err)
+	}
+}
+
+// resolveRemoved resolves the firing alerts of rules that aren't among the
+// configured ones. Their webhooks are gone with the rule, so only the
+// AlertResolved event is published.
+func resolveRemoved(rules []string, now time.Time) error {
+	query := db.DB.Where("status = ?", models.AlertFiring)
+	if len(rules) > 0 {
+		query = query.Where("rule NOT IN ?", rules)
+	}
+	var orphaned []models.Alert
+	if err := query.Find(&orphaned).Error; err != nil {
+		return err
+	}
+	for _, alert := range orphaned {
+		if err := resolve(&alert, now); err != nil {
+			return err
+		}
+		events.Publish(events.AlertResolved, alert)
+	}
+	return nil
+}
+
+// resolve marks a firing alert resolved.
+func resolve(alert *models.Alert, now time.Time) error {
+	alert.Status = models.AlertResolved
+	alert.EndsAt = &now
+	alert.UpdatedAt = now
+	return db.DB.Model(alert).Updates(map[string]interface{}{
+		"status":     alert.Status,
+		"ends_at":    now,
+		"updated_at": now,
+	}).Error
+}
+
+// reconcile brings a rule's stored alerts in line with what it found.
+func reconcile(r Rule, found []instance, silences []models.AlertSilence, now time.Time) error {
+	var firing []models.Alert
+	if err := db.DB.Where("rule = ? AND status = ?", r.Name, models.AlertFiring).Find(&firing).Error; err != nil {
+		return err
+	}
+	byFingerprint := make(map[string]models.Alert, len(firing))
+	for _, a := range firing {
+		byFingerprint[a.Fingerprint] = a
+	}
+
+	seen := make(map[string]bool)
+	for _, inst := range found {
+		if !matches(r.Match, inst.labels) {
+			continue
+		}
+		fp := fingerprint(r.Name, inst.labels)
+		if seen[fp] {
+			continue
+		}
+		seen[fp] = true
+
+		if existing, ok := byFingerprint[fp]; ok {
+			updates := map[string]interface{}{
+				"value":      inst.value,
+				"summary":    inst.summary,
+				"updated_at": now,
+			}
+			// An alert that fired while silenced is notified once the
+			// silence is over and it is still firing.
+			notifyNow := !existing.Notified && !silenced(silences, r.Name, inst.labels)
+			if notifyNow {
+				updates["notified"], updates["silenced"] = true, false
+			}
+			if err := db.DB.Model(&existing).Updates(updates).Error; err != nil {
+				return err
+			}
+			if notifyNow {
+				existing.Value, existing.Summary, existing.UpdatedAt = inst.value, inst.summary, now
+				existing.Notified, existing.Silenced = true, false
+				notify(r, existing, inst.labels, false)
+			}
+			continue
+		}
+
+		labels, _ := json.Marshal(inst.labels)
+		alert := models.Alert{
+			ID:          uuid.New().String(),
+			Rule:        r.Name,
+			Fingerprint: fp,
+			Status:      models.AlertFiring,
+			Severity:    r.Severity,
+			Summary:     inst.summary,
+			Labels:      labels,
+			Value:       inst.value,
+			Silenced:    silenced(silences, r.Name, inst.labels),
+			StartsAt:    now,
+			UpdatedAt:   now,
+		}
+		alert.Notified = !alert.Silenced
+		if err := db.DB.Create(&alert).Error; err != nil {
+			return err
+		}
+		events.Publish(events.AlertFiring, alert)
+		notify(r, alert, inst.labels, alert.Silenced)
+	}
+
+	for fp, alert := range byFingerprint {
+		if seen[fp] {
+			continue
+		}
+		if err := resolve(&alert, now); err != nil {
+			return err
+		}
+		var labels map[string]string
+		_ = json.Unmarshal(alert.Labels, &labels)
+		events.Publish(events.AlertResolved, alert)
+		notify(r, alert, labels, !alert.Notified || silenced(silences, r.Name, labels))
+	}
+	return nil
+}
+
+// notify delivers an alert to the rule's webhooks in the background,
+// unless it is silenced.
+func notify(r Rule, alert models.Alert, labels map[string]string, silenced bool) {
+	if silenced {
+		return
+	}
+	n := Notification{Status: alert.Status, Alert: alert, Labels: labels}
+	for _, target := range targets(r) {
+		go func(target webhook.Target) {
+			if result, err := Deliver(context.Background(), target, n); err != nil {
+				log.Printf("Warning: failed to render alert %s for webhook %s: %v", alert.ID, target.Name, err)
+			} else if !result.Delivered {
+				log.Printf("Warning: failed to deliver alert %s to webhook %s after %d attempts", alert.ID, target.Name, len(result.Attempts))
+			}
+		}(target)
+	}
+}
+
+// Deliver renders a notification for a webhook and sends it.
+func Deliver(ctx context.Context, target webhook.Target, n Notification) (webhook.Result, error) {
+	body, err := target.Render(n)
+	if err != nil {
+		return webhook.Result{}, err
+	}
+	return target.Send(ctx, body, nil), nil
+}
+
+// targets returns the webhooks a rule notifies.
+func targets(r Rule) []webhook.Target {
+	all := Webhooks()
+	if len(r.Webhooks) == 0 {
+		return all
+	}
+	var selected []webhook.Target
+	for _, t := range all {
+		for _, name := range r.Webhooks {
+			if t.Name == name {
+				selected = append(selected, t)
+			}
+		}
+	}
+	return selected
+}
+
+func matches(matchers, labels map[string]string) bool {
+	for k, v := range matchers {
+		if labels[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// fingerprint identifies an alert by its rule and labels.
+func fingerprint(rule string, labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	b.WriteString(rule)
+	for _, k := range keys {
+		b.WriteString("\x00" + k + "=" + labels[k])
+	}
+	return uuid.NewSHA1(uuid.NameSpaceURL, []byte(b.String())).String()
+}
+
+func activeSilences(now time.Time) []models.AlertSilence {
+	var silences []models.AlertSilence
+	if err := db.DB.Where("starts_at <= ? AND ends_at > ?", now, now).Find(&silences).Error; err != nil {
+		log.Printf("Warning: failed to fetch alert silences: %v", err)
+	}
+	return silences
+}
+
+// silenced reports whether any silence matches a rule's alert labels.
+func silenced(silences []models.AlertSilence, rule string, labels map[string]string) bool {
+	withName := map[string]string{"alertname": rule}
+	for k, v := range labels {
+		withName[k] = v
+	}
+	for _, s := range silences {
+		var matchers map[string]string
+		if json.Unmarshal(s.Matchers, &matchers) == nil && matches(matchers, withName) {
+			return true
+		}
+	}
+	return false
+}
+
+// Evaluator runs Evaluate periodically, and soon after events that may
+// change what rules find.
+type Evaluator struct {
+	Interval time.Duration
+	trigger  chan struct{}
+	done     chan struct{}
+}
+
+// triggers are the bus events that prompt an early evaluation.
+var triggers = map[string]bool{
+	events.AgentStatusChanged: true,
+	events.AgentLooping:       true,
+	events.AgentStuck:         true,
+	events.FileConflict:       true,
+}
+
+// NewEvaluator creates an Evaluator that runs every interval.
+func NewEvaluator(interval time.Duration) *Evaluator {
+	return &Evaluator{
+		Interval: interval,
+		trigger:  make(chan struct{}, 1),
+		done:     make(chan struct{}),
+	}
+}
+
+// Start begins evaluation in a background goroutine.
+func (e *Evaluator) Start() {
+	events.Subscribe(func(ev events.Event) {
+		if triggers[ev.Type] {
+			select {
+			case e.trigger <- struct{}{}:
+			default:
+			}
+		}
+	})
+	go e.loop()
+	log.Printf("Alert evaluator started (every %s, %d rules)", e.Interval, len(Rules()))
+}
+
+// Stop terminates the evaluator.
+func (e *Evaluator) Stop() {
+	close(e.done)
+}
+
+func (e *Evaluator) loop() {
+	ticker := time.NewTicker(e.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-e.done:
+			return
+		case <-ticker.C:
+			Evaluate()
+		case <-e.trigger:
+			// Let a burst of events (e.g. a sync) settle first.
+			time.Sleep(2 * time.Second)
+			select {
+			case <-e.trigger:
+			default:
+			}
+			Evaluate()
+		}
+	}
+}
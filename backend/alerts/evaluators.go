@@ -0,0 +1,206 @@
+package alerts
+
+import (
+	"fmt"
+	"time"
+
+	"agent-observer/audit"
+	"agent-observer/db"
+	"agent-observer/modelinfo"
+	"agent-observer/models"
+)
+
+// instance is one thing a rule currently fires for.
+type instance struct {
+	labels  map[string]string
+	value   float64
+	summary string
+}
+
+var evaluators = map[string]func(Rule, time.Time) ([]instance, error){
+	TokenSpend:       evalTokenSpend,
+	ToolErrorRate:    evalToolErrorRate,
+	AgentStuck:       evalAgentStuck,
+	AgentLooping:     evalAgentLooping,
+	DangerousCommand: evalDangerousCommand,
+	SessionOutcome:   evalSessionOutcome,
+}
+
+func evalTokenSpend(r Rule, now time.Time) ([]instance, error) {
+	var rows []struct {
+		TeamID        string
+		Model         string
+		InputTokens   int
+		CacheCreation int
+		CacheRead     int
+		OutputTokens  int
+	}
+	if err := db.DB.Model(&models.TurnUsage{}).
+		Select("team_id, model, SUM(input_tokens) AS input_tokens, SUM(cache_creation) AS cache_creation, SUM(cache_read) AS cache_read, SUM(output_tokens) AS output_tokens").
+		Where("created_at >= ?", now.Add(-r.window)).Group("team_id, model").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	spend := make(map[string]float64)
+	for _, row := range rows {
+		spend[row.TeamID] += modelinfo.PricingFor(row.Model).Cost(row.InputTokens, row.CacheCreation, row.CacheRead, row.OutputTokens)
+	}
+	names := teamNames(spend)
+
+	var found []instance
+	for teamID, cost := range spend {
+		if cost <= r.Threshold {
+			continue
+		}
+		found = append(found, instance{
+			labels:  map[string]string{"team_id": teamID, "team_name": names[teamID]},
+			value:   cost,
+			summary: fmt.Sprintf("Team %s spent $%.2f in the last %s (threshold $%.2f)", names[teamID], cost, r.window, r.Threshold),
+		})
+	}
+	return found, nil
+}
+
+func evalToolErrorRate(r Rule, now time.Time) ([]instance, error) {
+	var rows []struct {
+		TeamID string
+		Calls  int
+		Errors int
+	}
+	if err := db.DB.Model(&models.Trace{}).
+		Select("team_id, COUNT(*) AS calls, SUM(CASE WHEN status = ? THEN 1 ELSE 0 END) AS errors", models.SpanStatusError).
+		Where("span_name LIKE ? AND start_time >= ?", "tool.%", now.Add(-r.window)).Group("team_id").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	rates := make(map[string]float64)
+	for _, row := range rows {
+		if row.Calls >= r.MinCalls {
+			rates[row.TeamID] = float64(row.Errors) / float64(row.Calls)
+		}
+	}
+	names := teamNames(rates)
+
+	var found []instance
+	for teamID, rate := range rates {
+		if rate <= r.Threshold {
+			continue
+		}
+		found = append(found, instance{
+			labels:  map[string]string{"team_id": teamID, "team_name": names[teamID]},
+			value:   rate,
+			summary: fmt.Sprintf("%.0f%% of team %s's tool calls failed in the last %s", rate*100, names[teamID], r.window),
+		})
+	}
+	return found, nil
+}
+
+func evalAgentStuck(r Rule, now time.Time) ([]instance, error) {
+	openFor := time.Duration(r.Threshold * float64(time.Minute))
+	var traces []models.Trace
+	if err := db.DB.Select("id", "team_id", "agent_id", "span_name", "start_time").
+		Where("span_name LIKE ? AND end_time IS NULL AND start_time < ? AND start_time >= ?", "tool.%", now.Add(-openFor), now.Add(-r.window)).
+		Find(&traces).Error; err != nil {
+		return nil, err
+	}
+
+	var found []instance
+	for _, t := range traces {
+		minutes := now.Sub(t.StartTime).Minutes()
+		found = append(found, instance{
+			labels:  map[string]string{"team_id": t.TeamID, "agent_id": t.AgentID, "span_id": t.ID, "tool": t.SpanName},
+			value:   minutes,
+			summary: fmt.Sprintf("Agent %s has been waiting on %s for %.0f minutes", t.AgentID, t.SpanName, minutes),
+		})
+	}
+	return found, nil
+}
+
+func evalAgentLooping(r Rule, now time.Time) ([]instance, error) {
+	var annotations []models.Annotation
+	if err := db.DB.Where("resolved_at IS NULL AND kind != ? AND ended_at >= ?", models.AnnotationStuckToolCall, now.Add(-r.window)).
+		Find(&annotations).Error; err != nil {
+		return nil, err
+	}
+
+	var found []instance
+	for _, a := range annotations {
+		found = append(found, instance{
+			labels: map[string]string{
+				"team_id":         a.TeamID,
+				"agent_id":        a.AgentID,
+				"conversation_id": a.ConversationID,
+				"annotation_id":   a.ID,
+				"kind":            a.Kind,
+			},
+			value:   float64(a.Count),
+			summary: fmt.Sprintf("Agent %s: %s", a.AgentID, a.Message),
+		})
+	}
+	return found, nil
+}
+
+func evalDangerousCommand(r Rule, now time.Time) ([]instance, error) {
+	var commands []models.CommandAudit
+	if err := db.DB.Where("severity IN ? AND created_at >= ?", audit.Severities[audit.SeverityRank(r.MinSeverity)-1:], now.Add(-r.window)).
+		Find(&commands).Error; err != nil {
+		return nil, err
+	}
+
+	var found []instance
+	for _, cmd := range commands {
+		found = append(found, instance{
+			labels: map[string]string{
+				"team_id":          cmd.TeamID,
+				"agent_id":         cmd.AgentID,
+				"command_id":       cmd.ID,
+				"command_severity": cmd.Severity,
+			},
+			value:   float64(audit.SeverityRank(cmd.Severity)),
+			summary: fmt.Sprintf("Agent %s ran a %s-severity command: %s", cmd.AgentID, cmd.Severity, truncate(cmd.Command, 200)),
+		})
+	}
+	return found, nil
+}
+
+func evalSessionOutcome(r Rule, now time.Time) ([]instance, error) {
+	var convs []models.Conversation
+	if err := db.DB.Where("outcome IN ? AND ended_at >= ?", r.Outcomes, now.Add(-r.window)).Find(&convs).Error; err != nil {
+		return nil, err
+	}
+
+	var found []instance
+	for _, conv := range convs {
+		found = append(found, instance{
+			labels:  map[string]string{"team_id": conv.TeamID, "conversation_id": conv.ID, "outcome": conv.Outcome},
+			summary: fmt.Sprintf("Session %s ended %s: %s", conv.Title, conv.Outcome, conv.OutcomeReason),
+		})
+	}
+	return found, nil
+}
+
+// teamNames looks up the names of the teams keyed in m.
+func teamNames[V any](m map[string]V) map[string]string {
+	names := make(map[string]string, len(m))
+	if len(m) == 0 {
+		return names
+	}
+	ids := make([]string, 0, len(m))
+	for id := range m {
+		ids = append(ids, id)
+		names[id] = id
+	}
+	var teams []models.Team
+	db.DB.Select("id", "name").Where("id IN ?", ids).Find(&teams)
+	for _, t := range teams {
+		names[t.ID] = t.Name
+	}
+	return names
+}
+
+func truncate(s string, n int) string {
+	if r := []rune(s); len(r) > n {
+		return string(r[:n]) + "..."
+	}
+	return s
+}
@@ -0,0 +1,215 @@
+// Package analyzer derives findings from synced session data, such as
+// agents clobbering each other's edits.
+package analyzer
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"agent-observer/db"
+	"agent-observer/events"
+	"agent-observer/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm/clause"
+)
+
+// ConflictWindow is the longest pause between an agent's changes to a file
+// that still counts as one stretch of work on it, and how long after an
+// agent's last change another agent's change still conflicts with it.
+var ConflictWindow = 10 * time.Minute
+
+// DetectConflicts looks for agents in a team's scope working on the same
+// file at the same time: every session with the same TeamName, or just the
+// team itself if it has none. Two agents conflict on a file when their
+// stretches of work on it, each extended by ConflictWindow, overlap; one
+// taking over longer than that after the other's last change doesn't.
+// Conflicts are stored, conflicts that no longer show up are removed, and
+// a FileConflict event is published for each new one.
+func DetectConflicts(teamID string) error {
+	var team models.Team
+	if err := db.DB.First(&team, "id = ?", teamID).Error; err != nil {
+		return fmt.Errorf("failed to fetch team %s: %w", teamID, err)
+	}
+
+	scope := team.ID
+	teamIDs := []string{team.ID}
+	if team.TeamName != "" {
+		scope = team.TeamName
+		if err := db.DB.Model(&models.Team{}).Where("team_name = ?", team.TeamName).Pluck("id", &teamIDs).Error; err != nil {
+			return fmt.Errorf("failed to fetch teams in group %s: %w", team.TeamName, err)
+		}
+	}
+
+	var touches []models.FileTouch
+	if err := db.DB.Select("id", "team_id", "agent_id", "file_path", "operation", "created_at").
+		Where("team_id IN ? AND operation != ? AND failed = ?", teamIDs, models.FileOpRead, false).
+		Order("created_at ASC").Find(&touches).Error; err != nil {
+		return fmt.Errorf("failed to fetch file changes: %w", err)
+	}
+
+	byFile := make(map[string][]models.FileTouch)
+	for _, t := range touches {
+		byFile[t.FilePath] = append(byFile[t.FilePath], t)
+	}
+
+	var found []models.FileConflict
+	for path, changes := range byFile {
+		found = append(found, conflictsFor(scope, team.TeamName, path, workSpans(changes, ConflictWindow), ConflictWindow)...)
+	}
+
+	var existing []string
+	if err := db.DB.Model(&models.FileConflict{}).Where("scope = ?", scope).Pluck("id", &existing).Error; err != nil {
+		return fmt.Errorf("failed to fetch conflicts: %w", err)
+	}
+	known := make(map[string]bool, len(existing))
+	for _, id := range existing {
+		known[id] = true
+	}
+
+	ids := make([]string, 0, len(found))
+	for _, c := range found {
+		ids = append(ids, c.ID)
+		if err := db.DB.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"agent_ids", "team_ids", "touch_ids", "changes", "started_at", "ended_at"}),
+		}).Create(&c).Error; err != nil {
+			return fmt.Errorf("failed to save conflict on %s: %w", c.FilePath, err)
+		}
+		if !known[c.ID] {
+			events.Publish(events.FileConflict, c)
+		}
+	}
+
+	stale := db.DB.Where("scope = ?", scope)
+	if len(ids) > 0 {
+		stale = stale.Where("id NOT IN ?", ids)
+	}
+	if err := stale.Delete(&models.FileConflict{}).Error; err != nil {
+		return fmt.Errorf("failed to clear old conflicts: %w", err)
+	}
+	return nil
+}
+
+// workSpan is a stretch of one agent's changes to a file.
+type workSpan struct {
+	agentID string
+	changes []models.FileTouch
+}
+
+func (w workSpan) start() time.Time { return w.changes[0].CreatedAt }
+func (w workSpan) end() time.Time   { return w.changes[len(w.changes)-1].CreatedAt }
+
+// workSpans splits a file's changes, oldest first, into each agent's
+// stretches of work: changes by the agent at most window apart.
+func workSpans(changes []models.FileTouch, window time.Duration) []workSpan {
+	var spans []workSpan
+	latest := make(map[string]int) // agent to its latest span
+	for _, c := range changes {
+		if i, ok := latest[c.AgentID]; ok && c.CreatedAt.Sub(spans[i].end()) <= window {
+			spans[i].changes = append(spans[i].changes, c)
+			continue
+		}
+		latest[c.AgentID] = len(spans)
+		spans = append(spans, workSpan{agentID: c.AgentID, changes: []models.FileTouch{c}})
+	}
+	return spans
+}
+
+// conflictsFor returns a conflict for each episode of a pair of agents
+// working on a file at once: their stretches of work, each extended by
+// window after its last change, overlap. Overlapping stretches less than
+// window apart make one episode. A conflict's ID depends on the scope,
+// path, agents and the episode's first change, so it stays the same as
+// the episode grows.
+func conflictsFor(scope, teamName, path string, spans []workSpan, window time.Duration) []models.FileConflict {
+	pairs := make(map[[2]string][][]models.FileTouch)
+	for i, a := range spans {
+		for _, b := range spans[i+1:] {
+			if a.agentID == b.agentID || a.start().After(b.end().Add(window)) || b.start().After(a.end().Add(window)) {
+				continue
+			}
+			key := [2]string{a.agentID, b.agentID}
+			if key[0] > key[1] {
+				key[0], key[1] = key[1], key[0]
+			}
+			group := append(append([]models.FileTouch(nil), a.changes...), b.changes...)
+			sortTouches(group)
+			pairs[key] = append(pairs[key], group)
+		}
+	}
+
+	var conflicts []models.FileConflict
+	for agents, groups := range pairs {
+		for _, run := range episodes(groups, window) {
+			teams := make(map[string]bool)
+			touchIDs := make([]string, 0, len(run))
+			for _, t := range run {
+				teams[t.TeamID] = true
+				touchIDs = append(touchIDs, t.ID)
+			}
+			agentIDs, _ := json.Marshal(agents[:])
+			teamIDs, _ := json.Marshal(sortedKeys(teams))
+			touchJSON, _ := json.Marshal(touchIDs)
+			conflicts = append(conflicts, models.FileConflict{
+				ID:         uuid.NewSHA1(uuid.NameSpaceURL, []byte(scope+"\x00"+path+"\x00"+agents[0]+"\x00"+agents[1]+"\x00"+run[0].ID)).String(),
+				Scope:      scope,
+				TeamName:   teamName,
+				FilePath:   path,
+				AgentIDs:   agentIDs,
+				TeamIDs:    teamIDs,
+				TouchIDs:   touchJSON,
+				Changes:    len(run),
+				StartedAt:  run[0].CreatedAt,
+				EndedAt:    run[len(run)-1].CreatedAt,
+				DetectedAt: time.Now(),
+			})
+		}
+	}
+	return conflicts
+}
+
+// episodes merges a pair's groups of overlapping changes, each sorted,
+// into runs of changes: groups starting within window of the run's last
+// change join it.
+func episodes(groups [][]models.FileTouch, window time.Duration) [][]models.FileTouch {
+	sort.Slice(groups, func(i, j int) bool { return groups[i][0].CreatedAt.Before(groups[j][0].CreatedAt) })
+
+	var runs [][]models.FileTouch
+	var seen map[string]bool
+	for _, g := range groups {
+		if n := len(runs); n == 0 || g[0].CreatedAt.After(runs[n-1][len(runs[n-1])-1].CreatedAt.Add(window)) {
+			runs = append(runs, nil)
+			seen = make(map[string]bool)
+		}
+		n := len(runs) - 1
+		for _, t := range g {
+			if !seen[t.ID] {
+				seen[t.ID] = true
+				runs[n] = append(runs[n], t)
+			}
+		}
+		sortTouches(runs[n])
+	}
+	return runs
+}
+
+func sortTouches(touches []models.FileTouch) {
+	sort.Slice(touches, func(i, j int) bool {
+		if !touches[i].CreatedAt.Equal(touches[j].CreatedAt) {
+			return touches[i].CreatedAt.Before(touches[j].CreatedAt)
+		}
+		return touches[i].ID < touches[j].ID
+	})
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,408 @@
+package analyzer
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"agent-observer/agentstate"
+	"agent-observer/db"
+	"agent-observer/events"
+	"agent-observer/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm/clause"
+)
+
+// Loop detection thresholds.
+var (
+	// RepeatLimit is how many identical tool calls in a row make a loop.
+	RepeatLimit = 3
+	// FailureLimit is how many times the same call may fail before it is
+	// flagged, in a row or not.
+	FailureLimit = 3
+	// CycleLimit is how many times a pattern of tool calls must repeat.
+	CycleLimit = 5
+	// MaxCycleLength is the longest pattern of tool calls looked for.
+	MaxCycleLength = 4
+	// StuckAfter is how long a tool call may stay open.
+	StuckAfter = 5 * time.Minute
+	// StuckWindow bounds how old an open call can be and still count as
+	// stuck; older ones were left behind by sessions that died.
+	StuckWindow = 2 * time.Hour
+)
+
+// delegationTools stay open while the sub-agent they started works, so they
+// are never stuck themselves.
+var delegationTools = map[string]bool{"Task": true, "Agent": true}
+
+// toolSpan is a tool call span reduced to what loop detection compares.
+type toolSpan struct {
+	models.Trace
+	tool   string
+	input  string // canonical JSON of the tool input
+	target string // tool plus what it acts on, e.g. "Read:/repo/a.go"
+	failed bool
+}
+
+// DetectLoops looks for agents in a conversation repeating tool calls or
+// stuck on one, and stores the findings as annotations. Annotations that
+// are no longer detected are resolved, and an AgentLooping or AgentStuck
+// event is published for each new one.
+func DetectLoops(convID string) error {
+	var traces []models.Trace
+	if err := db.DB.Where("conversation_id = ? AND span_name LIKE ?", convID, "tool.%").
+		Order("start_time ASC").Find(&traces).Error; err != nil {
+		return fmt.Errorf("failed to fetch tool spans: %w", err)
+	}
+
+	byAgent := make(map[string][]toolSpan)
+	var agents []string
+	for _, t := range traces {
+		if _, ok := byAgent[t.AgentID]; !ok {
+			agents = append(agents, t.AgentID)
+		}
+		byAgent[t.AgentID] = append(byAgent[t.AgentID], newToolSpan(t))
+	}
+
+	var active []string
+	if err := db.DB.Model(&models.Agent{}).Where("id IN ? AND status IN ?", agents, agentstate.ActiveStatuses).
+		Pluck("id", &active).Error; err != nil {
+		return fmt.Errorf("failed to fetch agent statuses: %w", err)
+	}
+	working := make(map[string]bool, len(active))
+	for _, id := range active {
+		working[id] = true
+	}
+
+	var found []models.Annotation
+	now := time.Now()
+	for _, agentID := range agents {
+		spans := byAgent[agentID]
+		found = append(found, repeatedCalls(spans)...)
+		found = append(found, repeatedFailures(spans, found)...)
+		found = append(found, toolCycles(spans)...)
+		if working[agentID] {
+			found = append(found, stuckCalls(spans, now)...)
+		}
+	}
+	return saveAnnotations(convID, found, true)
+}
+
+// CheckStuck flags open tool calls of active agents that have been open
+// for StuckAfter, but not longer than StuckWindow. Transcripts don't change
+// while a call hangs, so this runs on a timer rather than after sync.
+func CheckStuck() {
+	now := time.Now()
+	var traces []models.Trace
+	if err := db.DB.Where("span_name LIKE ? AND end_time IS NULL AND start_time < ? AND start_time >= ?", "tool.%", now.Add(-StuckAfter), now.Add(-StuckWindow)).
+		Where("agent_id IN (?)", db.DB.Model(&models.Agent{}).Select("id").Where("status IN ?", agentstate.ActiveStatuses)).
+		Find(&traces).Error; err != nil {
+		log.Printf("Error checking for stuck tool calls: %v", err)
+		return
+	}
+
+	byConv := make(map[string][]models.Annotation)
+	for _, t := range traces {
+		byConv[t.ConversationID] = append(byConv[t.ConversationID], stuckCalls([]toolSpan{newToolSpan(t)}, now)...)
+	}
+	for convID, found := range byConv {
+		if err := saveAnnotations(convID, found, false); err != nil {
+			log.Printf("Warning: failed to save stuck tool calls of conversation %s: %v", convID, err)
+		}
+	}
+}
+
+func newToolSpan(t models.Trace) toolSpan {
+	var attrs map[string]interface{}
+	_ = json.Unmarshal(t.Attributes, &attrs)
+
+	s := toolSpan{Trace: t, failed: t.Status == models.SpanStatusError}
+	s.tool, _ = attrs["tool_name"].(string)
+	if s.tool == "" {
+		s.tool = strings.TrimPrefix(t.SpanName, "tool.")
+	}
+	input, _ := json.Marshal(attrs["input"]) // map keys marshal sorted
+	s.input = string(input)
+
+	s.target = s.tool
+	for _, key := range []string{"file_path", "pattern", "command", "url", "query"} {
+		if v, ok := attrs[key].(string); ok && v != "" {
+			s.target += ":" + v
+			break
+		}
+	}
+	return s
+}
+
+// repeatedCalls finds runs of identical consecutive calls.
+func repeatedCalls(spans []toolSpan) []models.Annotation {
+	var found []models.Annotation
+	for i := 0; i < len(spans); {
+		j := i + 1
+		for j < len(spans) && spans[j].tool == spans[i].tool && spans[j].input == spans[i].input {
+			j++
+		}
+		if j-i >= RepeatLimit {
+			run := spans[i:j]
+			severity := models.AnnotationWarning
+			if allFailed(run) {
+				severity = models.AnnotationCritical
+			}
+			found = append(found, annotation(models.AnnotationRepeatedCall, severity, run, j-i,
+				fmt.Sprintf("%s called %d times in a row with the same input", spans[i].tool, j-i)))
+		}
+		i = j
+	}
+	return found
+}
+
+// repeatedFailures finds failing calls retried with the same input, in a
+// row or interleaved with other calls. Runs already reported by
+// repeatedCalls are skipped.
+func repeatedFailures(spans []toolSpan, reported []models.Annotation) []models.Annotation {
+	covered := make(map[string]bool)
+	for _, a := range reported {
+		var ids []string
+		_ = json.Unmarshal(a.SpanIDs, &ids)
+		for _, id := range ids {
+			covered[id] = true
+		}
+	}
+
+	groups := make(map[string][]toolSpan)
+	var order []string
+	for _, s := range spans {
+		if !s.failed {
+			continue
+		}
+		key := s.tool + "\x00" + s.input
+		if _, ok := groups[key]; !ok {
+			order = append(order, key)
+		}
+		groups[key] = append(groups[key], s)
+	}
+
+	var found []models.Annotation
+	for _, key := range order {
+		group := groups[key]
+		if len(group) < FailureLimit || covered[group[0].ID] {
+			continue
+		}
+		found = append(found, annotation(models.AnnotationRepeatedFailure, models.AnnotationCritical, group, len(group),
+			fmt.Sprintf("%s failed %d times with the same input", group[0].tool, len(group))))
+	}
+	return found
+}
+
+// toolCycles finds patterns of up to MaxCycleLength calls, compared by
+// tool and target, repeating at least CycleLimit times in a row. Shorter
+// patterns are found first and their calls aren't reported again.
+func toolCycles(spans []toolSpan) []models.Annotation {
+	covered := make([]bool, len(spans))
+	var found []models.Annotation
+	for p := 1; p <= MaxCycleLength; p++ {
+		for i := 0; i+p < len(spans); {
+			j := i
+			for j+p < len(spans) && spans[j].target == spans[j+p].target {
+				j++
+			}
+			end := j + p
+			repeats := (end - i) / p
+			if repeats < CycleLimit || anyCovered(covered[i:end]) || (p == 1 && sameInput(spans[i:end])) {
+				i++
+				continue
+			}
+			for k := i; k < end; k++ {
+				covered[k] = true
+			}
+			pattern := make([]string, p)
+			for k := range pattern {
+				pattern[k] = spans[i+k].tool
+			}
+			found = append(found, annotation(models.AnnotationToolCycle, models.AnnotationWarning, spans[i:end], repeats,
+				fmt.Sprintf("%s repeated %d times on %s", strings.Join(pattern, " → "), repeats, cycleTargets(spans[i:i+p]))))
+			i = end
+		}
+	}
+	return found
+}
+
+// stuckCalls finds calls other than delegations that have been open for
+// between StuckAfter and StuckWindow.
+func stuckCalls(spans []toolSpan, now time.Time) []models.Annotation {
+	var found []models.Annotation
+	for _, s := range spans {
+		open := now.Sub(s.StartTime)
+		if s.EndTime != nil || open < StuckAfter || open > StuckWindow || delegationTools[s.tool] {
+			continue
+		}
+		minutes := int(now.Sub(s.StartTime).Minutes())
+		a := annotation(models.AnnotationStuckToolCall, models.AnnotationWarning, []toolSpan{s}, minutes,
+			fmt.Sprintf("%s open for %d minutes without a result", s.tool, minutes))
+		a.EndedAt = now
+		found = append(found, a)
+	}
+	return found
+}
+
+func annotation(kind, severity string, spans []toolSpan, count int, message string) models.Annotation {
+	ids := make([]string, 0, len(spans))
+	for _, s := range spans {
+		ids = append(ids, s.ID)
+	}
+	spanIDs, _ := json.Marshal(ids)
+	first, last := spans[0], spans[len(spans)-1]
+	return models.Annotation{
+		ID:             uuid.NewSHA1(uuid.NameSpaceURL, []byte(first.ConversationID+"\x00"+kind+"\x00"+first.ID)).String(),
+		ConversationID: first.ConversationID,
+		TeamID:         first.TeamID,
+		AgentID:        first.AgentID,
+		Kind:           kind,
+		Severity:       severity,
+		Message:        message,
+		SpanIDs:        spanIDs,
+		Count:          count,
+		StartedAt:      first.StartTime,
+		EndedAt:        last.StartTime,
+		CreatedAt:      time.Now(),
+	}
+}
+
+// saveAnnotations upserts found annotations for a conversation, publishing
+// an event for each that is new or was resolved before. With resolve, the
+// conversation's open loop annotations that weren't found are resolved.
+func saveAnnotations(convID string, found []models.Annotation, resolve bool) error {
+	var existing []models.Annotation
+	if err := db.DB.Select("id", "resolved_at").Where("conversation_id = ?", convID).Find(&existing).Error; err != nil {
+		return fmt.Errorf("failed to fetch annotations: %w", err)
+	}
+	open := make(map[string]bool, len(existing))
+	for _, a := range existing {
+		open[a.ID] = a.ResolvedAt == nil
+	}
+
+	ids := make([]string, 0, len(found))
+	for _, a := range found {
+		ids = append(ids, a.ID)
+		if err := db.DB.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"severity", "message", "span_ids", "count", "ended_at", "resolved_at"}),
+		}).Create(&a).Error; err != nil {
+			return fmt.Errorf("failed to save %s annotation: %w", a.Kind, err)
+		}
+		if !open[a.ID] {
+			eventType := events.AgentLooping
+			if a.Kind == models.AnnotationStuckToolCall {
+				eventType = events.AgentStuck
+			}
+			events.Publish(eventType, a)
+		}
+	}
+
+	if !resolve {
+		return nil
+	}
+	query := db.DB.Model(&models.Annotation{}).Where("conversation_id = ? AND resolved_at IS NULL AND kind IN ?", convID, []string{
+		models.AnnotationRepeatedCall, models.AnnotationRepeatedFailure, models.AnnotationToolCycle, models.AnnotationStuckToolCall,
+	})
+	if len(ids) > 0 {
+		query = query.Where("id NOT IN ?", ids)
+	}
+	if err := query.Update("resolved_at", time.Now()).Error; err != nil {
+		return fmt.Errorf("failed to resolve annotations: %w", err)
+	}
+	return nil
+}
+
+func allFailed(spans []toolSpan) bool {
+	for _, s := range spans {
+		if !s.failed {
+			return false
+		}
+	}
+	return true
+}
+
+func anyCovered(covered []bool) bool {
+	for _, c := range covered {
+		if c {
+			return true
+		}
+	}
+	return false
+}
+
+func sameInput(spans []toolSpan) bool {
+	for _, s := range spans[1:] {
+		if s.input != spans[0].input {
+			return false
+		}
+	}
+	return true
+}
+
+// cycleTargets describes what a cycle's calls act on.
+func cycleTargets(spans []toolSpan) string {
+	seen := make(map[string]bool)
+	var targets []string
+	for _, s := range spans {
+		target := strings.TrimPrefix(strings.TrimPrefix(s.target, s.tool), ":")
+		if target != "" && !seen[target] {
+			seen[target] = true
+			targets = append(targets, truncate(target, 80))
+		}
+	}
+	if len(targets) == 0 {
+		return "the same targets"
+	}
+	return strings.Join(targets, ", ")
+}
+
+func truncate(s string, n int) string {
+	if r := []rune(s); len(r) > n {
+		return string(r[:n]) + "..."
+	}
+	return s
+}
+
+// StuckWatcher runs CheckStuck periodically.
+type StuckWatcher struct {
+	Interval time.Duration
+	done     chan struct{}
+}
+
+// NewStuckWatcher creates a StuckWatcher that runs every interval.
+func NewStuckWatcher(interval time.Duration) *StuckWatcher {
+	return &StuckWatcher{
+		Interval: interval,
+		done:     make(chan struct{}),
+	}
+}
+
+// Start begins periodic checks in a background goroutine.
+func (w *StuckWatcher) Start() {
+	go w.loop()
+	log.Printf("Stuck tool call watcher started (every %s, stuck after %s)", w.Interval, StuckAfter)
+}
+
+// Stop terminates the watcher.
+func (w *StuckWatcher) Stop() {
+	close(w.done)
+}
+
+func (w *StuckWatcher) loop() {
+	ticker := time.NewTicker(w.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-ticker.C:
+			CheckStuck()
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package analyzer
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"agent-observer/agentstate"
+	"agent-observer/db"
+	"agent-observer/events"
+	"agent-observer/models"
+	"agent-observer/parser"
+)
+
+// limitMarkers identify API errors caused by usage, rate or context limits
+// rather than other failures.
+var limitMarkers = []string{
+	"usage limit",
+	"rate limit",
+	"rate_limit",
+	"limit reached",
+	"prompt is too long",
+	"context length",
+	"context window",
+	"maximum context",
+	"credit balance is too low",
+	"overloaded",
+}
+
+// Finish is published as events.SessionFinished when a conversation's
+// outcome changes from in progress (or unknown) to a final one.
+type Finish struct {
+	TeamID         string     `json:"team_id"`
+	ConversationID string     `json:"conversation_id"`
+	Title          string     `json:"title"`
+	Outcome        string     `json:"outcome"`
+	Reason         string     `json:"reason"`
+	EndedAt        *time.Time `json:"ended_at,omitempty"`
+}
+
+// ClassifyOutcome labels a conversation with how its session ended (see
+// the models.Outcome constants) and stores it. messages is the lead
+// agent's transcript, whose end decides the outcome; the session's test
+// runs decide whether a finished session actually completed.
+func ClassifyOutcome(convID string, messages []parser.ParsedMessage) error {
+	var runs []models.TestRun
+	if err := db.DB.Select("status").Where("conversation_id = ? AND status != ?", convID, models.TestRunRunning).
+		Order("created_at ASC").Find(&runs).Error; err != nil {
+		return fmt.Errorf("failed to fetch test runs: %w", err)
+	}
+	lastTest := ""
+	if len(runs) > 0 {
+		lastTest = runs[len(runs)-1].Status
+	}
+
+	var conv models.Conversation
+	if err := db.DB.First(&conv, "id = ?", convID).Error; err != nil {
+		return fmt.Errorf("failed to fetch conversation: %w", err)
+	}
+
+	previous := conv.Outcome
+	outcome, reason := classify(messages, lastTest, time.Now())
+	if err := db.DB.Model(&conv).Updates(map[string]interface{}{"outcome": outcome, "outcome_reason": reason}).Error; err != nil {
+		return err
+	}
+	if !isFinal(previous) && isFinal(outcome) {
+		events.Publish(events.SessionFinished, Finish{
+			TeamID:         conv.TeamID,
+			ConversationID: conv.ID,
+			Title:          conv.Title,
+			Outcome:        outcome,
+			Reason:         reason,
+			EndedAt:        conv.EndedAt,
+		})
+	}
+	return nil
+}
+
+// ExpireOutcomes marks a team's in-progress conversations as abandoned. It
+// is called when one of its agents goes stale.
+func ExpireOutcomes(teamID string) error {
+	var convs []models.Conversation
+	if err := db.DB.Where("team_id = ? AND outcome = ?", teamID, models.OutcomeInProgress).Find(&convs).Error; err != nil {
+		return err
+	}
+	const reason = "no activity while work was in progress"
+	for _, conv := range convs {
+		if err := db.DB.Model(&conv).Updates(map[string]interface{}{
+			"outcome":        models.OutcomeAbandoned,
+			"outcome_reason": reason,
+		}).Error; err != nil {
+			return err
+		}
+		events.Publish(events.SessionFinished, Finish{
+			TeamID:         conv.TeamID,
+			ConversationID: conv.ID,
+			Title:          conv.Title,
+			Outcome:        models.OutcomeAbandoned,
+			Reason:         reason,
+			EndedAt:        conv.EndedAt,
+		})
+	}
+	return nil
+}
+
+// isFinal reports whether a session with this outcome has ended.
+func isFinal(outcome string) bool {
+	return outcome != "" && outcome != models.OutcomeInProgress
+}
+
+// classify decides the outcome from the last meaningful message. lastTest
+// is the status of the session's last finished test run, if any.
+func classify(messages []parser.ParsedMessage, lastTest string, now time.Time) (outcome, reason string) {
+	var last *parser.ParsedMessage
+	for i := len(messages) - 1; i >= 0; i-- {
+		m := &messages[i]
+		if m.Role == "assistant" || m.Content != "" || len(m.ToolResults) > 0 {
+			last = m
+			break
+		}
+	}
+	if last == nil {
+		return models.OutcomeInProgress, "no messages yet"
+	}
+
+	// Work that stopped mid-turn: a user message without a reply, or a
+	// tool call without a result.
+	midTurn := func(why string) (string, string) {
+		if now.Sub(last.Timestamp) > agentstate.StaleAfter {
+			return models.OutcomeAbandoned, why
+		}
+		return models.OutcomeInProgress, why
+	}
+
+	if last.Role == "user" {
+		if strings.HasPrefix(last.Content, "[Request interrupted by user") {
+			return models.OutcomeInterrupted, "interrupted by the user"
+		}
+		for _, result := range last.ToolResults {
+			if strings.HasPrefix(result, "The user doesn't want to proceed with this tool use") ||
+				strings.HasPrefix(result, "[Request interrupted by user") {
+				return models.OutcomeInterrupted, "the user rejected a tool call"
+			}
+		}
+		if len(last.ToolResults) > 0 {
+			return midTurn("no reply to the last tool results")
+		}
+		return midTurn("no reply to the last message")
+	}
+
+	if last.IsAPIError || strings.HasPrefix(last.Content, "API Error") {
+		lower := strings.ToLower(last.Content)
+		for _, marker := range limitMarkers {
+			if strings.Contains(lower, marker) {
+				return models.OutcomeHitLimits, truncate(last.Content, 200)
+			}
+		}
+		return models.OutcomeErrored, truncate(last.Content, 200)
+	}
+
+	for _, tc := range last.ToolCalls {
+		if !tc.HasResult {
+			return midTurn("tool call without a result: " + tc.Name)
+		}
+	}
+	if len(last.ToolCalls) == 0 && last.Content == "" {
+		return midTurn("the last response has no text")
+	}
+
+	switch lastTest {
+	case models.TestRunFailed:
+		return models.OutcomeErrored, "finished with failing tests"
+	case models.TestRunError:
+		return models.OutcomeErrored, "finished with tests that didn't build"
+	}
+	return models.OutcomeCompleted, "finished its turn"
+}
@@ -0,0 +1,174 @@
+// Package audit checks the shell commands agents run against a set of
+// rules for dangerous operations.
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sync"
+)
+
+// Severities, least severe first.
+const (
+	SeverityLow      = "low"
+	SeverityMedium   = "medium"
+	SeverityHigh     = "high"
+	SeverityCritical = "critical"
+)
+
+// Severities lists every severity, least severe first.
+var Severities = []string{SeverityLow, SeverityMedium, SeverityHigh, SeverityCritical}
+
+// SeverityRank orders severities; unknown severities rank 0, below low.
+func SeverityRank(severity string) int {
+	for i, s := range Severities {
+		if s == severity {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// Rule flags commands matching Pattern, or, with OutsideCwd, commands that
+// write to paths outside the session's working directory. A rule with both
+// must satisfy both.
+type Rule struct {
+	ID          string `json:"id"`
+	Severity    string `json:"severity"`
+	Description string `json:"description"`
+	Pattern     string `json:"pattern,omitempty"`
+	OutsideCwd  bool   `json:"outside_cwd,omitempty"`
+	Disabled    bool   `json:"disabled,omitempty"`
+
+	re *regexp.Regexp
+}
+
+// Finding is a rule a command matched.
+type Finding struct {
+	RuleID      string `json:"rule_id"`
+	Severity    string `json:"severity"`
+	Description string `json:"description"`
+	Match       string `json:"match"` // the matched text, or the path written outside cwd
+}
+
+var (
+	mu    sync.RWMutex
+	rules []Rule
+)
+
+func init() {
+	for _, r := range builtinRules {
+		if err := AddRule(r); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// AddRule adds a rule, replacing any rule with the same ID. A disabled rule
+// removes the rule with its ID.
+func AddRule(r Rule) error {
+	if r.ID == "" {
+		return fmt.Errorf("audit rule without id")
+	}
+	if SeverityRank(r.Severity) == 0 {
+		return fmt.Errorf("audit rule %s: invalid severity %q", r.ID, r.Severity)
+	}
+	if r.Pattern == "" && !r.OutsideCwd && !r.Disabled {
+		return fmt.Errorf("audit rule %s: needs a pattern or outside_cwd", r.ID)
+	}
+	if r.Pattern != "" {
+		re, err := regexp.Compile(r.Pattern)
+		if err != nil {
+			return fmt.Errorf("audit rule %s: %w", r.ID, err)
+		}
+		r.re = re
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	kept := rules[:0:0]
+	for _, existing := range rules {
+		if existing.ID != r.ID {
+			kept = append(kept, existing)
+		}
+	}
+	if !r.Disabled {
+		kept = append(kept, r)
+	}
+	rules = kept
+	return nil
+}
+
+// Rules returns the active rules.
+func Rules() []Rule {
+	mu.RLock()
+	defer mu.RUnlock()
+	return append([]Rule(nil), rules...)
+}
+
+// LoadConfig reads a JSON array of rules from path and adds them to the
+// built-in rules. A rule with a built-in rule's ID replaces it, and
+// {"id": ..., "severity": ..., "disabled": true} turns it off.
+func LoadConfig(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read audit rules: %w", err)
+	}
+	var config []Rule
+	if err := json.Unmarshal(data, &config); err != nil {
+		return fmt.Errorf("failed to parse audit rules %s: %w", path, err)
+	}
+	for _, r := range config {
+		if err := AddRule(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Check returns the findings for a command run in cwd.
+func Check(command, cwd string) []Finding {
+	var outside []string
+	var findings []Finding
+	checkedOutside := false
+	for _, r := range Rules() {
+		match := ""
+		if r.re != nil {
+			match = r.re.FindString(command)
+			if match == "" {
+				continue
+			}
+		}
+		if r.OutsideCwd {
+			if !checkedOutside {
+				outside = writesOutside(command, cwd)
+				checkedOutside = true
+			}
+			if len(outside) == 0 {
+				continue
+			}
+			match = outside[0]
+		}
+		findings = append(findings, Finding{
+			RuleID:      r.ID,
+			Severity:    r.Severity,
+			Description: r.Description,
+			Match:       match,
+		})
+	}
+	return findings
+}
+
+// MaxSeverity returns the highest severity among findings, or "" if there
+// are none.
+func MaxSeverity(findings []Finding) string {
+	max := ""
+	for _, f := range findings {
+		if SeverityRank(f.Severity) > SeverityRank(max) {
+			max = f.Severity
+		}
+	}
+	return max
+}
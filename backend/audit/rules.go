@@ -0,0 +1,195 @@
+package audit
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var builtinRules = []Rule{
+	{
+		ID:          "rm_rf_root",
+		Severity:    SeverityCritical,
+		Description: "Recursive delete of the filesystem root or home directory",
+		Pattern:     `\brm\s+(?:-\S+\s+)*(?:/|/\*|~/?|\$HOME/?)(?:\s|$|;|&|\|)`,
+	},
+	{
+		ID:          "rm_rf",
+		Severity:    SeverityHigh,
+		Description: "Recursive forced delete",
+		Pattern:     `\brm\s+(?:-\S+\s+)*(?:-[a-zA-Z]*(?:r[a-zA-Z]*f|f[a-zA-Z]*r)[a-zA-Z]*|-[rR]\s+-f|-f\s+-[rR]|--recursive\s+--force|--force\s+--recursive)\b`,
+	},
+	{
+		ID:          "pipe_to_shell",
+		Severity:    SeverityCritical,
+		Description: "Downloaded script piped straight into a shell",
+		Pattern:     `\b(?:curl|wget)\b[^|;&]*\|\s*(?:sudo\s+)?(?:ba|z|da|k)?sh\b`,
+	},
+	{
+		ID:          "git_force_push",
+		Severity:    SeverityHigh,
+		Description: "Force push rewrites remote history",
+		Pattern:     `\bgit\s+push\b[^;&|]*\s(?:--force(?:-with-lease)?\b|-f\b|\+\S+)`,
+	},
+	{
+		ID:          "git_reset_hard",
+		Severity:    SeverityMedium,
+		Description: "Hard reset discards uncommitted work",
+		Pattern:     `\bgit\s+reset\s+(?:\S+\s+)*--hard\b`,
+	},
+	{
+		ID:          "git_clean",
+		Severity:    SeverityMedium,
+		Description: "git clean deletes untracked files",
+		Pattern:     `\bgit\s+clean\s+(?:\S+\s+)*-[a-zA-Z]*f`,
+	},
+	{
+		ID:          "git_no_verify",
+		Severity:    SeverityLow,
+		Description: "Commit or push skipping hooks",
+		Pattern:     `\bgit\s+(?:commit|push)\b[^;&|]*\s--no-verify\b`,
+	},
+	{
+		ID:          "sudo",
+		Severity:    SeverityMedium,
+		Description: "Command run as root",
+		Pattern:     `(?:^|[;&|(]\s*)sudo\s`,
+	},
+	{
+		ID:          "chmod_world_writable",
+		Severity:    SeverityMedium,
+		Description: "Permissions opened to everyone",
+		Pattern:     `\bchmod\s+(?:-\S+\s+)*(?:0?777|a\+w|o\+w)\b`,
+	},
+	{
+		ID:          "disk_write",
+		Severity:    SeverityCritical,
+		Description: "Raw write to a disk device or filesystem creation",
+		Pattern:     `\bmkfs(?:\.\w+)?\b|\bdd\s[^;&|]*\bof=/dev/(?:sd|disk|nvme|hd)`,
+	},
+	{
+		ID:          "write_outside_repo",
+		Severity:    SeverityHigh,
+		Description: "Writes to a path outside the working directory",
+		OutsideCwd:  true,
+	},
+}
+
+// tempDirs are outside the working directory but are fine to write to.
+var tempDirs = []string{"/tmp", "/private/tmp", "/var/folders", "/dev"}
+
+// segmentSep splits a command line into simple commands.
+var segmentSep = regexp.MustCompile(`&&|\|\||[;|\n]`)
+
+// redirect matches an output redirection, with or without a space before
+// the target.
+var redirect = regexp.MustCompile(`^\d*>>?\|?(.*)$`)
+
+// writesOutside returns the paths a command writes to that are outside
+// cwd, following `cd` between simple commands. The shell parsing is
+// deliberately rough: it catches the common cases, and paths built from
+// variables other than $HOME are ignored.
+func writesOutside(command, cwd string) []string {
+	if cwd == "" {
+		return nil
+	}
+	dir := cwd
+	var outside []string
+	for _, segment := range segmentSep.Split(command, -1) {
+		args := strings.Fields(segment)
+		for i := range args {
+			args[i] = strings.Trim(args[i], `"'`)
+		}
+		// Skip env assignments and privilege wrappers.
+		for len(args) > 0 && (strings.Contains(args[0], "=") || args[0] == "sudo" || args[0] == "env") {
+			args = args[1:]
+		}
+		if len(args) == 0 {
+			continue
+		}
+
+		if args[0] == "cd" {
+			if len(args) > 1 {
+				dir = resolve(dir, args[1])
+			}
+			continue
+		}
+
+		for _, target := range writeTargets(args) {
+			path := resolve(dir, target)
+			if path != "" && !within(path, cwd) {
+				outside = append(outside, path)
+			}
+		}
+	}
+	return outside
+}
+
+// writeTargets returns the paths a simple command writes to.
+func writeTargets(args []string) []string {
+	var targets, operands []string
+	for i := 0; i < len(args); i++ {
+		if m := redirect.FindStringSubmatch(args[i]); m != nil {
+			switch {
+			case strings.HasPrefix(m[1], "&"):
+			case m[1] != "":
+				targets = append(targets, m[1])
+			case i+1 < len(args):
+				targets = append(targets, args[i+1])
+				i++
+			}
+			continue
+		}
+		if i > 0 && !strings.HasPrefix(args[i], "-") {
+			operands = append(operands, args[i])
+		}
+	}
+	if len(operands) == 0 {
+		return targets
+	}
+
+	switch filepath.Base(args[0]) {
+	case "tee", "rm", "rmdir", "touch", "mkdir", "truncate":
+		targets = append(targets, operands...)
+	case "chmod", "chown":
+		targets = append(targets, operands[1:]...)
+	case "cp", "mv", "install", "ln", "rsync", "scp":
+		targets = append(targets, operands[len(operands)-1])
+	case "sed":
+		for _, a := range args {
+			if strings.HasPrefix(a, "-i") {
+				targets = append(targets, operands[len(operands)-1])
+				break
+			}
+		}
+	}
+	return targets
+}
+
+// resolve makes path absolute against dir. Home-relative paths resolve to
+// "~/..."; paths built from other variables resolve to "".
+func resolve(dir, path string) string {
+	switch {
+	case path == "~" || path == "$HOME":
+		return "~"
+	case strings.HasPrefix(path, "~/"):
+		return path
+	case strings.HasPrefix(path, "$HOME/"):
+		return "~" + strings.TrimPrefix(path, "$HOME")
+	case strings.Contains(path, "$") || strings.Contains(path, ":"):
+		return ""
+	case filepath.IsAbs(path):
+		return filepath.Clean(path)
+	}
+	return filepath.Join(dir, path)
+}
+
+// within reports whether path is cwd, inside it, or in a temp directory.
+func within(path, cwd string) bool {
+	for _, dir := range append([]string{cwd}, tempDirs...) {
+		if path == dir || strings.HasPrefix(path, strings.TrimSuffix(dir, "/")+"/") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,62 @@
+// Command observer-hook forwards Claude Code hook payloads to the observer.
+//
+// Register it for each hook event in ~/.claude/settings.json, e.g.
+//
+//	{
+//	  "hooks": {
+//	    "PreToolUse": [{"matcher": "*", "hooks": [{"type": "command", "command": "observer-hook"}]}],
+//	    "PostToolUse": [{"matcher": "*", "hooks": [{"type": "command", "command": "observer-hook"}]}],
+//	    "SessionStart": [{"hooks": [{"type": "command", "command": "observer-hook"}]}],
+//	    "UserPromptSubmit": [{"hooks": [{"type": "command", "command": "observer-hook"}]}],
+//	    "Notification": [{"hooks": [{"type": "command", "command": "observer-hook"}]}],
+//	    "Stop": [{"hooks": [{"type": "command", "command": "observer-hook"}]}],
+//	    "SubagentStop": [{"hooks": [{"type": "command", "command": "observer-hook"}]}]
+//	  }
+//	}
+//
+// The observer URL defaults to http://localhost:8080 and can be overridden
+// with AGENT_OBSERVER_URL. The command always exits 0 and prints nothing to
+// stdout so it never blocks or alters Claude Code, even if the observer is
+// down.
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+)
+
+const defaultObserverURL = "http://localhost:8080"
+
+func main() {
+	if err := forward(); err != nil {
+		fmt.Fprintf(os.Stderr, "observer-hook: %v\n", err)
+	}
+}
+
+func forward() error {
+	body, err := io.ReadAll(io.LimitReader(os.Stdin, 16<<20))
+	if err != nil {
+		return fmt.Errorf("failed to read hook payload: %w", err)
+	}
+
+	baseURL := os.Getenv("AGENT_OBSERVER_URL")
+	if baseURL == "" {
+		baseURL = defaultObserverURL
+	}
+
+	client := &http.Client{Timeout: 2 * time.Second}
+	resp, err := client.Post(baseURL+"/internal/hooks/claude", "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to send hook payload: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,256 @@
+// Package commgraph builds the graph of who talked to whom from the
+// interactions between agents: nodes are agents, edges aggregate the
+// delegations, results and teammate messages from one agent to another.
+package commgraph
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"agent-observer/models"
+)
+
+// LeadName is the name Claude Code teammates use to message the agent that
+// leads the team.
+const LeadName = "team-lead"
+
+// Node is an agent, or a recipient name that matches no synced agent.
+type Node struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Role     string `json:"role,omitempty"` // lead, teammate; empty for unknown recipients
+	TeamID   string `json:"team_id,omitempty"`
+	Team     string `json:"team,omitempty"`
+	Sent     int    `json:"sent"`
+	Received int    `json:"received"`
+}
+
+// Edge aggregates the interactions of one kind from one node to another.
+type Edge struct {
+	From       string      `json:"from"`
+	To         string      `json:"to"`
+	Kind       string      `json:"kind"` // see the models.Interaction kinds
+	Messages   int         `json:"messages"`
+	Tokens     int         `json:"tokens"`
+	FirstAt    time.Time   `json:"first_at"`
+	LastAt     time.Time   `json:"last_at"`
+	Timestamps []time.Time `json:"timestamps"`
+}
+
+// Graph is a communication graph.
+type Graph struct {
+	Nodes []Node `json:"nodes"`
+	Edges []Edge `json:"edges"`
+}
+
+// Build builds the graph of teams' agents from their interactions.
+// Recipient names are matched against agent names; LeadName, unless an
+// agent is called that, is the lead agent of the first team, and a
+// broadcast reaches the lead agent of every other team. teams is not
+// modified.
+func Build(teams []models.Team, agents []models.Agent, interactions []models.Interaction) Graph {
+	teams = append([]models.Team(nil), teams...)
+	sort.SliceStable(teams, func(i, j int) bool { return teams[i].CreatedAt.Before(teams[j].CreatedAt) })
+	teamNames := make(map[string]string, len(teams))
+	for _, t := range teams {
+		teamNames[t.ID] = t.Name
+	}
+
+	nodes := make(map[string]*Node)
+	byName := make(map[string]string)
+	var leads []string
+	for _, a := range agents {
+		nodes[a.ID] = &Node{ID: a.ID, Name: a.Name, Role: a.Role, TeamID: a.TeamID, Team: teamNames[a.TeamID]}
+		if a.Role == "lead" {
+			leads = append(leads, a.ID)
+		}
+		if id, ok := byName[a.Name]; !ok || (nodes[id].Role != "lead" && a.Role == "lead") {
+			byName[a.Name] = a.ID
+		}
+	}
+	if _, ok := byName[LeadName]; !ok && len(teams) > 0 {
+		for _, a := range agents {
+			if a.Role == "lead" && a.TeamID == teams[0].ID {
+				byName[LeadName] = a.ID
+			}
+		}
+	}
+
+	node := func(id string) *Node {
+		if n, ok := nodes[id]; ok {
+			return n
+		}
+		n := &Node{ID: id, Name: id}
+		nodes[id] = n
+		return n
+	}
+	recipients := func(i models.Interaction, from *Node) []string {
+		switch {
+		case i.ToAgentID != "":
+			return []string{i.ToAgentID}
+		case i.Recipient == "*":
+			var to []string
+			for _, id := range leads {
+				if id != from.ID && nodes[id].TeamID != from.TeamID {
+					to = append(to, id)
+				}
+			}
+			return to
+		case i.Recipient != "":
+			if id, ok := byName[i.Recipient]; ok {
+				return []string{id}
+			}
+			n := node("recipient:" + i.Recipient)
+			n.Name = i.Recipient
+			return []string{n.ID}
+		}
+		return nil
+	}
+
+	edges := make(map[[3]string]*Edge)
+	for _, i := range interactions {
+		from := node(i.FromAgentID)
+		for _, to := range recipients(i, from) {
+			key := [3]string{i.FromAgentID, to, i.Kind}
+			e, ok := edges[key]
+			if !ok {
+				e = &Edge{From: i.FromAgentID, To: to, Kind: i.Kind, FirstAt: i.CreatedAt}
+				edges[key] = e
+			}
+			e.Messages++
+			e.Tokens += i.Tokens
+			if i.CreatedAt.Before(e.FirstAt) {
+				e.FirstAt = i.CreatedAt
+			}
+			if i.CreatedAt.After(e.LastAt) {
+				e.LastAt = i.CreatedAt
+			}
+			e.Timestamps = append(e.Timestamps, i.CreatedAt)
+			from.Sent++
+			node(to).Received++
+		}
+	}
+
+	g := Graph{Nodes: make([]Node, 0, len(nodes)), Edges: make([]Edge, 0, len(edges))}
+	for _, n := range nodes {
+		g.Nodes = append(g.Nodes, *n)
+	}
+	for _, e := range edges {
+		sort.Slice(e.Timestamps, func(i, j int) bool { return e.Timestamps[i].Before(e.Timestamps[j]) })
+		g.Edges = append(g.Edges, *e)
+	}
+	g.sort()
+	return g
+}
+
+// Focus returns the part of the graph around some agents: the edges from
+// or to them, and the nodes of those agents and the ones they talked to.
+func (g Graph) Focus(agentIDs map[string]bool) Graph {
+	keep := make(map[string]bool)
+	for id := range agentIDs {
+		keep[id] = true
+	}
+	focused := Graph{Nodes: []Node{}, Edges: []Edge{}}
+	for _, e := range g.Edges {
+		if agentIDs[e.From] || agentIDs[e.To] {
+			focused.Edges = append(focused.Edges, e)
+			keep[e.From], keep[e.To] = true, true
+		}
+	}
+	for _, n := range g.Nodes {
+		if keep[n.ID] {
+			focused.Nodes = append(focused.Nodes, n)
+		}
+	}
+	return focused
+}
+
+// sort orders nodes by team, leads first, then by name, and edges by when
+// they started.
+func (g Graph) sort() {
+	sort.Slice(g.Nodes, func(i, j int) bool {
+		a, b := g.Nodes[i], g.Nodes[j]
+		if a.TeamID != b.TeamID {
+			return a.TeamID != "" && (b.TeamID == "" || a.TeamID < b.TeamID)
+		}
+		if (a.Role == "lead") != (b.Role == "lead") {
+			return a.Role == "lead"
+		}
+		return a.Name < b.Name
+	})
+	sort.Slice(g.Edges, func(i, j int) bool {
+		if !g.Edges[i].FirstAt.Equal(g.Edges[j].FirstAt) {
+			return g.Edges[i].FirstAt.Before(g.Edges[j].FirstAt)
+		}
+		return g.Edges[i].From+g.Edges[i].To+g.Edges[i].Kind < g.Edges[j].From+g.Edges[j].To+g.Edges[j].Kind
+	})
+}
+
+// edgeStyles is the DOT line style of each interaction kind.
+var edgeStyles = map[string]string{
+	models.InteractionDelegation: "solid",
+	models.InteractionResult:     "dashed",
+	models.InteractionMessage:    "dotted",
+}
+
+// DOT renders the graph in the Graphviz DOT language, with each team's
+// agents in a cluster and edges thicker the more messages they carry.
+func (g Graph) DOT(name string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "digraph %s {\n", quote(name))
+	b.WriteString("  rankdir=LR;\n")
+	b.WriteString("  node [shape=box, style=rounded];\n")
+
+	// Nodes are sorted by team, with unknown recipients (no team) last.
+	team := ""
+	for _, n := range g.Nodes {
+		if n.TeamID != team {
+			if team != "" {
+				b.WriteString("  }\n")
+			}
+			team = n.TeamID
+			if team != "" {
+				label := n.Team
+				if label == "" {
+					label = team
+				}
+				fmt.Fprintf(&b, "  subgraph %s {\n    label=%s;\n", quote("cluster_"+team), quote(label))
+			}
+		}
+		indent := "  "
+		if team != "" {
+			indent = "    "
+		}
+		attrs := "label=" + quote(n.Name)
+		switch n.Role {
+		case "lead":
+			attrs += ", penwidth=2"
+		case "":
+			attrs += ", style=dashed"
+		}
+		fmt.Fprintf(&b, "%s%s [%s];\n", indent, quote(n.ID), attrs)
+	}
+	if team != "" {
+		b.WriteString("  }\n")
+	}
+
+	for _, e := range g.Edges {
+		label := fmt.Sprintf("%s x%d, ~%d tokens", e.Kind, e.Messages, e.Tokens)
+		width := math.Min(1+math.Log2(float64(e.Messages)), 6)
+		style := edgeStyles[e.Kind]
+		if style == "" {
+			style = "solid"
+		}
+		fmt.Fprintf(&b, "  %s -> %s [label=%s, style=%s, penwidth=%.1f];\n", quote(e.From), quote(e.To), quote(label), style, width)
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// quote returns s as a DOT quoted string.
+func quote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s) + `"`
+}
@@ -0,0 +1,59 @@
+package datasync
+
+import (
+	"encoding/json"
+
+	"agent-observer/audit"
+	"agent-observer/models"
+	"agent-observer/parser"
+	"agent-observer/toolattrs"
+)
+
+// commandAudits builds the command audit log for an agent's transcript
+// from its Bash calls, checking each command against the audit rules.
+func commandAudits(messages []parser.ParsedMessage, teamID, convID, agentID string) []models.CommandAudit {
+	var audits []models.CommandAudit
+	for _, msg := range messages {
+		if msg.Role != "assistant" {
+			continue
+		}
+		for _, tc := range msg.ToolCalls {
+			if tc.ID == "" || tc.Name != "Bash" {
+				continue
+			}
+			command, _ := tc.Input["command"].(string)
+			a := models.CommandAudit{
+				ID:             tc.ID,
+				TeamID:         teamID,
+				AgentID:        agentID,
+				ConversationID: convID,
+				Command:        command,
+				Cwd:            msg.Cwd,
+				Completed:      tc.HasResult,
+				Failed:         tc.IsError,
+				OutputBytes:    len(tc.Result),
+				CreatedAt:      msg.Timestamp,
+			}
+			a.Description, _ = tc.Input["description"].(string)
+			a.Background, _ = tc.Input["run_in_background"].(bool)
+			if timeout, ok := tc.Input["timeout"].(float64); ok {
+				a.TimeoutMs = int(timeout)
+			}
+			if tc.HasResult {
+				if code, ok := toolattrs.ExitCode(tc.Result, tc.IsError); ok {
+					a.ExitCode = &code
+				}
+				if !tc.ResultAt.IsZero() {
+					a.DurationMs = tc.ResultAt.Sub(msg.Timestamp).Milliseconds()
+				}
+			}
+
+			if findings := audit.Check(command, msg.Cwd); len(findings) > 0 {
+				a.Findings, _ = json.Marshal(findings)
+				a.Severity = audit.MaxSeverity(findings)
+			}
+			audits = append(audits, a)
+		}
+	}
+	return audits
+}
@@ -6,10 +6,16 @@ import (
 	"log"
 	"strings"
 	"time"
+	"unicode/utf8"
 
+	"agent-observer/agentstate"
+	"agent-observer/analyzer"
 	"agent-observer/db"
+	"agent-observer/events"
 	"agent-observer/models"
 	"agent-observer/parser"
+	"agent-observer/toolattrs"
+	"agent-observer/toolerrors"
 
 	"github.com/google/uuid"
 	"gorm.io/datatypes"
@@ -27,84 +33,104 @@ func SyncSession(parsed *parser.ParsedSession) error {
 	log.Printf("Syncing session %s (slug: %s, %d main messages, %d subagents)",
 		parsed.SessionID, parsed.Slug, len(parsed.MainMessages), len(parsed.SubAgents))
 
-	// Determine team status based on latest message time
-	status := "idle"
-	if !parsed.EndedAt.IsZero() && time.Since(parsed.EndedAt) < 5*time.Minute {
-		status = "running"
-	}
-
 	// Determine display name: prefer agentName, then slug
 	displayName := parsed.Slug
 	if parsed.AgentName != "" {
 		displayName = parsed.AgentName
 	}
 
+	// Agents seen before this sync; the rest are new
+	known := existingAgents(parsed.SessionID)
+
 	// Upsert Team
 	team := models.Team{
 		ID:          parsed.SessionID,
 		Name:        displayName,
 		Description: fmt.Sprintf("Claude Code session: %s", parsed.Slug),
 		CreatedBy:   "claude-code",
-		Status:      status,
+		Status:      agentstate.TeamIdle, // derived from the agents below
 		TeamName:    parsed.TeamName,
 		CreatedAt:   parsed.StartedAt,
 	}
 	if err := db.DB.Clauses(clause.OnConflict{
 		Columns:   []clause.Column{{Name: "id"}},
-		DoUpdates: clause.AssignmentColumns([]string{"name", "description", "status", "team_name"}),
+		DoUpdates: clause.AssignmentColumns([]string{"name", "description", "team_name"}),
 	}).Create(&team).Error; err != nil {
 		return fmt.Errorf("failed to upsert team %s: %w", parsed.SessionID, err)
 	}
 
 	// Create or update lead agent (main session)
-	leadAgentID := parsed.SessionID + "-lead"
+	leadAgentID := LeadAgentID(parsed.SessionID)
 	leadAgentName := agentDisplayName(parsed.Slug, "lead")
 	if parsed.AgentName != "" {
 		leadAgentName = parsed.AgentName
 	}
+	leadStatus, leadLast := agentstate.Derive(parsed.MainMessages, false)
 	leadAgent := models.Agent{
 		ID:        leadAgentID,
 		TeamID:    parsed.SessionID,
 		Role:      "lead",
 		Name:      leadAgentName,
 		Specialty: "Main Claude Code session",
-		Status:    agentStatus(parsed.MainMessages),
+		Status:    agentstate.WithStaleness(leadStatus, leadLast, time.Now()),
 		CreatedAt: parsed.StartedAt,
 	}
 	if err := db.DB.Clauses(clause.OnConflict{
 		Columns:   []clause.Column{{Name: "id"}},
-		DoUpdates: clause.AssignmentColumns([]string{"name", "status"}),
+		DoUpdates: clause.AssignmentColumns([]string{"name"}),
 	}).Create(&leadAgent).Error; err != nil {
 		return fmt.Errorf("failed to upsert lead agent: %w", err)
 	}
+	if err := agentstate.UpdateAgent(leadAgentID, leadStatus, leadLast, false); err != nil {
+		log.Printf("Warning: failed to update status of lead agent %s: %v", leadAgentID, err)
+	}
 
 	// Create or update subagent records
+	var spawned []SubagentSpawn
 	for _, sa := range parsed.SubAgents {
+		subStatus, subLast := agentstate.Derive(sa.Messages, true)
 		subAgent := models.Agent{
 			ID:        sa.AgentID,
 			TeamID:    parsed.SessionID,
 			Role:      "teammate",
 			Name:      agentDisplayName(sa.Slug, sa.AgentID),
 			Specialty: fmt.Sprintf("Sub-agent %s", sa.AgentID),
-			Status:    agentStatus(sa.Messages),
+			Status:    agentstate.WithStaleness(subStatus, subLast, time.Now()),
 			CreatedAt: agentStartTime(sa.Messages, parsed.StartedAt),
 		}
 		if err := db.DB.Clauses(clause.OnConflict{
 			Columns:   []clause.Column{{Name: "id"}},
-			DoUpdates: clause.AssignmentColumns([]string{"name", "status"}),
+			DoUpdates: clause.AssignmentColumns([]string{"name"}),
 		}).Create(&subAgent).Error; err != nil {
 			log.Printf("Warning: failed to upsert subagent %s: %v", sa.AgentID, err)
+			continue
+		}
+		if !known[sa.AgentID] {
+			spawned = append(spawned, SubagentSpawn{
+				TeamID:    parsed.SessionID,
+				AgentID:   sa.AgentID,
+				Name:      subAgent.Name,
+				StartedAt: subAgent.CreatedAt,
+			})
+		}
+		if err := agentstate.UpdateAgent(sa.AgentID, subStatus, subLast, false); err != nil {
+			log.Printf("Warning: failed to update status of subagent %s: %v", sa.AgentID, err)
 		}
 	}
 
+	if err := agentstate.RefreshTeam(parsed.SessionID); err != nil {
+		log.Printf("Warning: failed to refresh status of team %s: %v", parsed.SessionID, err)
+	}
+
 	// Create the main conversation (one per session)
-	convID := parsed.SessionID + "-conv"
+	convID := ConversationID(parsed.SessionID)
 	conv := models.Conversation{
-		ID:        convID,
-		TeamID:    parsed.SessionID,
-		AgentID:   leadAgentID,
-		Title:     parsed.Slug,
-		StartedAt: parsed.StartedAt,
+		ID:           convID,
+		TeamID:       parsed.SessionID,
+		AgentID:      leadAgentID,
+		Title:        parsed.Slug,
+		StartedAt:    parsed.StartedAt,
+		StartPending: true, // only taken by new rows; see announceStart
 	}
 	if !parsed.EndedAt.IsZero() {
 		endedAt := parsed.EndedAt
@@ -116,6 +142,22 @@ func SyncSession(parsed *parser.ParsedSession) error {
 	}).Create(&conv).Error; err != nil {
 		return fmt.Errorf("failed to upsert conversation: %w", err)
 	}
+	if announceStart(convID) {
+		events.Publish(events.SessionStarted, SessionStart{
+			TeamID:         parsed.SessionID,
+			TeamName:       parsed.TeamName,
+			ConversationID: convID,
+			Slug:           parsed.Slug,
+			StartedAt:      parsed.StartedAt,
+		})
+	}
+	for _, s := range spawned {
+		events.Publish(events.SubagentSpawned, s)
+	}
+
+	// Tool spans recorded live by the hooks receiver have precise timings
+	// that the transcript doesn't, so carry them over the re-insert below.
+	hookSpans := hookSpans(convID)
 
 	// Sync messages: delete existing and re-insert for idempotency
 	// All messages (lead + subagents) go into a single unified conversation.
@@ -127,11 +169,81 @@ func SyncSession(parsed *parser.ParsedSession) error {
 	}
 
 	// Sync main session messages into the unified conversation
-	syncMessages(parsed.MainMessages, parsed.SessionID, convID, leadAgentID, "", "")
+	syncMessages(parsed.MainMessages, parsed.SessionID, convID, leadAgentID, "", "", hookSpans)
 
 	// Sync subagent messages into the same unified conversation
 	for _, sa := range parsed.SubAgents {
-		syncMessages(sa.Messages, parsed.SessionID, convID, sa.AgentID, sa.AgentID, leadAgentID)
+		syncMessages(sa.Messages, parsed.SessionID, convID, sa.AgentID, sa.AgentID, leadAgentID, hookSpans)
+	}
+
+	// Rebuild the per-turn token usage series
+	compacted := compactedTurns(convID)
+	if err := db.DB.Where("conversation_id = ?", convID).Delete(&models.TurnUsage{}).Error; err != nil {
+		log.Printf("Warning: failed to clear old turn usage for conversation %s: %v", convID, err)
+	}
+	usage := turnUsages(parsed.MainMessages, parsed.SessionID, convID, leadAgentID)
+	for _, sa := range parsed.SubAgents {
+		usage = append(usage, turnUsages(sa.Messages, parsed.SessionID, convID, sa.AgentID)...)
+	}
+	batchInsert(usage, "turn usage", func(u models.TurnUsage) string { return u.ID })
+	publishCompactions(usage, compacted)
+
+	// Rebuild the files-touched index
+	if err := db.DB.Where("conversation_id = ?", convID).Delete(&models.FileTouch{}).Error; err != nil {
+		log.Printf("Warning: failed to clear old file touches for conversation %s: %v", convID, err)
+	}
+	touches := fileTouches(parsed.MainMessages, parsed.SessionID, convID, leadAgentID)
+	for _, sa := range parsed.SubAgents {
+		touches = append(touches, fileTouches(sa.Messages, parsed.SessionID, convID, sa.AgentID)...)
+	}
+	batchInsert(touches, "file touch", func(t models.FileTouch) string { return t.ID })
+	if err := analyzer.DetectConflicts(parsed.SessionID); err != nil {
+		log.Printf("Warning: failed to detect file conflicts for team %s: %v", parsed.SessionID, err)
+	}
+
+	// Rebuild the command audit log
+	if err := db.DB.Where("conversation_id = ?", convID).Delete(&models.CommandAudit{}).Error; err != nil {
+		log.Printf("Warning: failed to clear old command audits for conversation %s: %v", convID, err)
+	}
+	audits := commandAudits(parsed.MainMessages, parsed.SessionID, convID, leadAgentID)
+	for _, sa := range parsed.SubAgents {
+		audits = append(audits, commandAudits(sa.Messages, parsed.SessionID, convID, sa.AgentID)...)
+	}
+	batchInsert(audits, "command audit", func(a models.CommandAudit) string { return a.ID })
+
+	// Rebuild the test runs
+	if err := db.DB.Where("conversation_id = ?", convID).Delete(&models.TestRun{}).Error; err != nil {
+		log.Printf("Warning: failed to clear old test runs for conversation %s: %v", convID, err)
+	}
+	runs := testRuns(parsed.MainMessages, parsed.SessionID, convID, leadAgentID)
+	for _, sa := range parsed.SubAgents {
+		runs = append(runs, testRuns(sa.Messages, parsed.SessionID, convID, sa.AgentID)...)
+	}
+	batchInsert(runs, "test run", func(r models.TestRun) string { return r.ID })
+	if err := analyzer.ClassifyOutcome(convID, parsed.MainMessages); err != nil {
+		log.Printf("Warning: failed to classify outcome of conversation %s: %v", convID, err)
+	}
+
+	// Rebuild the messages agents passed to each other
+	if err := db.DB.Where("conversation_id = ?", convID).Delete(&models.Interaction{}).Error; err != nil {
+		log.Printf("Warning: failed to clear old interactions for conversation %s: %v", convID, err)
+	}
+	batchInsert(interactions(parsed, convID, leadAgentID), "interaction", func(i models.Interaction) string { return i.ID })
+
+	// Rebuild the todo list snapshots
+	if err := db.DB.Where("conversation_id = ?", convID).Delete(&models.TodoSnapshot{}).Error; err != nil {
+		log.Printf("Warning: failed to clear old todo snapshots for conversation %s: %v", convID, err)
+	}
+	todos := todoSnapshots(parsed.MainMessages, parsed.SessionID, convID, leadAgentID)
+	for _, sa := range parsed.SubAgents {
+		todos = append(todos, todoSnapshots(sa.Messages, parsed.SessionID, convID, sa.AgentID)...)
+	}
+	batchInsert(todos, "todo snapshot", func(t models.TodoSnapshot) string { return t.ID })
+
+	// Restore hook spans for tool calls that haven't reached the transcript yet.
+	restoreHookSpans(hookSpans)
+	if err := analyzer.DetectLoops(convID); err != nil {
+		log.Printf("Warning: failed to detect loops in conversation %s: %v", convID, err)
 	}
 
 	// Clean up old per-agent conversations from previous schema
@@ -143,10 +255,20 @@ func SyncSession(parsed *parser.ParsedSession) error {
 	return nil
 }
 
+// LeadAgentID returns the ID of the lead agent synced for a session.
+func LeadAgentID(sessionID string) string {
+	return sessionID + "-lead"
+}
+
+// ConversationID returns the ID of the unified conversation synced for a session.
+func ConversationID(sessionID string) string {
+	return sessionID + "-conv"
+}
+
 // syncMessages converts ParsedMessages to database Message and Trace records.
 // leadAgentID is set when syncing subagent conversations so that "user" messages
 // (which are actually from the lead agent) can be stored as "teammate_message".
-func syncMessages(messages []parser.ParsedMessage, teamID, convID, defaultAgentID, agentIDForTraces, leadAgentID string) {
+func syncMessages(messages []parser.ParsedMessage, teamID, convID, defaultAgentID, agentIDForTraces, leadAgentID string, hookSpans map[string]models.Trace) {
 	var dbMessages []models.Message
 	var dbTraces []models.Trace
 
@@ -230,21 +352,69 @@ func syncMessages(messages []parser.ParsedMessage, teamID, convID, defaultAgentI
 		}
 
 		for _, tc := range msg.ToolCalls {
-			traceID := uuid.New().String()
+			// Use the tool_use ID so spans recorded live by hooks and spans
+			// synced from the transcript refer to the same record.
+			traceID := tc.ID
+			if traceID == "" {
+				traceID = uuid.New().String()
+			}
 			attrs := map[string]interface{}{
 				"tool_name": tc.Name,
 				"input":     tc.Input,
 			}
+			if msg.Model != "" {
+				attrs["model"] = msg.Model
+			}
 			if tc.Result != "" {
-				result := tc.Result
-				if len(result) > 5000 {
-					result = result[:5000] + "...(已截断)"
+				attrs["result"] = truncateResult(tc.Result, 5000)
+			}
+
+			startTime := timestamp
+			var endTimePtr *time.Time // in flight until the result arrives
+			status, statusMessage := models.SpanStatusUnset, ""
+			if tc.HasResult {
+				endTime := tc.ResultAt
+				if endTime.Before(startTime) {
+					endTime = startTime
+				}
+				endTimePtr = &endTime
+				status = models.SpanStatusOK
+				if tc.IsError {
+					status = models.SpanStatusError
+					statusMessage = truncateResult(tc.Result, 500)
+					attrs["error_category"] = toolerrors.Classify(tc.Name, tc.Result)
+				}
+			}
+			if hookSpan, ok := hookSpans[traceID]; ok {
+				startTime = hookSpan.StartTime
+				// PostToolUse doesn't fire for failed or denied calls, so
+				// their end comes from the transcript.
+				if hookSpan.EndTime != nil {
+					endTimePtr = hookSpan.EndTime
+				} else if endTimePtr != nil && endTimePtr.Before(startTime) {
+					endTimePtr = &startTime
+				}
+				attrs["source"] = "hook"
+			}
+
+			// Normalized per-tool attributes; they never replace the ones above.
+			call := toolattrs.Call{
+				Name:      tc.Name,
+				Input:     tc.Input,
+				Result:    tc.Result,
+				HasResult: endTimePtr != nil,
+				IsError:   tc.IsError,
+			}
+			if endTimePtr != nil {
+				call.Duration = endTimePtr.Sub(startTime)
+			}
+			for k, v := range toolattrs.Extract(call) {
+				if _, ok := attrs[k]; !ok {
+					attrs[k] = v
 				}
-				attrs["result"] = result
 			}
 
 			attrsJSON, _ := json.Marshal(attrs)
-			endTime := timestamp.Add(time.Second) // Approximate end time
 
 			trace := models.Trace{
 				ID:             traceID,
@@ -253,8 +423,10 @@ func syncMessages(messages []parser.ParsedMessage, teamID, convID, defaultAgentI
 				ConversationID: convID,
 				SpanName:       "tool." + tc.Name,
 				Attributes:     datatypes.JSON(attrsJSON),
-				StartTime:      timestamp,
-				EndTime:        &endTime,
+				StartTime:      startTime,
+				EndTime:        endTimePtr,
+				Status:         status,
+				StatusMessage:  statusMessage,
 			}
 			dbTraces = append(dbTraces, trace)
 		}
@@ -292,44 +464,40 @@ func syncMessages(messages []parser.ParsedMessage, teamID, convID, defaultAgentI
 		}
 	}
 
-	// Batch insert messages
-	if len(dbMessages) > 0 {
-		batchSize := 100
-		for i := 0; i < len(dbMessages); i += batchSize {
-			end := i + batchSize
-			if end > len(dbMessages) {
-				end = len(dbMessages)
-			}
-			if err := db.DB.Session(&gorm.Session{CreateBatchSize: batchSize}).Create(dbMessages[i:end]).Error; err != nil {
-				log.Printf("Warning: failed to batch insert messages (batch %d-%d): %v", i, end, err)
-				// Try inserting one by one as fallback
-				for _, m := range dbMessages[i:end] {
-					if err := db.DB.Create(&m).Error; err != nil {
-						log.Printf("Warning: failed to insert message %s: %v", m.ID, err)
-					}
+	batchInsert(dbMessages, "message", func(m models.Message) string { return m.ID })
+	batchInsert(dbTraces, "trace", func(t models.Trace) string { return t.ID })
+}
+
+// batchInsert inserts rows in batches, falling back to one-by-one inserts
+// for a batch that fails so a single bad row doesn't lose its neighbours.
+func batchInsert[T any](rows []T, kind string, id func(T) string) {
+	const batchSize = 100
+	for i := 0; i < len(rows); i += batchSize {
+		end := i + batchSize
+		if end > len(rows) {
+			end = len(rows)
+		}
+		if err := db.DB.Session(&gorm.Session{CreateBatchSize: batchSize}).Create(rows[i:end]).Error; err != nil {
+			log.Printf("Warning: failed to batch insert %ss (batch %d-%d): %v", kind, i, end, err)
+			for _, row := range rows[i:end] {
+				if err := db.DB.Create(&row).Error; err != nil {
+					log.Printf("Warning: failed to insert %s %s: %v", kind, id(row), err)
 				}
 			}
 		}
 	}
+}
 
-	// Batch insert traces
-	if len(dbTraces) > 0 {
-		batchSize := 100
-		for i := 0; i < len(dbTraces); i += batchSize {
-			end := i + batchSize
-			if end > len(dbTraces) {
-				end = len(dbTraces)
-			}
-			if err := db.DB.Session(&gorm.Session{CreateBatchSize: batchSize}).Create(dbTraces[i:end]).Error; err != nil {
-				log.Printf("Warning: failed to batch insert traces (batch %d-%d): %v", i, end, err)
-				for _, t := range dbTraces[i:end] {
-					if err := db.DB.Create(&t).Error; err != nil {
-						log.Printf("Warning: failed to insert trace %s: %v", t.ID, err)
-					}
-				}
-			}
-		}
+// truncateResult shortens a tool result to at most n bytes, marking it as
+// cut, without splitting a UTF-8 character.
+func truncateResult(result string, n int) string {
+	if len(result) <= n {
+		return result
 	}
+	for n > 0 && !utf8.RuneStart(result[n]) {
+		n--
+	}
+	return result[:n] + "...(已截断)"
 }
 
 // buildRawThoughts creates the raw_thoughts JSON structure for an assistant message.
@@ -350,11 +518,7 @@ func buildRawThoughts(msg parser.ParsedMessage) map[string]interface{} {
 				"input": tc.Input,
 			}
 			if tc.Result != "" {
-				result := tc.Result
-				if len(result) > 5000 {
-					result = result[:5000] + "...(已截断)"
-				}
-				call["result"] = result
+				call["result"] = truncateResult(tc.Result, 5000)
 			}
 			calls = append(calls, call)
 		}
@@ -392,16 +556,36 @@ func agentDisplayName(slug, fallback string) string {
 	return name
 }
 
-// agentStatus determines agent status based on the most recent message time.
-func agentStatus(messages []parser.ParsedMessage) string {
-	if len(messages) == 0 {
-		return "idle"
+// hookSpans returns the spans in a conversation that were recorded live by
+// the hooks receiver, keyed by span ID.
+func hookSpans(convID string) map[string]models.Trace {
+	var traces []models.Trace
+	if err := db.DB.Where("conversation_id = ? AND json_extract(attributes, '$.source') = ?", convID, "hook").
+		Find(&traces).Error; err != nil {
+		log.Printf("Warning: failed to load hook spans for conversation %s: %v", convID, err)
 	}
-	lastMsg := messages[len(messages)-1]
-	if !lastMsg.Timestamp.IsZero() && time.Since(lastMsg.Timestamp) < 5*time.Minute {
-		return "active"
+
+	spans := make(map[string]models.Trace, len(traces))
+	for _, t := range traces {
+		spans[t.ID] = t
+	}
+	return spans
+}
+
+// restoreHookSpans re-inserts in-flight hook spans that the transcript sync
+// didn't recreate. Spans it did recreate are left alone. Finished spans are
+// dropped: if the transcript doesn't have them under the same ID they came
+// from a Claude Code version without tool_use_id and the transcript's own
+// span replaces them.
+func restoreHookSpans(spans map[string]models.Trace) {
+	for _, t := range spans {
+		if t.EndTime != nil {
+			continue
+		}
+		if err := db.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&t).Error; err != nil {
+			log.Printf("Warning: failed to restore hook span %s: %v", t.ID, err)
+		}
 	}
-	return "idle"
 }
 
 // agentStartTime returns the earliest timestamp from the agent's messages.
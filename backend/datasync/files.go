@@ -0,0 +1,88 @@
+package datasync
+
+import (
+	"encoding/json"
+	"path/filepath"
+
+	"agent-observer/models"
+	"agent-observer/parser"
+
+	"gorm.io/datatypes"
+)
+
+// fileTouches builds the files-touched index for an agent's transcript from
+// its Read, Write, Edit, MultiEdit and NotebookEdit calls.
+func fileTouches(messages []parser.ParsedMessage, teamID, convID, agentID string) []models.FileTouch {
+	var touches []models.FileTouch
+	for _, msg := range messages {
+		if msg.Role != "assistant" {
+			continue
+		}
+		for _, tc := range msg.ToolCalls {
+			if tc.ID == "" {
+				continue
+			}
+			touch := models.FileTouch{
+				ID:             tc.ID,
+				TeamID:         teamID,
+				AgentID:        agentID,
+				ConversationID: convID,
+				MessageID:      msg.UUID,
+				Failed:         tc.IsError,
+				CreatedAt:      msg.Timestamp,
+			}
+
+			path, _ := tc.Input["file_path"].(string)
+			switch tc.Name {
+			case "Read":
+				touch.Operation = models.FileOpRead
+				touch.Result = tc.Result
+			case "Write":
+				touch.Operation = models.FileOpWrite
+				touch.Content, _ = tc.Input["content"].(string)
+				touch.Result = tc.Result
+			case "Edit":
+				touch.Operation = models.FileOpEdit
+				touch.Edits = marshalEdits([]models.FileEdit{fileEdit(tc.Input)})
+			case "MultiEdit":
+				touch.Operation = models.FileOpEdit
+				var edits []models.FileEdit
+				list, _ := tc.Input["edits"].([]interface{})
+				for _, e := range list {
+					if m, ok := e.(map[string]interface{}); ok {
+						edits = append(edits, fileEdit(m))
+					}
+				}
+				touch.Edits = marshalEdits(edits)
+			case "NotebookEdit":
+				touch.Operation = models.FileOpNotebookEdit
+				path, _ = tc.Input["notebook_path"].(string)
+				touch.Content, _ = tc.Input["new_source"].(string)
+			default:
+				continue
+			}
+			if path == "" {
+				continue
+			}
+			touch.FilePath = filepath.Clean(path)
+			touches = append(touches, touch)
+		}
+	}
+	return touches
+}
+
+func fileEdit(input map[string]interface{}) models.FileEdit {
+	var e models.FileEdit
+	e.OldString, _ = input["old_string"].(string)
+	e.NewString, _ = input["new_string"].(string)
+	e.ReplaceAll, _ = input["replace_all"].(bool)
+	return e
+}
+
+func marshalEdits(edits []models.FileEdit) datatypes.JSON {
+	b, err := json.Marshal(edits)
+	if err != nil {
+		return nil
+	}
+	return datatypes.JSON(b)
+}
@@ -0,0 +1,152 @@
+package datasync
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"agent-observer/models"
+	"agent-observer/parser"
+)
+
+// interactions builds the messages a session's agents passed to each
+// other: Task delegations to sub-agents, the results they returned, and
+// SendMessage calls to teammates. SendMessage recipients are names that
+// are resolved when the graph is built, since teammates run in other
+// sessions.
+func interactions(parsed *parser.ParsedSession, convID, leadAgentID string) []models.Interaction {
+	type subagent struct {
+		id      string
+		prompt  string
+		started time.Time
+	}
+	subagents := make([]subagent, len(parsed.SubAgents))
+	for i, sa := range parsed.SubAgents {
+		subagents[i] = subagent{id: sa.AgentID, started: agentStartTime(sa.Messages, parsed.StartedAt)}
+		for _, msg := range sa.Messages {
+			if msg.Role == "user" && msg.Content != "" {
+				subagents[i].prompt = strings.TrimSpace(msg.Content)
+				break
+			}
+		}
+	}
+	claimed := make(map[string]bool)
+
+	// spawned finds the sub-agent a Task call started: the one given the
+	// same prompt, or else the first to start after the call.
+	spawned := func(caller, prompt string, at time.Time) string {
+		prompt = strings.TrimSpace(prompt)
+		for _, sa := range subagents {
+			if !claimed[sa.id] && sa.id != caller && prompt != "" && sa.prompt == prompt {
+				claimed[sa.id] = true
+				return sa.id
+			}
+		}
+		found := ""
+		var first time.Time
+		for _, sa := range subagents {
+			if !claimed[sa.id] && sa.id != caller && !sa.started.Before(at) && (found == "" || sa.started.Before(first)) {
+				found, first = sa.id, sa.started
+			}
+		}
+		if found != "" {
+			claimed[found] = true
+		}
+		return found
+	}
+
+	type transcript struct {
+		agentID  string
+		messages []parser.ParsedMessage
+	}
+	transcripts := []transcript{{leadAgentID, parsed.MainMessages}}
+	for _, sa := range parsed.SubAgents {
+		transcripts = append(transcripts, transcript{sa.AgentID, sa.Messages})
+	}
+
+	var result []models.Interaction
+	for _, t := range transcripts {
+		for _, msg := range t.messages {
+			if msg.Role != "assistant" {
+				continue
+			}
+			for _, tc := range msg.ToolCalls {
+				if tc.ID == "" {
+					continue
+				}
+				base := models.Interaction{
+					TeamID:         parsed.SessionID,
+					ConversationID: convID,
+					FromAgentID:    t.agentID,
+					SpanID:         tc.ID,
+					CreatedAt:      msg.Timestamp,
+				}
+
+				switch tc.Name {
+				case "Task", "Agent":
+					prompt, _ := tc.Input["prompt"].(string)
+					d := base
+					d.ID = tc.ID + ":" + models.InteractionDelegation
+					d.Kind = models.InteractionDelegation
+					d.ToAgentID = spawned(t.agentID, prompt, msg.Timestamp)
+					if d.ToAgentID == "" {
+						d.Recipient, _ = tc.Input["subagent_type"].(string)
+					}
+					d.Tokens = estimateTokens(prompt)
+					result = append(result, d)
+
+					if d.ToAgentID != "" && tc.HasResult && !tc.IsError {
+						r := base
+						r.ID = tc.ID + ":" + models.InteractionResult
+						r.Kind = models.InteractionResult
+						r.FromAgentID, r.ToAgentID = d.ToAgentID, t.agentID
+						r.Tokens = estimateTokens(tc.Result)
+						if !tc.ResultAt.IsZero() {
+							r.CreatedAt = tc.ResultAt
+						}
+						result = append(result, r)
+					}
+
+				case "SendMessage":
+					recipient := inputString(tc.Input, "recipient", "to")
+					if kind, _ := tc.Input["type"].(string); kind == "broadcast" {
+						recipient = "*"
+					}
+					if recipient == "" {
+						continue
+					}
+					m := base
+					m.ID = tc.ID + ":" + models.InteractionMessage
+					m.Kind = models.InteractionMessage
+					m.Recipient = recipient
+					m.Tokens = estimateTokens(inputString(tc.Input, "content", "message"))
+					result = append(result, m)
+				}
+			}
+		}
+	}
+	return result
+}
+
+// inputString returns the first of keys present in a tool input, as a
+// string; non-string values are returned as JSON.
+func inputString(input map[string]interface{}, keys ...string) string {
+	for _, k := range keys {
+		switch v := input[k].(type) {
+		case nil:
+			continue
+		case string:
+			return v
+		default:
+			b, _ := json.Marshal(v)
+			return string(b)
+		}
+	}
+	return ""
+}
+
+// estimateTokens approximates the tokens in text at four characters each.
+func estimateTokens(text string) int {
+	return (utf8.RuneCountInString(text) + 3) / 4
+}
@@ -0,0 +1,91 @@
+package datasync
+
+import (
+	"time"
+
+	"agent-observer/db"
+	"agent-observer/events"
+	"agent-observer/models"
+)
+
+// SessionStart is published as events.SessionStarted the first time a
+// session's transcript is synced.
+type SessionStart struct {
+	TeamID         string    `json:"team_id"`
+	TeamName       string    `json:"team_name"`
+	ConversationID string    `json:"conversation_id"`
+	Slug           string    `json:"slug"`
+	StartedAt      time.Time `json:"started_at"`
+}
+
+// SubagentSpawn is published as events.SubagentSpawned the first time a
+// sub-agent is synced.
+type SubagentSpawn struct {
+	TeamID    string    `json:"team_id"`
+	AgentID   string    `json:"agent_id"`
+	Name      string    `json:"name"`
+	StartedAt time.Time `json:"started_at"`
+}
+
+// Compaction is published as events.ContextCompacted for each newly synced
+// turn that follows a compaction of an agent's context.
+type Compaction struct {
+	TeamID         string    `json:"team_id"`
+	AgentID        string    `json:"agent_id"`
+	ConversationID string    `json:"conversation_id"`
+	TurnID         string    `json:"turn_id"`
+	ContextTokens  int       `json:"context_tokens"`  // context size after compacting
+	PreviousTokens int       `json:"previous_tokens"` // context size of the turn before, 0 if unknown
+	At             time.Time `json:"at"`
+}
+
+// announceStart clears a conversation's StartPending flag and reports
+// whether it was set, so SessionStarted is published once per session even
+// when the hooks receiver created the conversation first.
+func announceStart(convID string) bool {
+	result := db.DB.Model(&models.Conversation{}).Where("id = ? AND start_pending = ?", convID, true).Update("start_pending", false)
+	return result.Error == nil && result.RowsAffected > 0
+}
+
+// existingAgents returns the IDs of a team's synced agents.
+func existingAgents(teamID string) map[string]bool {
+	var ids []string
+	db.DB.Model(&models.Agent{}).Where("team_id = ?", teamID).Pluck("id", &ids)
+	known := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		known[id] = true
+	}
+	return known
+}
+
+// compactedTurns returns the IDs of a conversation's turns that followed a
+// compaction.
+func compactedTurns(convID string) map[string]bool {
+	var ids []string
+	db.DB.Model(&models.TurnUsage{}).Where("conversation_id = ? AND compacted = ?", convID, true).Pluck("id", &ids)
+	known := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		known[id] = true
+	}
+	return known
+}
+
+// publishCompactions publishes the compactions in usage that weren't among
+// the known compacted turns.
+func publishCompactions(usage []models.TurnUsage, known map[string]bool) {
+	previous := make(map[string]int)
+	for _, u := range usage {
+		if u.Compacted && !known[u.ID] {
+			events.Publish(events.ContextCompacted, Compaction{
+				TeamID:         u.TeamID,
+				AgentID:        u.AgentID,
+				ConversationID: u.ConversationID,
+				TurnID:         u.ID,
+				ContextTokens:  u.ContextTokens,
+				PreviousTokens: previous[u.AgentID],
+				At:             u.CreatedAt,
+			})
+		}
+		previous[u.AgentID] = u.ContextTokens
+	}
+}
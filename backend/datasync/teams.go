@@ -0,0 +1,169 @@
+package datasync
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"agent-observer/db"
+	"agent-observer/models"
+	"agent-observer/parser"
+
+	"github.com/google/uuid"
+)
+
+// SyncTeamFiles syncs a Claude Code agent team's config and inboxes, and
+// the task list of the same name, from the default directories. name may
+// also be a task list that belongs to no team.
+func SyncTeamFiles(name string) error {
+	return SyncTeamFilesFromDirs(parser.ClaudeTeamsDir, parser.ClaudeTasksDir, name)
+}
+
+// SyncTeamFilesFromDirs syncs a team and task list from specific
+// directories. Stored rows are only replaced once the files parse. Claude
+// Code deletes a team's directories when it cleans the team up; its
+// members, inbox and tasks are then kept, and the team marked ended.
+func SyncTeamFilesFromDirs(teamsDir, tasksDir, name string) error {
+	team, err := parser.ParseTeamInDir(teamsDir, name)
+	if err != nil {
+		return err
+	}
+	if team != nil {
+		syncTeamConfig(team)
+	} else {
+		// The team was deleted, or name is only a task list.
+		markTeamEnded(name)
+	}
+
+	if _, err := os.Stat(filepath.Join(tasksDir, name)); os.IsNotExist(err) {
+		return nil
+	}
+	tasks, err := parser.ParseTasksInDir(tasksDir, name)
+	if err != nil {
+		return err
+	}
+	if err := db.DB.Where("list_id = ?", name).Delete(&models.TeamTask{}).Error; err != nil {
+		log.Printf("Warning: failed to clear old tasks of list %s: %v", name, err)
+	}
+	rows := make([]models.TeamTask, 0, len(tasks))
+	for _, t := range tasks {
+		blocks, _ := json.Marshal(nonNil(t.Blocks))
+		blockedBy, _ := json.Marshal(nonNil(t.BlockedBy))
+		rows = append(rows, models.TeamTask{
+			ID:          name + "/" + t.ID,
+			ListID:      name,
+			TaskID:      t.ID,
+			Subject:     t.Subject,
+			Description: t.Description,
+			ActiveForm:  t.ActiveForm,
+			Status:      t.Status,
+			Owner:       t.Owner,
+			Blocks:      blocks,
+			BlockedBy:   blockedBy,
+			UpdatedAt:   t.UpdatedAt,
+		})
+	}
+	batchInsert(rows, "team task", func(t models.TeamTask) string { return t.ID })
+	return nil
+}
+
+// syncTeamConfig replaces a team's members and inbox messages.
+func syncTeamConfig(team *parser.ParsedTeam) {
+	clearTeamConfig(team.Name)
+	members := make([]models.TeamMember, 0, len(team.Members))
+	for _, m := range team.Members {
+		member := models.TeamMember{
+			ID:        team.Name + "/" + m.Name,
+			TeamName:  team.Name,
+			Name:      m.Name,
+			MemberID:  m.AgentID,
+			AgentType: m.AgentType,
+			Model:     m.Model,
+			Color:     m.Color,
+			Cwd:       m.Cwd,
+			IsLead:    m.AgentID != "" && m.AgentID == team.LeadAgentID,
+			JoinedAt:  m.JoinedAt,
+		}
+		if member.IsLead {
+			member.SessionID = team.LeadSessionID
+		}
+		members = append(members, member)
+	}
+	batchInsert(members, "team member", func(m models.TeamMember) string { return m.ID })
+
+	inbox := make([]models.InboxMessage, 0, len(team.Inbox))
+	for _, m := range team.Inbox {
+		// Inboxes only grow, so a message keeps its position.
+		key := team.Name + "\x00" + m.Recipient + "\x00" + strconv.Itoa(m.Index)
+		inbox = append(inbox, models.InboxMessage{
+			ID:        uuid.NewSHA1(uuid.NameSpaceURL, []byte(key)).String(),
+			TeamName:  team.Name,
+			Recipient: m.Recipient,
+			Sender:    m.From,
+			Kind:      m.Kind,
+			Text:      m.Text,
+			Summary:   m.Summary,
+			Read:      m.Read,
+			CreatedAt: m.Timestamp,
+		})
+	}
+	batchInsert(inbox, "inbox message", func(m models.InboxMessage) string { return m.ID })
+}
+
+// markTeamEnded records that a team's directory is gone.
+func markTeamEnded(name string) {
+	if err := db.DB.Model(&models.TeamMember{}).Where("team_name = ? AND ended_at IS NULL", name).
+		Update("ended_at", time.Now()).Error; err != nil {
+		log.Printf("Warning: failed to mark team %s ended: %v", name, err)
+	}
+}
+
+// clearTeamConfig deletes a team's members and inbox messages.
+func clearTeamConfig(name string) {
+	if err := db.DB.Where("team_name = ?", name).Delete(&models.TeamMember{}).Error; err != nil {
+		log.Printf("Warning: failed to clear old members of team %s: %v", name, err)
+	}
+	if err := db.DB.Where("team_name = ?", name).Delete(&models.InboxMessage{}).Error; err != nil {
+		log.Printf("Warning: failed to clear old inbox messages of team %s: %v", name, err)
+	}
+}
+
+// SyncAllTeams syncs every team and task list in the default directories.
+func SyncAllTeams() error {
+	return SyncAllTeamsFromDirs(parser.ClaudeTeamsDir, parser.ClaudeTasksDir)
+}
+
+// SyncAllTeamsFromDirs syncs every team and task list in specific directories.
+func SyncAllTeamsFromDirs(teamsDir, tasksDir string) error {
+	teams, err := parser.ScanDirsIn(teamsDir)
+	if err != nil {
+		return fmt.Errorf("failed to scan teams: %w", err)
+	}
+	lists, err := parser.ScanDirsIn(tasksDir)
+	if err != nil {
+		return fmt.Errorf("failed to scan task lists: %w", err)
+	}
+
+	seen := make(map[string]bool)
+	for _, name := range append(teams, lists...) {
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		if err := SyncTeamFilesFromDirs(teamsDir, tasksDir, name); err != nil {
+			log.Printf("Warning: failed to sync team files %s: %v", name, err)
+		}
+	}
+	return nil
+}
+
+func nonNil(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
@@ -0,0 +1,80 @@
+package datasync
+
+import (
+	"encoding/json"
+
+	"agent-observer/models"
+	"agent-observer/parser"
+	"agent-observer/testruns"
+	"agent-observer/toolattrs"
+)
+
+// testRuns finds the Bash calls in an agent's transcript that ran tests
+// and parses their results.
+func testRuns(messages []parser.ParsedMessage, teamID, convID, agentID string) []models.TestRun {
+	var runs []models.TestRun
+	for _, msg := range messages {
+		if msg.Role != "assistant" {
+			continue
+		}
+		for _, tc := range msg.ToolCalls {
+			if tc.ID == "" || tc.Name != "Bash" {
+				continue
+			}
+			command, _ := tc.Input["command"].(string)
+			framework := testruns.Detect(command)
+			if framework == "" {
+				continue
+			}
+			run := models.TestRun{
+				ID:             tc.ID,
+				TeamID:         teamID,
+				AgentID:        agentID,
+				ConversationID: convID,
+				Command:        command,
+				Framework:      framework,
+				Status:         models.TestRunRunning,
+				CreatedAt:      msg.Timestamp,
+			}
+			if tc.HasResult {
+				result, ok := testruns.Parse(framework, tc.Result)
+				exitCode, exitKnown := toolattrs.ExitCode(tc.Result, tc.IsError)
+				if exitKnown {
+					run.ExitCode = &exitCode
+				}
+				if !tc.ResultAt.IsZero() {
+					run.DurationMs = tc.ResultAt.Sub(msg.Timestamp).Milliseconds()
+				}
+				run.Framework = result.Framework
+				run.Passed, run.Failed, run.Skipped = result.Passed, result.Failed, result.Skipped
+				if len(result.Failing) > 0 {
+					run.FailingTests, _ = json.Marshal(result.Failing)
+				}
+				run.Status = testRunStatus(result, ok, tc.IsError)
+			}
+			runs = append(runs, run)
+		}
+	}
+	return runs
+}
+
+// testRunStatus decides a finished run's status from its parsed results,
+// falling back to whether the command failed.
+func testRunStatus(result testruns.Result, parsed, isError bool) string {
+	switch {
+	case result.BuildFailed:
+		return models.TestRunError
+	case result.Failed > 0:
+		return models.TestRunFailed
+	case parsed && !isError:
+		return models.TestRunPassed
+	case isError:
+		// A failing exit with no failed tests in the output: the output was
+		// truncated or the runner itself broke.
+		if parsed {
+			return models.TestRunFailed
+		}
+		return models.TestRunError
+	}
+	return models.TestRunUnknown
+}
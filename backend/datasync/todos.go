@@ -0,0 +1,72 @@
+package datasync
+
+import (
+	"encoding/json"
+
+	"agent-observer/models"
+	"agent-observer/parser"
+)
+
+// todoSnapshots finds the TodoWrite calls in an agent's transcript and
+// numbers the todo lists they wrote. A call that failed changed nothing.
+func todoSnapshots(messages []parser.ParsedMessage, teamID, convID, agentID string) []models.TodoSnapshot {
+	var snapshots []models.TodoSnapshot
+	for _, msg := range messages {
+		if msg.Role != "assistant" {
+			continue
+		}
+		for _, tc := range msg.ToolCalls {
+			if tc.ID == "" || tc.Name != "TodoWrite" || tc.IsError {
+				continue
+			}
+			items, ok := todoItems(tc.Input["todos"])
+			if !ok {
+				continue
+			}
+			snapshot := models.TodoSnapshot{
+				ID:             tc.ID,
+				TeamID:         teamID,
+				AgentID:        agentID,
+				ConversationID: convID,
+				Version:        len(snapshots) + 1,
+				Total:          len(items),
+				CreatedAt:      msg.Timestamp,
+			}
+			snapshot.Items, _ = json.Marshal(items)
+			for _, item := range items {
+				switch item.Status {
+				case models.TodoCompleted:
+					snapshot.Completed++
+				case models.TodoInProgress:
+					snapshot.InProgress++
+				}
+			}
+			snapshots = append(snapshots, snapshot)
+		}
+	}
+	return snapshots
+}
+
+// todoItems reads the todos input of a TodoWrite call.
+func todoItems(input interface{}) ([]models.TodoItem, bool) {
+	if input == nil {
+		return nil, false
+	}
+	data, err := json.Marshal(input)
+	if err != nil {
+		return nil, false
+	}
+	var raw []struct {
+		Content    string `json:"content"`
+		Status     string `json:"status"`
+		ActiveForm string `json:"activeForm"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, false
+	}
+	items := make([]models.TodoItem, len(raw))
+	for i, r := range raw {
+		items[i] = models.TodoItem{Content: r.Content, Status: r.Status, ActiveForm: r.ActiveForm}
+	}
+	return items, true
+}
@@ -0,0 +1,65 @@
+package datasync
+
+import (
+	"agent-observer/modelinfo"
+	"agent-observer/models"
+	"agent-observer/parser"
+)
+
+// turnUsages builds one TurnUsage per model call in an agent's transcript.
+// Claude Code writes a streamed response as several lines sharing the same
+// API message ID; they are counted once, using the last line's usage.
+func turnUsages(messages []parser.ParsedMessage, teamID, convID, agentID string) []models.TurnUsage {
+	var turns []models.TurnUsage
+	compactPending := false
+	lastAPIMsgID := ""
+
+	for _, msg := range messages {
+		if msg.Role == "user" {
+			if msg.Compacted {
+				compactPending = true
+			}
+			continue
+		}
+		if msg.Role != "assistant" || msg.TokenUsage == nil || msg.IsAPIError || msg.UUID == "" {
+			continue
+		}
+		u := msg.TokenUsage
+		context := u.InputTokens + u.CacheCreation + u.CacheRead
+		if context == 0 && u.OutputTokens == 0 {
+			continue
+		}
+
+		turn := models.TurnUsage{
+			ID:             msg.UUID,
+			TeamID:         teamID,
+			AgentID:        agentID,
+			ConversationID: convID,
+			Model:          msg.Model,
+			InputTokens:    u.InputTokens,
+			OutputTokens:   u.OutputTokens,
+			CacheCreation:  u.CacheCreation,
+			CacheRead:      u.CacheRead,
+			ContextTokens:  context,
+			ContextWindow:  modelinfo.ContextWindow(msg.Model),
+			CreatedAt:      msg.Timestamp,
+		}
+
+		if msg.APIMsgID != "" && msg.APIMsgID == lastAPIMsgID && len(turns) > 0 {
+			turn.Compacted = turns[len(turns)-1].Compacted
+			turns[len(turns)-1] = turn
+			continue
+		}
+		lastAPIMsgID = msg.APIMsgID
+
+		// Besides the summary marker, a context that more than halves from
+		// one turn to the next can only mean it was compacted or cleared.
+		if len(turns) > 0 && context < turns[len(turns)-1].ContextTokens/2 {
+			compactPending = true
+		}
+		turn.Compacted = compactPending
+		compactPending = false
+		turns = append(turns, turn)
+	}
+	return turns
+}
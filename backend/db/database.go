@@ -21,16 +21,53 @@ func InitDB() {
 		log.Fatal("Failed to connect to database:", err)
 	}
 
+	// Idempotency keys used to be unique without their kind. They only
+	// matter for a day, so an old table is dropped rather than migrated.
+	if DB.Migrator().HasTable(&models.IdempotencyKey{}) {
+		columns, _ := DB.Migrator().ColumnTypes(&models.IdempotencyKey{})
+		for _, c := range columns {
+			if pk, ok := c.PrimaryKey(); c.Name() == "kind" && ok && !pk {
+				if err := DB.Migrator().DropTable(&models.IdempotencyKey{}); err != nil {
+					log.Fatal("Failed to drop old idempotency keys:", err)
+				}
+			}
+		}
+	}
+
+	// Alerts used to be notified exactly when they weren't silenced.
+	backfillNotified := DB.Migrator().HasTable(&models.Alert{}) && !DB.Migrator().HasColumn(&models.Alert{}, "Notified")
+
 	err = DB.AutoMigrate(
 		&models.Team{},
 		&models.Agent{},
 		&models.Conversation{},
 		&models.Message{},
 		&models.Trace{},
+		&models.IdempotencyKey{},
+		&models.TurnUsage{},
+		&models.FileTouch{},
+		&models.FileConflict{},
+		&models.CommandAudit{},
+		&models.TestRun{},
+		&models.Annotation{},
+		&models.Interaction{},
+		&models.TodoSnapshot{},
+		&models.Alert{},
+		&models.AlertSilence{},
+		&models.Webhook{},
+		&models.WebhookDelivery{},
+		&models.TeamMember{},
+		&models.InboxMessage{},
+		&models.TeamTask{},
 	)
 	if err != nil {
 		log.Fatal("Failed to migrate database:", err)
 	}
+	if backfillNotified {
+		if err := DB.Model(&models.Alert{}).Where("silenced = ?", false).Update("notified", true).Error; err != nil {
+			log.Fatal("Failed to migrate alerts:", err)
+		}
+	}
 
 	log.Println("Database initialized and migrated successfully")
 }
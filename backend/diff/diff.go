@@ -0,0 +1,215 @@
+// Package diff computes line diffs and renders them as unified diff hunks,
+// and reconstructs the changes agents made to files from their Read, Write
+// and Edit tool calls.
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Op kinds.
+const (
+	Equal  = ' '
+	Delete = '-'
+	Insert = '+'
+)
+
+// Op is one line of a line diff.
+type Op struct {
+	Kind byte   `json:"kind"`
+	Line string `json:"line"`
+}
+
+// maxCells bounds the LCS table. Larger inputs (after trimming the common
+// prefix and suffix) are diffed as a whole-block replacement.
+const maxCells = 4_000_000
+
+// Lines diffs two sequences of lines using a longest common subsequence.
+func Lines(a, b []string) []Op {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	ops := make([]Op, 0, len(a)+len(b))
+	for _, l := range a[:prefix] {
+		ops = append(ops, Op{Equal, l})
+	}
+	ops = append(ops, lcs(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+	for _, l := range a[len(a)-suffix:] {
+		ops = append(ops, Op{Equal, l})
+	}
+	return ops
+}
+
+func lcs(a, b []string) []Op {
+	n, m := len(a), len(b)
+	if n*m > maxCells {
+		ops := make([]Op, 0, n+m)
+		for _, l := range a {
+			ops = append(ops, Op{Delete, l})
+		}
+		for _, l := range b {
+			ops = append(ops, Op{Insert, l})
+		}
+		return ops
+	}
+
+	// table[i][j] is the LCS length of a[i:] and b[j:].
+	table := make([][]int, n+1)
+	for i := range table {
+		table[i] = make([]int, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				table[i][j] = table[i+1][j+1] + 1
+			} else {
+				table[i][j] = max(table[i+1][j], table[i][j+1])
+			}
+		}
+	}
+
+	ops := make([]Op, 0, n+m)
+	i, j := 0, 0
+	for i < n && j < m {
+		switch {
+		case a[i] == b[j]:
+			ops = append(ops, Op{Equal, a[i]})
+			i++
+			j++
+		case table[i+1][j] >= table[i][j+1]:
+			ops = append(ops, Op{Delete, a[i]})
+			i++
+		default:
+			ops = append(ops, Op{Insert, b[j]})
+			j++
+		}
+	}
+	for ; i < n; i++ {
+		ops = append(ops, Op{Delete, a[i]})
+	}
+	for ; j < m; j++ {
+		ops = append(ops, Op{Insert, b[j]})
+	}
+	return ops
+}
+
+// Hunk is one hunk of a unified diff. Start lines are 1-based.
+type Hunk struct {
+	OldStart int      `json:"old_start"`
+	OldLines int      `json:"old_lines"`
+	NewStart int      `json:"new_start"`
+	NewLines int      `json:"new_lines"`
+	Lines    []string `json:"lines"` // prefixed with ' ', '-' or '+'
+}
+
+// Header returns the hunk's "@@ -a,b +c,d @@" line.
+func (h Hunk) Header() string {
+	return fmt.Sprintf("@@ -%s +%s @@", hunkRange(h.OldStart, h.OldLines), hunkRange(h.NewStart, h.NewLines))
+}
+
+func hunkRange(start, lines int) string {
+	if lines == 0 {
+		// An empty range names the line before it, as in GNU diff.
+		return fmt.Sprintf("%d,0", start-1)
+	}
+	if lines == 1 {
+		return fmt.Sprintf("%d", start)
+	}
+	return fmt.Sprintf("%d,%d", start, lines)
+}
+
+// Hunks groups ops into hunks with up to context unchanged lines around
+// each change; changes closer than 2*context lines share a hunk. oldStart
+// and newStart are the line numbers of the first op.
+func Hunks(ops []Op, context, oldStart, newStart int) []Hunk {
+	// Line numbers at each op.
+	oldAt := make([]int, len(ops)+1)
+	newAt := make([]int, len(ops)+1)
+	o, n := oldStart, newStart
+	for i, op := range ops {
+		oldAt[i], newAt[i] = o, n
+		if op.Kind != Insert {
+			o++
+		}
+		if op.Kind != Delete {
+			n++
+		}
+	}
+	oldAt[len(ops)], newAt[len(ops)] = o, n
+
+	var hunks []Hunk
+	for i := 0; i < len(ops); {
+		if ops[i].Kind == Equal {
+			i++
+			continue
+		}
+
+		// Extend over following changes separated by short unchanged runs.
+		end := i + 1
+		for j := end; j < len(ops); {
+			if ops[j].Kind != Equal {
+				j++
+				end = j
+				continue
+			}
+			k := j
+			for k < len(ops) && ops[k].Kind == Equal {
+				k++
+			}
+			if k == len(ops) || k-j > 2*context {
+				break
+			}
+			j = k
+		}
+
+		start := max(0, i-context)
+		stop := min(len(ops), end+context)
+		h := Hunk{OldStart: oldAt[start], NewStart: newAt[start]}
+		for _, op := range ops[start:stop] {
+			h.Lines = append(h.Lines, string(op.Kind)+op.Line)
+			if op.Kind != Insert {
+				h.OldLines++
+			}
+			if op.Kind != Delete {
+				h.NewLines++
+			}
+		}
+		hunks = append(hunks, h)
+		i = stop
+	}
+	return hunks
+}
+
+// SplitLines splits text into lines without their trailing newlines.
+func SplitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
+
+// Format renders hunks as a unified diff of one file. oldPath is
+// "/dev/null" for a file that is being created.
+func Format(oldPath, newPath string, hunks []Hunk) string {
+	if len(hunks) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "--- %s\n+++ %s\n", oldPath, newPath)
+	for _, h := range hunks {
+		b.WriteString(h.Header())
+		b.WriteByte('\n')
+		for _, l := range h.Lines {
+			b.WriteString(l)
+			b.WriteByte('\n')
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,283 @@
+package diff
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"agent-observer/models"
+)
+
+// DefaultContext is the number of unchanged lines shown around changes.
+const DefaultContext = 3
+
+// readPageLines is how many lines Claude Code's Read returns by default. A
+// read from line 1 that returns fewer lines is taken to be the whole file.
+const readPageLines = 2000
+
+// Change is one file change reconstructed from a Write or Edit call.
+type Change struct {
+	TouchID   string    `json:"touch_id"`
+	AgentID   string    `json:"agent_id"`
+	FilePath  string    `json:"file_path"`
+	Operation string    `json:"operation"`
+	At        time.Time `json:"at"`
+	// LineNumbersKnown is false when the file's content wasn't known from
+	// an earlier Read or Write, so hunks start at line 1 and only their
+	// context locates them.
+	LineNumbersKnown bool `json:"line_numbers_known"`
+	Created          bool `json:"created"` // a Write that created the file
+	// Partial is true when the file's prior content is unknown: a Write
+	// over a file never read, or an edit that no Read locates. The hunks
+	// show only what the call wrote or replaced, and Patch leaves the
+	// change out.
+	Partial bool   `json:"partial"`
+	Hunks   []Hunk `json:"hunks"`
+	Diff    string `json:"diff"`
+}
+
+// window is a known run of a file's lines from a partial Read.
+type window struct {
+	start int // 1-based line number of lines[0]
+	lines []string
+}
+
+// fileState is what is known about a file's current content.
+type fileState struct {
+	known   bool // full is the whole file
+	full    []string
+	windows []window
+}
+
+// Reconstruct replays file touches, oldest first, and returns the changes
+// made by the touches include accepts (nil accepts all). Every touch
+// contributes to the known file content, so edits by one agent get line
+// numbers from another agent's Read. pathFor maps a file path to the path
+// used in diff headers.
+func Reconstruct(touches []models.FileTouch, include func(models.FileTouch) bool, pathFor func(string) string) []Change {
+	files := make(map[string]*fileState)
+	var changes []Change
+
+	for _, t := range touches {
+		if t.Failed {
+			continue
+		}
+		state, ok := files[t.FilePath]
+		if !ok {
+			state = &fileState{}
+			files[t.FilePath] = state
+		}
+
+		var touchChanges []Change
+		switch t.Operation {
+		case models.FileOpRead:
+			state.read(t.Result)
+		case models.FileOpWrite:
+			touchChanges = []Change{state.write(t.Content, isCreation(t.Result))}
+		case models.FileOpEdit:
+			var edits []models.FileEdit
+			_ = json.Unmarshal(t.Edits, &edits)
+			touchChanges = state.edit(edits)
+		}
+
+		if include != nil && !include(t) {
+			continue
+		}
+		path := pathFor(t.FilePath)
+		for _, c := range touchChanges {
+			if len(c.Hunks) == 0 {
+				continue
+			}
+			c.TouchID = t.ID
+			c.AgentID = t.AgentID
+			c.FilePath = t.FilePath
+			c.Operation = t.Operation
+			c.At = t.CreatedAt
+			oldPath := "a/" + path
+			if c.Created {
+				oldPath = "/dev/null"
+			}
+			c.Diff = Format(oldPath, "b/"+path, c.Hunks)
+			changes = append(changes, c)
+		}
+	}
+	return changes
+}
+
+// Patch concatenates the diffs of changes into one patch. Each change is
+// its own file section, so the patch applies change by change with
+// `patch -p1`. Partial changes can't be applied, so each is replaced by a
+// note line, which patch tools skip as leading text.
+func Patch(changes []Change) string {
+	var b strings.Builder
+	for _, c := range changes {
+		if c.Partial {
+			fmt.Fprintf(&b, "# %s of %s omitted: prior content unknown\n", c.Operation, c.FilePath)
+			continue
+		}
+		b.WriteString(c.Diff)
+	}
+	return b.String()
+}
+
+// isCreation reports whether a Write result says the file was created
+// rather than overwritten.
+func isCreation(result string) bool {
+	return strings.HasPrefix(strings.TrimSpace(result), "File created successfully")
+}
+
+func (s *fileState) read(result string) {
+	if strings.Contains(result, "the file exists but the contents are empty") {
+		s.known, s.full, s.windows = true, nil, nil
+		return
+	}
+	start, lines, ok := ParseReadResult(result)
+	if !ok {
+		return
+	}
+	if start == 1 && len(lines) < readPageLines {
+		s.known, s.full, s.windows = true, lines, nil
+		return
+	}
+	if !s.known {
+		s.windows = append(s.windows, window{start: start, lines: lines})
+	}
+}
+
+// write replaces the file's content. created is whether the Write result
+// says the file is new; without it, an unknown prior content makes the
+// change partial rather than a creation.
+func (s *fileState) write(content string, created bool) Change {
+	newLines := SplitLines(content)
+	c := Change{LineNumbersKnown: true}
+	switch {
+	case created:
+		c.Created = true
+		c.Hunks = Hunks(Lines(nil, newLines), DefaultContext, 1, 1)
+	case s.known:
+		c.Hunks = Hunks(Lines(s.full, newLines), DefaultContext, 1, 1)
+	default:
+		c.Partial = true
+		c.Hunks = Hunks(Lines(nil, newLines), DefaultContext, 1, 1)
+	}
+	s.known, s.full, s.windows = true, newLines, nil
+	return c
+}
+
+// edit applies edits in order. With the whole file known they make one
+// change; otherwise each edit is its own change, located through a Read
+// window if possible.
+func (s *fileState) edit(edits []models.FileEdit) []Change {
+	if s.known {
+		before := s.full
+		text := strings.Join(s.full, "\n")
+		applied := true
+		for _, e := range edits {
+			next, ok := applyEdit(text, e)
+			if !ok {
+				applied = false
+				break
+			}
+			text = next
+		}
+		if applied {
+			s.full = SplitLines(text)
+			return []Change{{
+				LineNumbersKnown: true,
+				Hunks:            Hunks(Lines(before, s.full), DefaultContext, 1, 1),
+			}}
+		}
+		// The file changed in ways we didn't see; stop trusting it.
+		s.known, s.full = false, nil
+	}
+
+	changes := make([]Change, 0, len(edits))
+	for _, e := range edits {
+		changes = append(changes, s.editWindow(e))
+	}
+	return changes
+}
+
+// editWindow renders one edit, with line numbers if a Read window contains
+// old_string.
+func (s *fileState) editWindow(e models.FileEdit) Change {
+	oldLines, newLines := SplitLines(e.OldString), SplitLines(e.NewString)
+	for i, w := range s.windows {
+		text := strings.Join(w.lines, "\n")
+		idx := strings.Index(text, e.OldString)
+		if e.OldString == "" || idx < 0 {
+			continue
+		}
+		line := w.start + strings.Count(text[:idx], "\n")
+
+		// Widen the edit to whole lines so the hunk shows complete lines.
+		lineStart := strings.LastIndex(text[:idx], "\n") + 1
+		lineEnd := idx + len(e.OldString)
+		if nl := strings.Index(text[lineEnd:], "\n"); nl >= 0 {
+			lineEnd += nl
+		} else {
+			lineEnd = len(text)
+		}
+		oldBlock := text[lineStart:lineEnd]
+		newBlock := text[lineStart:idx] + e.NewString + text[idx+len(e.OldString):lineEnd]
+
+		updated := SplitLines(text[:lineStart] + newBlock + text[lineEnd:])
+		delta := len(updated) - len(w.lines)
+		s.windows[i].lines = updated
+		for j := range s.windows {
+			if j != i && s.windows[j].start > line {
+				s.windows[j].start += delta
+			}
+		}
+
+		return Change{
+			LineNumbersKnown: true,
+			Hunks:            Hunks(Lines(SplitLines(oldBlock), SplitLines(newBlock)), DefaultContext, line, line),
+		}
+	}
+	return Change{Partial: true, Hunks: Hunks(Lines(oldLines, newLines), DefaultContext, 1, 1)}
+}
+
+func applyEdit(text string, e models.FileEdit) (string, bool) {
+	if e.OldString == "" || !strings.Contains(text, e.OldString) {
+		return text, false
+	}
+	if e.ReplaceAll {
+		return strings.ReplaceAll(text, e.OldString, e.NewString), true
+	}
+	return strings.Replace(text, e.OldString, e.NewString, 1), true
+}
+
+// readLinePattern matches a line of Read output: a right-aligned line
+// number, then a tab (or "→" in older Claude Code versions), then the line.
+var readLinePattern = regexp.MustCompile(`^\s*(\d+)(?:\t|→)(.*)$`)
+
+// ParseReadResult extracts the line number of the first line and the lines
+// of a Read tool result. Output that isn't numbered lines (errors, images)
+// returns ok false. Anything after the numbered lines, such as appended
+// system reminders, is ignored.
+func ParseReadResult(result string) (start int, lines []string, ok bool) {
+	next := 0
+	for _, raw := range strings.Split(result, "\n") {
+		m := readLinePattern.FindStringSubmatch(raw)
+		if m == nil {
+			if next > 0 {
+				break
+			}
+			continue
+		}
+		n, _ := strconv.Atoi(m[1])
+		if next > 0 && n != next {
+			break
+		}
+		if next == 0 {
+			start = n
+		}
+		lines = append(lines, strings.TrimSuffix(m[2], "\r"))
+		next = n + 1
+	}
+	return start, lines, next > 0
+}
@@ -0,0 +1,208 @@
+// Package dispatch delivers lifecycle events to the webhook subscriptions
+// stored in the database, signing each request and logging every delivery.
+//
+// A delivery is a POST of
+//
+//	{"id": "<delivery id>", "type": "session_finished", "time": "...", "data": {...}}
+//
+// with the headers X-Observer-Event, X-Observer-Delivery and
+// X-Observer-Signature ("sha256=" and the hex HMAC-SHA256 of the body keyed
+// by the webhook's secret).
+package dispatch
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"time"
+
+	"agent-observer/agentstate"
+	"agent-observer/db"
+	"agent-observer/events"
+	"agent-observer/models"
+	"agent-observer/webhook"
+
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+)
+
+// Event types webhooks can subscribe to.
+const (
+	SessionStarted   = events.SessionStarted
+	SessionFinished  = events.SessionFinished
+	SubagentSpawned  = events.SubagentSpawned
+	AgentErrored     = "agent_errored"
+	ContextCompacted = events.ContextCompacted
+)
+
+// EventTypes lists the event types webhooks can subscribe to.
+var EventTypes = []string{SessionStarted, SessionFinished, SubagentSpawned, AgentErrored, ContextCompacted}
+
+// IsEventType reports whether webhooks can subscribe to t.
+func IsEventType(t string) bool {
+	for _, et := range EventTypes {
+		if et == t {
+			return true
+		}
+	}
+	return false
+}
+
+// envelope is the body of a delivery.
+type envelope struct {
+	ID   string          `json:"id"`
+	Type string          `json:"type"`
+	Time time.Time       `json:"time"`
+	Data json.RawMessage `json:"data"`
+}
+
+// Start subscribes to the event bus and delivers lifecycle events to
+// webhooks from then on. Deliveries left pending by a previous run are
+// sent again.
+func Start() {
+	go resumePending(time.Now())
+
+	events.Subscribe(func(e events.Event) {
+		eventType := e.Type
+		if t, ok := e.Data.(agentstate.Transition); ok {
+			if t.To != agentstate.Errored {
+				return
+			}
+			eventType = AgentErrored
+		}
+		if !IsEventType(eventType) {
+			return
+		}
+		go dispatch(eventType, e.Data, e.Time)
+	})
+}
+
+// dispatch logs a delivery of an event to every active webhook subscribed
+// to it and sends them.
+func dispatch(eventType string, data interface{}, at time.Time) {
+	var hooks []models.Webhook
+	if err := db.DB.Where("active = ? AND EXISTS (SELECT 1 FROM json_each(events) WHERE value = ?)", true, eventType).
+		Find(&hooks).Error; err != nil {
+		log.Printf("Warning: failed to fetch webhooks for %s: %v", eventType, err)
+		return
+	}
+	if len(hooks) == 0 {
+		return
+	}
+
+	payload, err := json.Marshal(data)
+	if err != nil {
+		log.Printf("Warning: failed to encode %s event: %v", eventType, err)
+		return
+	}
+	for _, hook := range hooks {
+		delivery := models.WebhookDelivery{
+			ID:         uuid.New().String(),
+			WebhookID:  hook.ID,
+			EventType:  eventType,
+			Payload:    payload,
+			Status:     models.DeliveryPending,
+			Attempts:   datatypes.JSON("[]"),
+			OccurredAt: at,
+			CreatedAt:  time.Now(),
+		}
+		if err := db.DB.Create(&delivery).Error; err != nil {
+			log.Printf("Warning: failed to log delivery to webhook %s: %v", hook.ID, err)
+			continue
+		}
+		go Deliver(context.Background(), hook, &delivery)
+	}
+}
+
+// Deliver sends a logged delivery to its webhook, with retries, and records
+// the attempts and final status.
+func Deliver(ctx context.Context, hook models.Webhook, delivery *models.WebhookDelivery) {
+	body, _ := json.Marshal(envelope{
+		ID:   delivery.ID,
+		Type: delivery.EventType,
+		Time: delivery.OccurredAt,
+		Data: json.RawMessage(delivery.Payload),
+	})
+	target := webhook.Target{Name: hook.Name, URL: hook.URL}
+	result := target.Send(ctx, body, map[string]string{
+		"X-Observer-Event":     delivery.EventType,
+		"X-Observer-Delivery":  delivery.ID,
+		"X-Observer-Signature": Sign(hook.Secret, body),
+	})
+
+	now := time.Now()
+	attempts, _ := json.Marshal(result.Attempts)
+	delivery.Attempts = attempts
+	delivery.Status = models.DeliveryFailed
+	if result.Delivered {
+		delivery.Status = models.DeliveryDelivered
+	}
+	delivery.CompletedAt = &now
+	if err := db.DB.Model(delivery).Updates(map[string]interface{}{
+		"attempts":     delivery.Attempts,
+		"status":       delivery.Status,
+		"completed_at": now,
+	}).Error; err != nil {
+		log.Printf("Warning: failed to update delivery %s: %v", delivery.ID, err)
+	}
+	if !result.Delivered {
+		log.Printf("Warning: failed to deliver %s to webhook %s after %d attempts", delivery.EventType, hook.Name, len(result.Attempts))
+	}
+}
+
+// resumePending sends the deliveries logged before this run started but
+// never finished, under their original ID so receivers can drop
+// duplicates. Those whose webhook is gone or inactive are marked failed.
+func resumePending(startedAt time.Time) {
+	var pending []models.WebhookDelivery
+	if err := db.DB.Where("status = ? AND created_at < ?", models.DeliveryPending, startedAt).Order("created_at ASC").Find(&pending).Error; err != nil {
+		log.Printf("Warning: failed to fetch pending deliveries: %v", err)
+		return
+	}
+	for i := range pending {
+		delivery := &pending[i]
+		var hook models.Webhook
+		if err := db.DB.First(&hook, "id = ?", delivery.WebhookID).Error; err != nil || !hook.Active {
+			now := time.Now()
+			if err := db.DB.Model(delivery).Updates(map[string]interface{}{
+				"status":       models.DeliveryFailed,
+				"completed_at": now,
+			}).Error; err != nil {
+				log.Printf("Warning: failed to update delivery %s: %v", delivery.ID, err)
+			}
+			continue
+		}
+		go Deliver(context.Background(), hook, delivery)
+	}
+}
+
+// Replay logs a new delivery of a past delivery's event and sends it,
+// waiting for the result.
+func Replay(ctx context.Context, hook models.Webhook, original models.WebhookDelivery) (models.WebhookDelivery, error) {
+	delivery := models.WebhookDelivery{
+		ID:         uuid.New().String(),
+		WebhookID:  hook.ID,
+		EventType:  original.EventType,
+		Payload:    original.Payload,
+		Status:     models.DeliveryPending,
+		Attempts:   datatypes.JSON("[]"),
+		ReplayOf:   original.ID,
+		OccurredAt: original.OccurredAt,
+		CreatedAt:  time.Now(),
+	}
+	if err := db.DB.Create(&delivery).Error; err != nil {
+		return delivery, err
+	}
+	Deliver(ctx, hook, &delivery)
+	return delivery, nil
+}
+
+// Sign returns the X-Observer-Signature header value for body.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,72 @@
+// Package events is an in-process publish/subscribe bus for things that
+// happen in the observer (status transitions, alerts, ...). The WebSocket
+// hub subscribes to it so every published event also reaches the frontend.
+package events
+
+import (
+	"sync"
+	"time"
+)
+
+// Event types.
+const (
+	AgentStatusChanged = "agent_status_changed"
+	TeamStatusChanged  = "team_status_changed"
+	FileConflict       = "file_conflict"
+	AgentLooping       = "agent_looping"
+	AgentStuck         = "agent_stuck"
+	AlertFiring        = "alert_firing"
+	AlertResolved      = "alert_resolved"
+	SessionStarted     = "session_started"
+	SessionFinished    = "session_finished"
+	SubagentSpawned    = "subagent_spawned"
+	ContextCompacted   = "context_compacted"
+)
+
+// Event is a single published event.
+type Event struct {
+	Type string      `json:"type"`
+	Data interface{} `json:"data"`
+	Time time.Time   `json:"time"`
+}
+
+// Bus delivers published events to every subscriber, synchronously and in
+// subscription order. Subscribers that do slow work should hand it off to
+// their own goroutine.
+type Bus struct {
+	mu   sync.RWMutex
+	subs []func(Event)
+}
+
+// Default is the process-wide bus.
+var Default = &Bus{}
+
+// Subscribe registers fn to be called for every published event.
+func (b *Bus) Subscribe(fn func(Event)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.subs = append(b.subs, fn)
+}
+
+// Publish sends an event of the given type to all subscribers.
+func (b *Bus) Publish(eventType string, data interface{}) {
+	e := Event{Type: eventType, Data: data, Time: time.Now()}
+
+	b.mu.RLock()
+	subs := b.subs
+	b.mu.RUnlock()
+
+	for _, fn := range subs {
+		fn(e)
+	}
+}
+
+// Subscribe registers fn on the default bus.
+func Subscribe(fn func(Event)) {
+	Default.Subscribe(fn)
+}
+
+// Publish sends an event on the default bus.
+func Publish(eventType string, data interface{}) {
+	Default.Publish(eventType, data)
+}
@@ -46,6 +46,12 @@ func GetAgent(c *gin.Context) {
 	})
 }
 
+// AgentWithTodos is an agent with the progress of its todo list.
+type AgentWithTodos struct {
+	models.Agent
+	Todos *TodoProgress `json:"todos,omitempty"`
+}
+
 func ListAgentsByTeam(c *gin.Context) {
 	teamID := c.Param("id")
 
@@ -55,5 +61,20 @@ func ListAgentsByTeam(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, agents)
+	var snapshots []models.TodoSnapshot
+	if err := db.DB.Select("agent_id, total, completed, created_at, version").Where("team_id = ?", teamID).
+		Order("created_at ASC, version ASC").Find(&snapshots).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch todo snapshots"})
+		return
+	}
+	byAgent := make(map[string][]models.TodoSnapshot)
+	for _, s := range snapshots {
+		byAgent[s.AgentID] = append(byAgent[s.AgentID], s)
+	}
+
+	result := make([]AgentWithTodos, len(agents))
+	for i, a := range agents {
+		result[i] = AgentWithTodos{Agent: a, Todos: todoProgress(byAgent[a.ID])}
+	}
+	c.JSON(http.StatusOK, result)
 }
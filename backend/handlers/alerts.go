@@ -0,0 +1,221 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"agent-observer/alerts"
+	"agent-observer/db"
+	"agent-observer/models"
+	"agent-observer/webhook"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// ListAlerts lists alerts, newest first. Filters: status (firing or
+// resolved), rule, since and until (on when the alert started).
+func ListAlerts(c *gin.Context) {
+	query := db.DB.Model(&models.Alert{})
+	if v := c.Query("status"); v != "" {
+		if v != models.AlertFiring && v != models.AlertResolved {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid status: " + v})
+			return
+		}
+		query = query.Where("status = ?", v)
+	}
+	if v := c.Query("rule"); v != "" {
+		query = query.Where("rule = ?", v)
+	}
+	query, ok := timeWindow(c, query, "starts_at")
+	if !ok {
+		return
+	}
+
+	list := []models.Alert{}
+	if err := query.Order("starts_at DESC").Limit(500).Find(&list).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch alerts"})
+		return
+	}
+
+	c.JSON(http.StatusOK, list)
+}
+
+// ListAlertRules returns the configured alert rules and the names of the
+// webhooks they deliver to. Webhook URLs and headers may hold secrets and
+// are left out.
+func ListAlertRules(c *gin.Context) {
+	names := []string{}
+	for _, w := range alerts.Webhooks() {
+		names = append(names, w.Name)
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"rules":    alerts.Rules(),
+		"webhooks": names,
+	})
+}
+
+// ListAlertSilences lists silences that have not ended yet.
+// ?all=true includes expired ones.
+func ListAlertSilences(c *gin.Context) {
+	query := db.DB.Model(&models.AlertSilence{})
+	if c.Query("all") != "true" {
+		query = query.Where("ends_at > ?", time.Now())
+	}
+
+	silences := []models.AlertSilence{}
+	if err := query.Order("created_at DESC").Find(&silences).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch silences"})
+		return
+	}
+
+	c.JSON(http.StatusOK, silences)
+}
+
+type CreateAlertSilenceReq struct {
+	Matchers  map[string]string `json:"matchers" binding:"required"`
+	Comment   string            `json:"comment"`
+	CreatedBy string            `json:"created_by"`
+	StartsAt  *time.Time        `json:"starts_at"`
+	EndsAt    *time.Time        `json:"ends_at"`
+	Duration  string            `json:"duration"` // instead of ends_at, e.g. "2h"
+}
+
+// CreateAlertSilence silences alerts whose labels match all matchers,
+// from starts_at (default now) until ends_at or for duration.
+func CreateAlertSilence(c *gin.Context) {
+	var req CreateAlertSilenceReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(req.Matchers) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "matchers must not be empty"})
+		return
+	}
+
+	now := time.Now()
+	startsAt := now
+	if req.StartsAt != nil {
+		startsAt = *req.StartsAt
+	}
+	var endsAt time.Time
+	switch {
+	case req.EndsAt != nil:
+		endsAt = *req.EndsAt
+	case req.Duration != "":
+		d, err := time.ParseDuration(req.Duration)
+		if err != nil || d <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid duration: " + req.Duration})
+			return
+		}
+		endsAt = startsAt.Add(d)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ends_at or duration is required"})
+		return
+	}
+	if !endsAt.After(startsAt) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ends_at must be after starts_at"})
+		return
+	}
+
+	matchers, _ := json.Marshal(req.Matchers)
+	silence := models.AlertSilence{
+		ID:        uuid.New().String(),
+		Matchers:  matchers,
+		Comment:   req.Comment,
+		CreatedBy: req.CreatedBy,
+		StartsAt:  startsAt,
+		EndsAt:    endsAt,
+		CreatedAt: now,
+	}
+	if err := db.DB.Create(&silence).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create silence"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, silence)
+}
+
+// ExpireAlertSilence ends a silence now.
+func ExpireAlertSilence(c *gin.Context) {
+	id := c.Param("id")
+
+	result := db.DB.Model(&models.AlertSilence{}).Where("id = ? AND ends_at > ?", id, time.Now()).
+		Update("ends_at", time.Now())
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to expire silence"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Silence not found or already expired"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+type TestAlertWebhookReq struct {
+	// Webhook names a configured webhook. Otherwise URL (and optionally
+	// Template and Headers) describe an ad-hoc one.
+	Webhook  string            `json:"webhook"`
+	URL      string            `json:"url"`
+	Template string            `json:"template"`
+	Headers  map[string]string `json:"headers"`
+}
+
+// TestAlertWebhook sends a sample notification to a webhook and waits for
+// the delivery result, retries included.
+func TestAlertWebhook(c *gin.Context) {
+	var req TestAlertWebhookReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var target webhook.Target
+	if req.Webhook != "" {
+		found := false
+		for _, w := range alerts.Webhooks() {
+			if w.Name == req.Webhook {
+				target, found = w, true
+			}
+		}
+		if !found {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found: " + req.Webhook})
+			return
+		}
+	} else {
+		target = webhook.Target{Name: "test", URL: req.URL, Template: req.Template, Headers: req.Headers}
+		if err := target.Validate(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	now := time.Now()
+	labels := map[string]string{"alertname": "test"}
+	labelsJSON, _ := json.Marshal(labels)
+	n := alerts.Notification{
+		Status: "test",
+		Alert: models.Alert{
+			ID:        uuid.New().String(),
+			Rule:      "test",
+			Status:    models.AlertFiring,
+			Severity:  alerts.SeverityInfo,
+			Summary:   "Test notification from Agent Observer",
+			Labels:    labelsJSON,
+			StartsAt:  now,
+			UpdatedAt: now,
+		},
+		Labels: labels,
+	}
+	result, err := alerts.Deliver(c.Request.Context(), target, n)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to render template: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,519 @@
+package handlers
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"agent-observer/db"
+	"agent-observer/modelinfo"
+	"agent-observer/models"
+	"agent-observer/toolerrors"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// CacheStats aggregates prompt-cache behaviour over a set of turns. Costs
+// are in USD at list price.
+type CacheStats struct {
+	Turns         int     `json:"turns"`
+	InputTokens   int     `json:"input_tokens"` // uncached input
+	CacheCreation int     `json:"cache_creation"`
+	CacheRead     int     `json:"cache_read"`
+	OutputTokens  int     `json:"output_tokens"`
+	HitRatio      float64 `json:"hit_ratio"` // cache_read / all input tokens
+	// WriteCost is what cache writes cost on top of sending the same
+	// tokens uncached; ReadSavings is what cache reads saved over that.
+	WriteCost   float64 `json:"write_cost_usd"`
+	ReadSavings float64 `json:"read_savings_usd"`
+	NetSavings  float64 `json:"net_savings_usd"`
+	TotalCost   float64 `json:"total_cost_usd"`
+}
+
+func (s *CacheStats) add(t models.TurnUsage) {
+	p := modelinfo.PricingFor(t.Model)
+	s.Turns++
+	s.InputTokens += t.InputTokens
+	s.CacheCreation += t.CacheCreation
+	s.CacheRead += t.CacheRead
+	s.OutputTokens += t.OutputTokens
+	s.WriteCost += float64(t.CacheCreation) * (p.CacheWrite - p.Input) / 1e6
+	s.ReadSavings += float64(t.CacheRead) * (p.Input - p.CacheRead) / 1e6
+	s.TotalCost += p.Cost(t.InputTokens, t.CacheCreation, t.CacheRead, t.OutputTokens)
+}
+
+func (s *CacheStats) finish() {
+	if total := s.InputTokens + s.CacheCreation + s.CacheRead; total > 0 {
+		s.HitRatio = float64(s.CacheRead) / float64(total)
+	}
+	s.NetSavings = s.ReadSavings - s.WriteCost
+}
+
+type CacheGroup struct {
+	Key   string `json:"key"`
+	Label string `json:"label"`
+	CacheStats
+}
+
+// CacheBucket is one interval of the timeline. MissSpike is set when the
+// bucket's uncached input (fresh input plus cache writes) is well above
+// the timeline's median.
+type CacheBucket struct {
+	Start time.Time `json:"start"`
+	CacheStats
+	MissSpike bool `json:"miss_spike"`
+}
+
+// CacheInvalidation is a turn that rewrote a large part of the cache after
+// a run of turns that were reading it, e.g. because the system prompt or
+// tool list changed or the cache expired.
+type CacheInvalidation struct {
+	TurnID         string    `json:"turn_id"`
+	TeamID         string    `json:"team_id"`
+	AgentID        string    `json:"agent_id"`
+	Model          string    `json:"model"`
+	At             time.Time `json:"at"`
+	CacheCreation  int       `json:"cache_creation"`
+	CacheRead      int       `json:"cache_read"`
+	PriorReadTurns int       `json:"prior_read_turns"` // length of the run of cache reads before it
+	ExtraCost      float64   `json:"extra_cost_usd"`   // over reading the same tokens from cache
+	Compacted      bool      `json:"compacted"`        // expected: the context was compacted
+}
+
+const (
+	missSpikeFactor           = 3
+	minInvalidationRun        = 2
+	defaultInvalidationTokens = 10_000
+)
+
+// GetCacheAnalytics reports prompt-cache efficiency over the turns matching
+// the team_id, agent_id, model, since and until filters, grouped by
+// group_by (agent, model or team) and bucketed by bucket (hour or day).
+// min_tokens sets how large a cache write must be to count as an
+// invalidation.
+func GetCacheAnalytics(c *gin.Context) {
+	query, ok := turnUsageQuery(c)
+	if !ok {
+		return
+	}
+
+	groupBy := c.DefaultQuery("group_by", "agent")
+	if groupBy != "agent" && groupBy != "model" && groupBy != "team" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid group_by: " + groupBy})
+		return
+	}
+	bucket := time.Hour
+	switch c.DefaultQuery("bucket", "hour") {
+	case "hour":
+	case "day":
+		bucket = 24 * time.Hour
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid bucket: " + c.Query("bucket")})
+		return
+	}
+	minTokens := defaultInvalidationTokens
+	if v := c.Query("min_tokens"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid min_tokens: " + v})
+			return
+		}
+		minTokens = n
+	}
+
+	var turns []models.TurnUsage
+	if err := query.Order("created_at ASC").Find(&turns).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch turn usage"})
+		return
+	}
+
+	var totals CacheStats
+	groups := make(map[string]*CacheGroup)
+	buckets := make(map[int64]*CacheBucket)
+	for _, t := range turns {
+		totals.add(t)
+
+		key := t.AgentID
+		switch groupBy {
+		case "model":
+			key = t.Model
+		case "team":
+			key = t.TeamID
+		}
+		g, ok := groups[key]
+		if !ok {
+			g = &CacheGroup{Key: key, Label: key}
+			groups[key] = g
+		}
+		g.add(t)
+
+		start := t.CreatedAt.Truncate(bucket)
+		b, ok := buckets[start.Unix()]
+		if !ok {
+			b = &CacheBucket{Start: start}
+			buckets[start.Unix()] = b
+		}
+		b.add(t)
+	}
+	totals.finish()
+
+	groupList := make([]CacheGroup, 0, len(groups))
+	for _, g := range groups {
+		g.finish()
+		groupList = append(groupList, *g)
+	}
+	labelCacheGroups(groupList, groupBy)
+	sort.Slice(groupList, func(i, j int) bool { return groupList[i].TotalCost > groupList[j].TotalCost })
+
+	timeline := make([]CacheBucket, 0, len(buckets))
+	for _, b := range buckets {
+		b.finish()
+		timeline = append(timeline, *b)
+	}
+	sort.Slice(timeline, func(i, j int) bool { return timeline[i].Start.Before(timeline[j].Start) })
+	markMissSpikes(timeline)
+
+	c.JSON(http.StatusOK, gin.H{
+		"totals":        totals,
+		"groups":        groupList,
+		"timeline":      timeline,
+		"invalidations": cacheInvalidations(turns, minTokens),
+	})
+}
+
+// turnUsageQuery builds a TurnUsage query from the team_id, agent_id,
+// model, since and until query parameters, writing a 400 and returning
+// false if a time is malformed.
+func turnUsageQuery(c *gin.Context) (*gorm.DB, bool) {
+	query := db.DB.Model(&models.TurnUsage{})
+	if v := c.Query("team_id"); v != "" {
+		query = query.Where("team_id = ?", v)
+	}
+	if v := c.Query("agent_id"); v != "" {
+		query = query.Where("agent_id = ?", v)
+	}
+	if v := c.Query("model"); v != "" {
+		query = query.Where("model = ?", v)
+	}
+	return timeWindow(c, query, "created_at")
+}
+
+// timeWindow restricts query to rows whose column falls between the since
+// (inclusive) and until (exclusive) RFC 3339 query parameters, writing a
+// 400 and returning false if either is malformed.
+func timeWindow(c *gin.Context, query *gorm.DB, column string) (*gorm.DB, bool) {
+	for _, param := range []string{"since", "until"} {
+		v := c.Query(param)
+		if v == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + param + ": " + v})
+			return nil, false
+		}
+		if param == "since" {
+			query = query.Where(column+" >= ?", t)
+		} else {
+			query = query.Where(column+" < ?", t)
+		}
+	}
+	return query, true
+}
+
+// labelCacheGroups replaces agent and team IDs with their names.
+func labelCacheGroups(groups []CacheGroup, groupBy string) {
+	if groupBy == "model" || len(groups) == 0 {
+		return
+	}
+	ids := make([]string, len(groups))
+	for i, g := range groups {
+		ids[i] = g.Key
+	}
+
+	names := make(map[string]string)
+	if groupBy == "agent" {
+		var agents []models.Agent
+		db.DB.Where("id IN ?", ids).Find(&agents)
+		for _, a := range agents {
+			names[a.ID] = a.Name
+		}
+	} else {
+		var teams []models.Team
+		db.DB.Where("id IN ?", ids).Find(&teams)
+		for _, t := range teams {
+			names[t.ID] = t.Name
+		}
+	}
+	for i := range groups {
+		if name := names[groups[i].Key]; name != "" {
+			groups[i].Label = name
+		}
+	}
+}
+
+// markMissSpikes flags buckets whose uncached input is more than
+// missSpikeFactor times the median bucket's.
+func markMissSpikes(timeline []CacheBucket) {
+	if len(timeline) < 3 {
+		return
+	}
+	misses := make([]int, len(timeline))
+	for i, b := range timeline {
+		misses[i] = b.InputTokens + b.CacheCreation
+	}
+	sorted := append([]int(nil), misses...)
+	sort.Ints(sorted)
+	median := sorted[len(sorted)/2]
+	for i := range timeline {
+		timeline[i].MissSpike = misses[i] > 0 && misses[i] > missSpikeFactor*median
+	}
+}
+
+// cacheInvalidations finds, per agent, turns that wrote at least minTokens
+// to the cache, more than they read, right after minInvalidationRun or more
+// turns that read from it. turns must be in time order.
+func cacheInvalidations(turns []models.TurnUsage, minTokens int) []CacheInvalidation {
+	result := []CacheInvalidation{}
+	runs := make(map[string]int) // agent -> consecutive turns with cache reads
+	for _, t := range turns {
+		if runs[t.AgentID] >= minInvalidationRun && t.CacheCreation >= minTokens && t.CacheCreation > t.CacheRead {
+			p := modelinfo.PricingFor(t.Model)
+			result = append(result, CacheInvalidation{
+				TurnID:         t.ID,
+				TeamID:         t.TeamID,
+				AgentID:        t.AgentID,
+				Model:          t.Model,
+				At:             t.CreatedAt,
+				CacheCreation:  t.CacheCreation,
+				CacheRead:      t.CacheRead,
+				PriorReadTurns: runs[t.AgentID],
+				ExtraCost:      float64(t.CacheCreation) * (p.CacheWrite - p.CacheRead) / 1e6,
+				Compacted:      t.Compacted,
+			})
+		}
+		if t.CacheRead > 0 && t.CacheRead >= t.CacheCreation {
+			runs[t.AgentID]++
+		} else {
+			runs[t.AgentID] = 0
+		}
+	}
+	return result
+}
+
+// ToolStats aggregates the calls of one tool. Durations only count calls
+// that have finished.
+type ToolStats struct {
+	ToolName  string  `json:"tool_name"`
+	Calls     int     `json:"calls"`
+	Completed int     `json:"completed"`
+	Errors    int     `json:"errors"`
+	ErrorRate float64 `json:"error_rate"` // errors / completed
+	P50Ms     int64   `json:"p50_ms"`
+	P95Ms     int64   `json:"p95_ms"`
+	AvgMs     int64   `json:"avg_ms"`
+	MaxMs     int64   `json:"max_ms"`
+	TotalMs   int64   `json:"total_ms"`
+
+	durations []int64
+}
+
+// GetToolAnalytics reports call counts, latency percentiles and error rates
+// per tool over the tool spans matching the team_id, agent_id, model, since
+// and until filters.
+func GetToolAnalytics(c *gin.Context) {
+	query, ok := toolSpanQuery(c)
+	if !ok {
+		return
+	}
+
+	var traces []models.Trace
+	if err := query.Find(&traces).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch traces"})
+		return
+	}
+
+	tools := make(map[string]*ToolStats)
+	total := &ToolStats{ToolName: "*"}
+	for _, t := range traces {
+		name := strings.TrimPrefix(t.SpanName, "tool.")
+		stats, ok := tools[name]
+		if !ok {
+			stats = &ToolStats{ToolName: name}
+			tools[name] = stats
+		}
+		for _, s := range []*ToolStats{stats, total} {
+			s.Calls++
+			if t.EndTime == nil {
+				continue
+			}
+			s.Completed++
+			s.durations = append(s.durations, t.EndTime.Sub(t.StartTime).Milliseconds())
+			if t.Status == models.SpanStatusError {
+				s.Errors++
+			}
+		}
+	}
+
+	result := make([]ToolStats, 0, len(tools))
+	for _, s := range tools {
+		s.finish()
+		result = append(result, *s)
+	}
+	total.finish()
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Calls != result[j].Calls {
+			return result[i].Calls > result[j].Calls
+		}
+		return result[i].ToolName < result[j].ToolName
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"totals": total,
+		"tools":  result,
+	})
+}
+
+// toolSpanQuery builds a query over tool spans from the team_id, agent_id,
+// model, since and until query parameters, writing a 400 and returning
+// false if a time is malformed.
+func toolSpanQuery(c *gin.Context) (*gorm.DB, bool) {
+	query := db.DB.Model(&models.Trace{}).Where("span_name LIKE ?", "tool.%")
+	if v := c.Query("team_id"); v != "" {
+		query = query.Where("team_id = ?", v)
+	}
+	if v := c.Query("agent_id"); v != "" {
+		query = query.Where("agent_id = ?", v)
+	}
+	if v := c.Query("model"); v != "" {
+		query = query.Where("json_extract(attributes, '$.model') = ?", v)
+	}
+	return timeWindow(c, query, "start_time")
+}
+
+func (s *ToolStats) finish() {
+	if s.Completed > 0 {
+		s.ErrorRate = float64(s.Errors) / float64(s.Completed)
+	}
+	if len(s.durations) == 0 {
+		return
+	}
+	sort.Slice(s.durations, func(i, j int) bool { return s.durations[i] < s.durations[j] })
+	for _, d := range s.durations {
+		s.TotalMs += d
+	}
+	s.P50Ms = percentile(s.durations, 0.50)
+	s.P95Ms = percentile(s.durations, 0.95)
+	s.AvgMs = s.TotalMs / int64(len(s.durations))
+	s.MaxMs = s.durations[len(s.durations)-1]
+}
+
+// percentile returns the nearest-rank percentile p (0-1) of sorted values.
+func percentile(sorted []int64, p float64) int64 {
+	rank := int(math.Ceil(p*float64(len(sorted)))) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	return sorted[rank]
+}
+
+type ErrorCategoryCount struct {
+	Category string         `json:"category"`
+	Count    int            `json:"count"`
+	ByTool   map[string]int `json:"by_tool"`
+}
+
+// ToolFailure is one failed tool call.
+type ToolFailure struct {
+	SpanID   string    `json:"span_id"`
+	TeamID   string    `json:"team_id"`
+	AgentID  string    `json:"agent_id"`
+	ToolName string    `json:"tool_name"`
+	Category string    `json:"category"`
+	Message  string    `json:"message"`
+	At       time.Time `json:"at"`
+}
+
+// GetErrorAnalytics counts failed tool calls by failure category (see the
+// toolerrors package) and lists the most recent ones. It takes the same
+// filters as GetToolAnalytics plus category and limit (default 50) for the
+// list.
+func GetErrorAnalytics(c *gin.Context) {
+	query, ok := toolSpanQuery(c)
+	if !ok {
+		return
+	}
+	limit := 50
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit: " + v})
+			return
+		}
+		limit = n
+	}
+	category := c.Query("category")
+
+	var traces []models.Trace
+	if err := query.Where("status = ?", models.SpanStatusError).Order("start_time DESC").Find(&traces).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch traces"})
+		return
+	}
+
+	counts := make(map[string]*ErrorCategoryCount)
+	failures := []ToolFailure{}
+	for _, t := range traces {
+		var attrs struct {
+			ToolName      string `json:"tool_name"`
+			ErrorCategory string `json:"error_category"`
+		}
+		_ = json.Unmarshal(t.Attributes, &attrs)
+		toolName := strings.TrimPrefix(t.SpanName, "tool.")
+		cat := attrs.ErrorCategory
+		if cat == "" {
+			cat = toolerrors.Other
+		}
+
+		cc, ok := counts[cat]
+		if !ok {
+			cc = &ErrorCategoryCount{Category: cat, ByTool: make(map[string]int)}
+			counts[cat] = cc
+		}
+		cc.Count++
+		cc.ByTool[toolName]++
+
+		if (category == "" || category == cat) && len(failures) < limit {
+			failures = append(failures, ToolFailure{
+				SpanID:   t.ID,
+				TeamID:   t.TeamID,
+				AgentID:  t.AgentID,
+				ToolName: toolName,
+				Category: cat,
+				Message:  t.StatusMessage,
+				At:       t.StartTime,
+			})
+		}
+	}
+
+	categories := make([]ErrorCategoryCount, 0, len(counts))
+	for _, cc := range counts {
+		categories = append(categories, *cc)
+	}
+	sort.Slice(categories, func(i, j int) bool {
+		if categories[i].Count != categories[j].Count {
+			return categories[i].Count > categories[j].Count
+		}
+		return categories[i].Category < categories[j].Category
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"total":      len(traces),
+		"categories": categories,
+		"recent":     failures,
+	})
+}
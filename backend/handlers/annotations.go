@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"agent-observer/db"
+	"agent-observer/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetConversationAnnotations lists the analyzer findings on a conversation,
+// oldest first. ?open=true leaves out resolved ones.
+func GetConversationAnnotations(c *gin.Context) {
+	id := c.Param("id")
+
+	query := db.DB.Where("conversation_id = ?", id)
+	if v := c.Query("open"); v != "" {
+		open, err := strconv.ParseBool(v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid open: " + v})
+			return
+		}
+		if open {
+			query = query.Where("resolved_at IS NULL")
+		}
+	}
+
+	annotations := []models.Annotation{}
+	if err := query.Order("started_at ASC").Find(&annotations).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch annotations"})
+		return
+	}
+
+	c.JSON(http.StatusOK, annotations)
+}
@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"agent-observer/audit"
+	"agent-observer/db"
+	"agent-observer/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ListCommandAudits searches the Bash command audit log, newest first.
+// Filters: team_id, agent_id, q (substring of the command or description),
+// cwd, severity (this severity or worse), rule, failed, since and until.
+// limit (default 100, max 1000) and offset page the results; the finding
+// counts cover every matching command.
+func ListCommandAudits(c *gin.Context) {
+	query := db.DB.Model(&models.CommandAudit{})
+	if v := c.Query("team_id"); v != "" {
+		query = query.Where("team_id = ?", v)
+	}
+	if v := c.Query("agent_id"); v != "" {
+		query = query.Where("agent_id = ?", v)
+	}
+	if v := c.Query("q"); v != "" {
+		query = query.Where("(instr(command, ?) > 0 OR instr(description, ?) > 0)", v, v)
+	}
+	if v := c.Query("cwd"); v != "" {
+		query = query.Where("cwd = ?", v)
+	}
+	if v := c.Query("severity"); v != "" {
+		rank := audit.SeverityRank(v)
+		if rank == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid severity: " + v})
+			return
+		}
+		query = query.Where("severity IN ?", audit.Severities[rank-1:])
+	}
+	if v := c.Query("rule"); v != "" {
+		query = query.Where("EXISTS (SELECT 1 FROM json_each(findings) WHERE json_extract(value, '$.rule_id') = ?)", v)
+	}
+	if v := c.Query("failed"); v != "" {
+		failed, err := strconv.ParseBool(v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid failed: " + v})
+			return
+		}
+		query = query.Where("failed = ?", failed)
+	}
+	query, ok := timeWindow(c, query, "created_at")
+	if !ok {
+		return
+	}
+
+	limit, offset := 100, 0
+	for param, dst := range map[string]*int{"limit": &limit, "offset": &offset} {
+		v := c.Query(param)
+		if v == "" {
+			continue
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + param + ": " + v})
+			return
+		}
+		*dst = n
+	}
+	limit = min(limit, 1000)
+
+	var flagged []models.CommandAudit
+	if err := query.Session(&gorm.Session{}).Select("findings").Where("severity != ''").Find(&flagged).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch command audits"})
+		return
+	}
+	var total int64
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch command audits"})
+		return
+	}
+	bySeverity := make(map[string]int, len(audit.Severities))
+	for _, s := range audit.Severities {
+		bySeverity[s] = 0
+	}
+	byRule := make(map[string]int)
+	for _, a := range flagged {
+		var findings []audit.Finding
+		_ = json.Unmarshal(a.Findings, &findings)
+		for _, f := range findings {
+			bySeverity[f.Severity]++
+			byRule[f.RuleID]++
+		}
+	}
+
+	commands := []models.CommandAudit{}
+	if err := query.Order("created_at DESC").Limit(limit).Offset(offset).Find(&commands).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch command audits"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"total":       total,
+		"by_severity": bySeverity,
+		"by_rule":     byRule,
+		"commands":    commands,
+	})
+}
+
+// ListAuditRules lists the active command audit rules.
+func ListAuditRules(c *gin.Context) {
+	c.JSON(http.StatusOK, audit.Rules())
+}
@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"agent-observer/db"
+	"agent-observer/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type ConflictAgent struct {
+	AgentID   string `json:"agent_id"`
+	AgentName string `json:"agent_name"`
+}
+
+type FileConflictView struct {
+	models.FileConflict
+	Agents []ConflictAgent `json:"agents"`
+}
+
+// ListTeamGroupConflicts lists the file conflicts between agents of all
+// sessions in a Claude Code team, newest first.
+func ListTeamGroupConflicts(c *gin.Context) {
+	respondConflicts(c, db.DB.Where("team_name = ?", c.Param("teamName")))
+}
+
+// ListTeamConflicts lists the file conflicts a team's agents were part of,
+// newest first.
+func ListTeamConflicts(c *gin.Context) {
+	respondConflicts(c, db.DB.Where("EXISTS (SELECT 1 FROM json_each(team_ids) WHERE value = ?)", c.Param("id")))
+}
+
+func respondConflicts(c *gin.Context, query *gorm.DB) {
+	var conflicts []models.FileConflict
+	if err := query.Order("started_at DESC").Find(&conflicts).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch conflicts"})
+		return
+	}
+
+	agentIDs := make([][]string, len(conflicts))
+	var all []string
+	for i, fc := range conflicts {
+		_ = json.Unmarshal(fc.AgentIDs, &agentIDs[i])
+		all = append(all, agentIDs[i]...)
+	}
+	names := make(map[string]string)
+	if len(all) > 0 {
+		var agents []models.Agent
+		db.DB.Where("id IN ?", all).Find(&agents)
+		for _, a := range agents {
+			names[a.ID] = a.Name
+		}
+	}
+
+	result := make([]FileConflictView, 0, len(conflicts))
+	for i, fc := range conflicts {
+		view := FileConflictView{FileConflict: fc, Agents: make([]ConflictAgent, 0, len(agentIDs[i]))}
+		for _, id := range agentIDs[i] {
+			view.Agents = append(view.Agents, ConflictAgent{AgentID: id, AgentName: names[id]})
+		}
+		result = append(result, view)
+	}
+
+	c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,237 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"agent-observer/db"
+	"agent-observer/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ContextThresholds are the fractions of the context window at which an
+// agent is flagged, unless a request overrides them with ?thresholds=.
+var ContextThresholds = []float64{0.8, 0.95}
+
+// ParseThresholds parses a comma-separated list of fractions such as
+// "0.8,0.95". Percentages ("80") are accepted too.
+func ParseThresholds(s string) ([]float64, error) {
+	var thresholds []float64
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		v, err := strconv.ParseFloat(part, 64)
+		if err != nil || v <= 0 {
+			return nil, fmt.Errorf("invalid threshold: %q", part)
+		}
+		if v > 1 {
+			v /= 100
+		}
+		thresholds = append(thresholds, v)
+	}
+	sort.Float64s(thresholds)
+	return thresholds, nil
+}
+
+type ContextPoint struct {
+	TurnID        string    `json:"turn_id"`
+	At            time.Time `json:"at"`
+	Model         string    `json:"model"`
+	ContextTokens int       `json:"context_tokens"`
+	OutputTokens  int       `json:"output_tokens"`
+	ContextWindow int       `json:"context_window"`
+	Pct           float64   `json:"pct"` // ContextTokens / ContextWindow
+	Compacted     bool      `json:"compacted"`
+}
+
+// ContextCrossing is a turn where usage rose to or past a threshold. A
+// threshold can be crossed again after a compaction brings usage back down.
+type ContextCrossing struct {
+	Threshold     float64   `json:"threshold"`
+	TurnID        string    `json:"turn_id"`
+	At            time.Time `json:"at"`
+	ContextTokens int       `json:"context_tokens"`
+	Pct           float64   `json:"pct"`
+}
+
+type ContextCompaction struct {
+	TurnID       string    `json:"turn_id"`
+	At           time.Time `json:"at"`
+	TokensBefore int       `json:"tokens_before"`
+	TokensAfter  int       `json:"tokens_after"`
+}
+
+type AgentContext struct {
+	AgentID       string              `json:"agent_id"`
+	AgentName     string              `json:"agent_name,omitempty"`
+	Role          string              `json:"role,omitempty"`
+	Model         string              `json:"model"`
+	ContextWindow int                 `json:"context_window"`
+	CurrentTokens int                 `json:"current_tokens"`
+	CurrentPct    float64             `json:"current_pct"`
+	PeakTokens    int                 `json:"peak_tokens"`
+	PeakPct       float64             `json:"peak_pct"`
+	Turns         int                 `json:"turns"`
+	Thresholds    []float64           `json:"thresholds"`
+	Crossings     []ContextCrossing   `json:"crossings"`
+	Compactions   []ContextCompaction `json:"compactions"`
+	Points        []ContextPoint      `json:"points,omitempty"`
+}
+
+// GetAgentContext returns an agent's context growth curve, one point per
+// model call, with threshold crossings and compactions flagged.
+func GetAgentContext(c *gin.Context) {
+	id := c.Param("id")
+
+	thresholds, ok := contextThresholds(c)
+	if !ok {
+		return
+	}
+
+	var agent models.Agent
+	if err := db.DB.First(&agent, "id = ?", id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Agent not found"})
+		return
+	}
+
+	var turns []models.TurnUsage
+	if err := db.DB.Where("agent_id = ?", id).Order("created_at ASC").Find(&turns).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch turn usage"})
+		return
+	}
+
+	c.JSON(http.StatusOK, buildAgentContext(agent, turns, thresholds, true))
+}
+
+// GetTeamContext summarizes context usage for every agent in a team, the
+// agents closest to their limit first.
+func GetTeamContext(c *gin.Context) {
+	id := c.Param("id")
+
+	thresholds, ok := contextThresholds(c)
+	if !ok {
+		return
+	}
+
+	var agents []models.Agent
+	if err := db.DB.Where("team_id = ?", id).Find(&agents).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch agents"})
+		return
+	}
+
+	var turns []models.TurnUsage
+	if err := db.DB.Where("team_id = ?", id).Order("created_at ASC").Find(&turns).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch turn usage"})
+		return
+	}
+	byAgent := make(map[string][]models.TurnUsage)
+	for _, t := range turns {
+		byAgent[t.AgentID] = append(byAgent[t.AgentID], t)
+	}
+
+	result := make([]AgentContext, 0, len(agents))
+	for _, agent := range agents {
+		result = append(result, buildAgentContext(agent, byAgent[agent.ID], thresholds, false))
+	}
+	sort.SliceStable(result, func(i, j int) bool { return result[i].PeakPct > result[j].PeakPct })
+
+	c.JSON(http.StatusOK, result)
+}
+
+// contextThresholds returns the thresholds for a request, writing a 400 and
+// returning false if ?thresholds= is malformed.
+func contextThresholds(c *gin.Context) ([]float64, bool) {
+	q := c.Query("thresholds")
+	if q == "" {
+		return ContextThresholds, true
+	}
+	thresholds, err := ParseThresholds(q)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	return thresholds, true
+}
+
+func buildAgentContext(agent models.Agent, turns []models.TurnUsage, thresholds []float64, withPoints bool) AgentContext {
+	ac := AgentContext{
+		AgentID:     agent.ID,
+		AgentName:   agent.Name,
+		Role:        agent.Role,
+		Turns:       len(turns),
+		Thresholds:  thresholds,
+		Crossings:   []ContextCrossing{},
+		Compactions: []ContextCompaction{},
+	}
+
+	armed := make([]bool, len(thresholds))
+	for i := range armed {
+		armed[i] = true
+	}
+
+	for i, t := range turns {
+		pct := 0.0
+		if t.ContextWindow > 0 {
+			pct = float64(t.ContextTokens) / float64(t.ContextWindow)
+		}
+
+		if withPoints {
+			ac.Points = append(ac.Points, ContextPoint{
+				TurnID:        t.ID,
+				At:            t.CreatedAt,
+				Model:         t.Model,
+				ContextTokens: t.ContextTokens,
+				OutputTokens:  t.OutputTokens,
+				ContextWindow: t.ContextWindow,
+				Pct:           pct,
+				Compacted:     t.Compacted,
+			})
+		}
+
+		if t.Compacted && i > 0 {
+			ac.Compactions = append(ac.Compactions, ContextCompaction{
+				TurnID:       t.ID,
+				At:           t.CreatedAt,
+				TokensBefore: turns[i-1].ContextTokens,
+				TokensAfter:  t.ContextTokens,
+			})
+		}
+
+		for j, threshold := range thresholds {
+			if pct < threshold {
+				armed[j] = true
+				continue
+			}
+			if armed[j] {
+				ac.Crossings = append(ac.Crossings, ContextCrossing{
+					Threshold:     threshold,
+					TurnID:        t.ID,
+					At:            t.CreatedAt,
+					ContextTokens: t.ContextTokens,
+					Pct:           pct,
+				})
+				armed[j] = false
+			}
+		}
+
+		if t.ContextTokens > ac.PeakTokens {
+			ac.PeakTokens = t.ContextTokens
+		}
+		if pct > ac.PeakPct {
+			ac.PeakPct = pct
+		}
+		ac.Model = t.Model
+		ac.ContextWindow = t.ContextWindow
+		ac.CurrentTokens = t.ContextTokens
+		ac.CurrentPct = pct
+	}
+
+	return ac
+}
@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"net/http"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"agent-observer/db"
+	"agent-observer/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// FileAgent is an agent that touched a file and how.
+type FileAgent struct {
+	AgentID   string `json:"agent_id"`
+	AgentName string `json:"agent_name"`
+	TeamID    string `json:"team_id"`
+	Reads     int    `json:"reads"`
+	Changes   int    `json:"changes"`
+}
+
+type FileSummary struct {
+	FilePath     string      `json:"file_path"`
+	Reads        int         `json:"reads"`
+	Writes       int         `json:"writes"`
+	Edits        int         `json:"edits"`
+	Failed       int         `json:"failed"`
+	Agents       []FileAgent `json:"agents"`
+	FirstTouched time.Time   `json:"first_touched"`
+	LastTouched  time.Time   `json:"last_touched"`
+}
+
+type FileTouchView struct {
+	models.FileTouch
+	AgentName string `json:"agent_name"`
+	TeamName  string `json:"team_name"`
+}
+
+// ListFiles returns the history of one file across all sessions when path
+// is given, oldest first, including the exact edits made. Without path it
+// lists every touched file, most recently touched first, optionally
+// restricted to agent_id.
+func ListFiles(c *gin.Context) {
+	path := c.Query("path")
+	if path == "" {
+		query := db.DB.Model(&models.FileTouch{})
+		if v := c.Query("agent_id"); v != "" {
+			query = query.Where("agent_id = ?", v)
+		}
+		respondFileSummaries(c, query)
+		return
+	}
+
+	var touches []models.FileTouch
+	if err := db.DB.Where("file_path = ?", filepath.Clean(path)).Order("created_at ASC").Find(&touches).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch file history"})
+		return
+	}
+
+	agentNames, teamNames := touchNames(touches)
+	history := make([]FileTouchView, 0, len(touches))
+	for _, t := range touches {
+		history = append(history, FileTouchView{
+			FileTouch: t,
+			AgentName: agentNames[t.AgentID],
+			TeamName:  teamNames[t.TeamID],
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"file_path": filepath.Clean(path),
+		"history":   history,
+	})
+}
+
+// ListTeamFiles lists the files a team's agents touched, most recently
+// touched first.
+func ListTeamFiles(c *gin.Context) {
+	respondFileSummaries(c, db.DB.Model(&models.FileTouch{}).Where("team_id = ?", c.Param("id")))
+}
+
+func respondFileSummaries(c *gin.Context, query *gorm.DB) {
+	var touches []models.FileTouch
+	if err := query.Select("id", "team_id", "agent_id", "file_path", "operation", "failed", "created_at").
+		Order("created_at ASC").Find(&touches).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch files"})
+		return
+	}
+	agentNames, _ := touchNames(touches)
+
+	files := make(map[string]*FileSummary)
+	agents := make(map[string]map[string]*FileAgent) // path -> agent -> stats
+	for _, t := range touches {
+		f, ok := files[t.FilePath]
+		if !ok {
+			f = &FileSummary{FilePath: t.FilePath, FirstTouched: t.CreatedAt}
+			files[t.FilePath] = f
+			agents[t.FilePath] = make(map[string]*FileAgent)
+		}
+		f.LastTouched = t.CreatedAt
+
+		a, ok := agents[t.FilePath][t.AgentID]
+		if !ok {
+			a = &FileAgent{AgentID: t.AgentID, AgentName: agentNames[t.AgentID], TeamID: t.TeamID}
+			agents[t.FilePath][t.AgentID] = a
+		}
+
+		if t.Failed {
+			f.Failed++
+			continue
+		}
+		switch t.Operation {
+		case models.FileOpRead:
+			f.Reads++
+			a.Reads++
+		case models.FileOpWrite:
+			f.Writes++
+			a.Changes++
+		default:
+			f.Edits++
+			a.Changes++
+		}
+	}
+
+	result := make([]FileSummary, 0, len(files))
+	for path, f := range files {
+		f.Agents = make([]FileAgent, 0, len(agents[path]))
+		for _, a := range agents[path] {
+			f.Agents = append(f.Agents, *a)
+		}
+		sort.Slice(f.Agents, func(i, j int) bool { return f.Agents[i].AgentID < f.Agents[j].AgentID })
+		result = append(result, *f)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].LastTouched.After(result[j].LastTouched) })
+
+	c.JSON(http.StatusOK, result)
+}
+
+// touchNames looks up the names of the agents and teams in touches.
+func touchNames(touches []models.FileTouch) (agentNames, teamNames map[string]string) {
+	agentNames = make(map[string]string)
+	teamNames = make(map[string]string)
+	var agentIDs, teamIDs []string
+	for _, t := range touches {
+		if _, ok := agentNames[t.AgentID]; !ok {
+			agentNames[t.AgentID] = ""
+			agentIDs = append(agentIDs, t.AgentID)
+		}
+		if _, ok := teamNames[t.TeamID]; !ok {
+			teamNames[t.TeamID] = ""
+			teamIDs = append(teamIDs, t.TeamID)
+		}
+	}
+	if len(agentIDs) > 0 {
+		var agents []models.Agent
+		db.DB.Where("id IN ?", agentIDs).Find(&agents)
+		for _, a := range agents {
+			agentNames[a.ID] = a.Name
+		}
+	}
+	if len(teamIDs) > 0 {
+		var teams []models.Team
+		db.DB.Where("id IN ?", teamIDs).Find(&teams)
+		for _, t := range teams {
+			teamNames[t.ID] = t.Name
+		}
+	}
+	return agentNames, teamNames
+}
@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"net/http"
+
+	"agent-observer/commgraph"
+	"agent-observer/db"
+	"agent-observer/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetTeamGraph returns who a session's agents talked to: delegations to
+// sub-agents, their results, and messages to and from teammates in other
+// sessions of the same Claude Code team. since and until limit the
+// interactions; ?format=dot downloads the graph as Graphviz DOT.
+func GetTeamGraph(c *gin.Context) {
+	id := c.Param("id")
+
+	var team models.Team
+	if err := db.DB.First(&team, "id = ?", id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Team not found"})
+		return
+	}
+
+	teams := []models.Team{team}
+	if team.TeamName != "" {
+		if err := db.DB.Where("team_name = ?", team.TeamName).Find(&teams).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch team group"})
+			return
+		}
+	}
+
+	graph, ok := buildGraph(c, teams)
+	if !ok {
+		return
+	}
+	own := make(map[string]bool)
+	for _, n := range graph.Nodes {
+		if n.TeamID == id {
+			own[n.ID] = true
+		}
+	}
+	respondGraph(c, team.Name, graph.Focus(own))
+}
+
+// GetTeamGroupGraph returns who the agents of all sessions in a Claude
+// Code team talked to. It takes the same parameters as GetTeamGraph.
+func GetTeamGroupGraph(c *gin.Context) {
+	teamName := c.Param("teamName")
+
+	var teams []models.Team
+	if err := db.DB.Where("team_name = ?", teamName).Find(&teams).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch team group"})
+		return
+	}
+	if len(teams) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Team group not found"})
+		return
+	}
+
+	graph, ok := buildGraph(c, teams)
+	if !ok {
+		return
+	}
+	respondGraph(c, teamName, graph)
+}
+
+func buildGraph(c *gin.Context, teams []models.Team) (commgraph.Graph, bool) {
+	ids := make([]string, len(teams))
+	for i, t := range teams {
+		ids[i] = t.ID
+	}
+
+	var agents []models.Agent
+	if err := db.DB.Where("team_id IN ?", ids).Find(&agents).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch agents"})
+		return commgraph.Graph{}, false
+	}
+
+	query, ok := timeWindow(c, db.DB.Where("team_id IN ?", ids), "created_at")
+	if !ok {
+		return commgraph.Graph{}, false
+	}
+	var interactions []models.Interaction
+	if err := query.Order("created_at ASC").Find(&interactions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch interactions"})
+		return commgraph.Graph{}, false
+	}
+
+	return commgraph.Build(teams, agents, interactions), true
+}
+
+func respondGraph(c *gin.Context, name string, graph commgraph.Graph) {
+	switch c.Query("format") {
+	case "", "json":
+		c.JSON(http.StatusOK, graph)
+	case "dot":
+		setAttachment(c, name+".dot")
+		c.Data(http.StatusOK, "text/vnd.graphviz; charset=utf-8", []byte(graph.DOT(name)))
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid format: " + c.Query("format")})
+	}
+}
@@ -0,0 +1,343 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"agent-observer/agentstate"
+	"agent-observer/datasync"
+	"agent-observer/db"
+	"agent-observer/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+)
+
+// ClaudeHookPayload is the JSON Claude Code sends to hook commands on stdin.
+// Fields not relevant to an event are left empty.
+type ClaudeHookPayload struct {
+	SessionID        string                 `json:"session_id" binding:"required"`
+	HookEventName    string                 `json:"hook_event_name" binding:"required"`
+	TranscriptPath   string                 `json:"transcript_path"`
+	Cwd              string                 `json:"cwd"`
+	Source           string                 `json:"source"`            // SessionStart: startup, resume, clear, compact
+	Prompt           string                 `json:"prompt"`            // UserPromptSubmit
+	ToolName         string                 `json:"tool_name"`         // PreToolUse, PostToolUse
+	ToolInput        map[string]interface{} `json:"tool_input"`        // PreToolUse, PostToolUse
+	ToolResponse     json.RawMessage        `json:"tool_response"`     // PostToolUse
+	ToolUseID        string                 `json:"tool_use_id"`       // PreToolUse, PostToolUse (newer Claude Code versions)
+	Message          string                 `json:"message"`           // Notification
+	NotificationType string                 `json:"notification_type"` // Notification (newer Claude Code versions)
+	AgentID          string                 `json:"agent_id"`          // set when the event comes from a sub-agent
+}
+
+// pendingHookSpans maps tool calls without a tool_use_id to the span opened
+// for them by PreToolUse, so PostToolUse can close the same span. Calls
+// that fail or are denied never get a PostToolUse, so entries older than
+// pendingHookSpanTTL are dropped.
+var pendingHookSpans = struct {
+	sync.Mutex
+	ids map[string]pendingHookSpan
+}{ids: make(map[string]pendingHookSpan)}
+
+type pendingHookSpan struct {
+	spanID    string
+	startedAt time.Time
+}
+
+const pendingHookSpanTTL = time.Hour
+
+// ClaudeHook receives Claude Code hook payloads (forwarded by the
+// observer-hook command) and turns them into live spans and agent statuses.
+// Events are correlated to the synced team, lead agent and conversation by
+// session_id.
+func ClaudeHook(c *gin.Context) {
+	var payload ClaudeHookPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := ensureHookSession(payload); err != nil {
+		log.Printf("Error registering session %s from hook: %v", payload.SessionID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to register session"})
+		return
+	}
+
+	agentID := hookAgentID(payload)
+	now := time.Now()
+
+	switch payload.HookEventName {
+	case "SessionStart":
+		setHookStatus(payload.SessionID, agentID, agentstate.AwaitingUser, now)
+	case "UserPromptSubmit":
+		setHookStatus(payload.SessionID, agentID, agentstate.Thinking, now)
+	case "PreToolUse":
+		startHookToolSpan(payload, agentID, now)
+		setHookStatus(payload.SessionID, agentID, agentstate.RunningTool, now)
+	case "PostToolUse":
+		endHookToolSpan(payload, now)
+		setHookStatus(payload.SessionID, agentID, agentstate.Thinking, now)
+	case "Notification":
+		// Unlike the transcript, hooks can tell a model that is thinking apart
+		// from one blocked on a permission prompt.
+		if isPermissionNotification(payload) {
+			setHookStatus(payload.SessionID, agentID, agentstate.AwaitingPermission, now)
+		} else {
+			setHookStatus(payload.SessionID, agentID, agentstate.AwaitingUser, now)
+		}
+	case "Stop":
+		setHookStatus(payload.SessionID, agentID, agentstate.AwaitingUser, now)
+	case "SubagentStop":
+		if payload.AgentID != "" {
+			setHookStatus(payload.SessionID, payload.AgentID, agentstate.Completed, now)
+		}
+		// The lead resumes once a sub-agent hands back its result.
+		setHookStatus(payload.SessionID, datasync.LeadAgentID(payload.SessionID), agentstate.Thinking, now)
+	default:
+		// Other hook events are accepted so registering extra hooks is harmless.
+		c.JSON(http.StatusOK, gin.H{"status": "ignored"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// ensureHookSession creates placeholder team, lead agent and conversation
+// records for sessions whose transcript hasn't been synced yet. The next
+// sync fills in the real names.
+func ensureHookSession(payload ClaudeHookPayload) error {
+	var count int64
+	if err := db.DB.Model(&models.Team{}).Where("id = ?", payload.SessionID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+
+	now := time.Now()
+	name := "session-" + payload.SessionID
+	if len(payload.SessionID) > 8 {
+		name = "session-" + payload.SessionID[:8]
+	}
+	leadAgentID := datasync.LeadAgentID(payload.SessionID)
+
+	team := models.Team{
+		ID:          payload.SessionID,
+		Name:        name,
+		Description: fmt.Sprintf("Claude Code session: %s", name),
+		CreatedBy:   "claude-code",
+		Status:      agentstate.TeamIdle,
+		CreatedAt:   now,
+	}
+	if err := db.DB.Create(&team).Error; err != nil {
+		return err
+	}
+	lead := models.Agent{
+		ID:        leadAgentID,
+		TeamID:    payload.SessionID,
+		Role:      "lead",
+		Name:      name,
+		Specialty: "Main Claude Code session",
+		Status:    agentstate.Idle,
+		CreatedAt: now,
+	}
+	if err := db.DB.Create(&lead).Error; err != nil {
+		return err
+	}
+	conv := models.Conversation{
+		ID:           datasync.ConversationID(payload.SessionID),
+		TeamID:       payload.SessionID,
+		AgentID:      leadAgentID,
+		Title:        name,
+		StartedAt:    now,
+		StartPending: true,
+	}
+	return db.DB.Create(&conv).Error
+}
+
+// hookAgentID returns the agent an event belongs to: the sub-agent named in
+// the payload if it is known, otherwise the session's lead agent.
+func hookAgentID(payload ClaudeHookPayload) string {
+	if payload.AgentID != "" {
+		var count int64
+		db.DB.Model(&models.Agent{}).Where("id = ? AND team_id = ?", payload.AgentID, payload.SessionID).Count(&count)
+		if count > 0 {
+			return payload.AgentID
+		}
+	}
+	return datasync.LeadAgentID(payload.SessionID)
+}
+
+// setHookStatus records a hook-reported agent status and re-derives the
+// session's team status. Transitions are published by agentstate.
+func setHookStatus(sessionID, agentID, status string, at time.Time) {
+	if err := agentstate.UpdateAgent(agentID, status, at, true); err != nil {
+		log.Printf("Warning: failed to update status of agent %s: %v", agentID, err)
+		return
+	}
+	if err := agentstate.RefreshTeam(sessionID); err != nil {
+		log.Printf("Warning: failed to refresh status of team %s: %v", sessionID, err)
+	}
+}
+
+// startHookToolSpan opens a tool span for a PreToolUse event. Spans are keyed
+// by tool_use_id so the transcript sync later fills in the same record.
+func startHookToolSpan(payload ClaudeHookPayload, agentID string, at time.Time) {
+	spanID := payload.ToolUseID
+	if spanID == "" {
+		spanID = uuid.New().String()
+		pendingHookSpans.Lock()
+		for key, p := range pendingHookSpans.ids {
+			if at.Sub(p.startedAt) > pendingHookSpanTTL {
+				delete(pendingHookSpans.ids, key)
+			}
+		}
+		pendingHookSpans.ids[hookToolKey(payload)] = pendingHookSpan{spanID: spanID, startedAt: at}
+		pendingHookSpans.Unlock()
+	}
+
+	attrs := map[string]interface{}{
+		"tool_name": payload.ToolName,
+		"input":     payload.ToolInput,
+		"source":    "hook",
+	}
+	attrsJSON, _ := json.Marshal(attrs)
+
+	trace := models.Trace{
+		ID:             spanID,
+		TeamID:         payload.SessionID,
+		AgentID:        agentID,
+		ConversationID: datasync.ConversationID(payload.SessionID),
+		SpanName:       "tool." + payload.ToolName,
+		Attributes:     datatypes.JSON(attrsJSON),
+		StartTime:      at,
+	}
+	if err := db.DB.Create(&trace).Error; err != nil {
+		// The transcript sync may have created the span already; take it over.
+		if err := db.DB.Model(&models.Trace{}).Where("id = ?", spanID).
+			Updates(map[string]interface{}{"attributes": trace.Attributes, "start_time": at, "end_time": nil, "status": models.SpanStatusUnset, "status_message": ""}).Error; err != nil {
+			log.Printf("Warning: failed to record hook span %s: %v", spanID, err)
+			return
+		}
+	}
+
+	WSHub.Broadcast(gin.H{
+		"type": "new_trace",
+		"data": trace,
+	})
+}
+
+// endHookToolSpan closes the span opened by the matching PreToolUse event.
+func endHookToolSpan(payload ClaudeHookPayload, at time.Time) {
+	spanID := payload.ToolUseID
+	if spanID == "" {
+		key := hookToolKey(payload)
+		pendingHookSpans.Lock()
+		spanID = pendingHookSpans.ids[key].spanID
+		delete(pendingHookSpans.ids, key)
+		pendingHookSpans.Unlock()
+	}
+	if spanID == "" {
+		return
+	}
+
+	var trace models.Trace
+	if err := db.DB.First(&trace, "id = ?", spanID).Error; err != nil {
+		return
+	}
+
+	attrs := make(map[string]interface{})
+	if len(trace.Attributes) > 0 {
+		_ = json.Unmarshal(trace.Attributes, &attrs)
+	}
+	attrs["source"] = "hook"
+	if len(payload.ToolResponse) > 0 {
+		result := string(payload.ToolResponse)
+		if len(result) > 5000 {
+			result = result[:5000] + "...(已截断)"
+		}
+		attrs["result"] = result
+	}
+	attrsJSON, _ := json.Marshal(attrs)
+
+	updates := map[string]interface{}{
+		"end_time":       at,
+		"attributes":     datatypes.JSON(attrsJSON),
+		"status":         models.SpanStatusOK,
+		"status_message": "",
+	}
+	if msg, failed := hookToolError(payload.ToolResponse); failed {
+		updates["status"] = models.SpanStatusError
+		updates["status_message"] = msg
+	}
+	if err := db.DB.Model(&trace).Updates(updates).Error; err != nil {
+		log.Printf("Warning: failed to end hook span %s: %v", spanID, err)
+		return
+	}
+	db.DB.First(&trace, "id = ?", spanID)
+
+	WSHub.Broadcast(gin.H{
+		"type": "span_ended",
+		"data": trace,
+	})
+}
+
+// hookToolError reports whether a PostToolUse tool_response describes a
+// failure, such as an MCP result with is_error or a response with
+// success: false, and returns its error text.
+func hookToolError(response json.RawMessage) (string, bool) {
+	var r struct {
+		IsError bool            `json:"is_error"`
+		Success *bool           `json:"success"`
+		Error   json.RawMessage `json:"error"`
+		Content json.RawMessage `json:"content"`
+	}
+	if len(response) == 0 || json.Unmarshal(response, &r) != nil {
+		return "", false
+	}
+	if !r.IsError && (r.Success == nil || *r.Success) {
+		return "", false
+	}
+	msg := ""
+	for _, raw := range []json.RawMessage{r.Error, r.Content} {
+		if len(raw) == 0 || string(raw) == "null" {
+			continue
+		}
+		if err := json.Unmarshal(raw, &msg); err != nil {
+			msg = string(raw)
+		}
+		break
+	}
+	if n := 500; len(msg) > n {
+		// Don't split a UTF-8 character.
+		for n > 0 && !utf8.RuneStart(msg[n]) {
+			n--
+		}
+		msg = msg[:n] + "...(已截断)"
+	}
+	return msg, true
+}
+
+// hookToolKey identifies a tool call by session, tool and input for payloads
+// from Claude Code versions that don't send tool_use_id.
+func hookToolKey(payload ClaudeHookPayload) string {
+	input, _ := json.Marshal(payload.ToolInput)
+	return payload.SessionID + "\x00" + payload.ToolName + "\x00" + string(input)
+}
+
+// isPermissionNotification reports whether a Notification event means
+// Claude is blocked on a permission prompt rather than idle.
+func isPermissionNotification(payload ClaudeHookPayload) bool {
+	if payload.NotificationType != "" {
+		return payload.NotificationType == "permission_prompt"
+	}
+	return strings.Contains(strings.ToLower(payload.Message), "permission")
+}
@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"agent-observer/db"
+	"agent-observer/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm/clause"
+)
+
+// IdempotencyKeyHeader is the request header clients use to make ingest
+// requests safe to retry.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// IdempotencyKeyTTL is how long an Idempotency-Key is remembered. Clients
+// retry within minutes, so older keys are pruned.
+var IdempotencyKeyTTL = 24 * time.Hour
+
+// idempotencyPrune limits pruning of expired keys to once per interval.
+var idempotencyPrune = struct {
+	sync.Mutex
+	last time.Time
+}{}
+
+const idempotencyPruneInterval = 10 * time.Minute
+
+// Idempotency key kinds.
+const (
+	idempotencyKindMessage = "message"
+	idempotencyKindTrace   = "trace"
+	idempotencyKindSpanEnd = "span_end"
+)
+
+// lookupIdempotencyKey returns the ID of the record previously produced by a
+// request carrying the same Idempotency-Key header and kind, if any.
+func lookupIdempotencyKey(c *gin.Context, kind string) (string, bool) {
+	key := c.GetHeader(IdempotencyKeyHeader)
+	if key == "" {
+		return "", false
+	}
+	var rec models.IdempotencyKey
+	if err := db.DB.First(&rec, "key = ? AND kind = ? AND created_at >= ?", key, kind, time.Now().Add(-IdempotencyKeyTTL)).Error; err != nil {
+		return "", false
+	}
+	return rec.RecordID, true
+}
+
+// saveIdempotencyKey records that the request's Idempotency-Key produced recordID.
+func saveIdempotencyKey(c *gin.Context, kind, recordID string) {
+	key := c.GetHeader(IdempotencyKeyHeader)
+	if key == "" {
+		return
+	}
+	now := time.Now()
+	pruneIdempotencyKeys(now)
+	// An expired key that wasn't pruned yet is taken over.
+	db.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "key"}, {Name: "kind"}},
+		DoUpdates: clause.AssignmentColumns([]string{"record_id", "created_at"}),
+		Where:     clause.Where{Exprs: []clause.Expression{clause.Lt{Column: "idempotency_keys.created_at", Value: now.Add(-IdempotencyKeyTTL)}}},
+	}).Create(&models.IdempotencyKey{
+		Key:       key,
+		Kind:      kind,
+		RecordID:  recordID,
+		CreatedAt: now,
+	})
+}
+
+// pruneIdempotencyKeys deletes keys older than IdempotencyKeyTTL, at most
+// once per idempotencyPruneInterval.
+func pruneIdempotencyKeys(now time.Time) {
+	idempotencyPrune.Lock()
+	defer idempotencyPrune.Unlock()
+	if now.Sub(idempotencyPrune.last) < idempotencyPruneInterval {
+		return
+	}
+	idempotencyPrune.last = now
+	if err := db.DB.Where("created_at < ?", now.Add(-IdempotencyKeyTTL)).Delete(&models.IdempotencyKey{}).Error; err != nil {
+		log.Printf("Warning: failed to prune idempotency keys: %v", err)
+	}
+}
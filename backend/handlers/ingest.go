@@ -0,0 +1,403 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"agent-observer/db"
+	"agent-observer/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// LenientIngest controls how ingest endpoints treat references to teams,
+// agents and conversations that don't exist. When false (the default) such
+// requests are rejected with 422; when true the missing records are created
+// as placeholders so custom frameworks can log without registering first.
+var LenientIngest = false
+
+// errUnknownRef is returned by ensureRefs when a referenced record is missing
+// and lenient ingest is off.
+type errUnknownRef struct {
+	kind string
+	id   string
+}
+
+func (e *errUnknownRef) Error() string {
+	return fmt.Sprintf("Unknown %s: %s", e.kind, e.id)
+}
+
+// ingestRefs are the IDs an ingest request points to. Empty IDs are skipped.
+type ingestRefs struct {
+	TeamID         string
+	AgentID        string
+	ConversationID string
+}
+
+// ensureRefs checks that every referenced record exists, creating
+// placeholders for missing ones in lenient mode.
+func ensureRefs(refs ingestRefs) error {
+	now := time.Now()
+
+	if refs.TeamID != "" {
+		err := ensureRecord(&models.Team{}, "team", refs.TeamID, &models.Team{
+			ID:        refs.TeamID,
+			Name:      refs.TeamID,
+			CreatedBy: "ingest",
+			Status:    "idle",
+			CreatedAt: now,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if refs.AgentID != "" {
+		err := ensureRecord(&models.Agent{}, "agent", refs.AgentID, &models.Agent{
+			ID:        refs.AgentID,
+			TeamID:    refs.TeamID,
+			Role:      "teammate",
+			Name:      refs.AgentID,
+			Status:    "idle",
+			CreatedAt: now,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if refs.ConversationID != "" {
+		err := ensureRecord(&models.Conversation{}, "conversation", refs.ConversationID, &models.Conversation{
+			ID:        refs.ConversationID,
+			TeamID:    refs.TeamID,
+			AgentID:   refs.AgentID,
+			Title:     refs.ConversationID,
+			StartedAt: now,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ensureRecord looks up id in model's table and, if it's missing, either
+// creates placeholder (lenient mode) or returns errUnknownRef.
+func ensureRecord(model interface{}, kind, id string, placeholder interface{}) error {
+	var count int64
+	if err := db.DB.Model(model).Where("id = ?", id).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+	if !LenientIngest {
+		return &errUnknownRef{kind: kind, id: id}
+	}
+	return db.DB.Create(placeholder).Error
+}
+
+// respondRefError writes the response for an ensureRefs failure.
+func respondRefError(c *gin.Context, err error) {
+	var unknown *errUnknownRef
+	if errors.As(err, &unknown) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": unknown.Error()})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to validate references"})
+}
+
+// RegisterTeamReq registers a team. Optional fields left out of a
+// re-registration keep their stored values.
+type RegisterTeamReq struct {
+	ID          string  `json:"id,omitempty"` // optional client-generated ID
+	Name        string  `json:"name" binding:"required"`
+	Description *string `json:"description"`
+	CreatedBy   string  `json:"created_by"`
+	TeamName    *string `json:"team_name"`
+	Status      *string `json:"status"`
+}
+
+// RegisterTeam creates a team, or updates it if the ID is already registered.
+func RegisterTeam(c *gin.Context) {
+	var req RegisterTeamReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Status != nil && *req.Status != "" && !validTeamStatus(*req.Status) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid status: " + *req.Status})
+		return
+	}
+
+	var team models.Team
+	if req.ID != "" && db.DB.First(&team, "id = ?", req.ID).Error == nil {
+		updates := map[string]interface{}{"name": req.Name}
+		if req.Description != nil {
+			updates["description"] = *req.Description
+		}
+		if req.TeamName != nil {
+			updates["team_name"] = *req.TeamName
+		}
+		if req.Status != nil && *req.Status != "" {
+			updates["status"] = *req.Status
+		}
+		if err := db.DB.Model(&team).Updates(updates).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update team"})
+			return
+		}
+		db.DB.First(&team, "id = ?", team.ID)
+		c.JSON(http.StatusOK, team)
+		return
+	}
+
+	id := req.ID
+	if id == "" {
+		id = uuid.New().String()
+	}
+	createdBy := req.CreatedBy
+	if createdBy == "" {
+		createdBy = "ingest"
+	}
+
+	team = models.Team{
+		ID:          id,
+		Name:        req.Name,
+		Description: stringOr(req.Description, ""),
+		CreatedBy:   createdBy,
+		Status:      stringOr(req.Status, "idle"),
+		TeamName:    stringOr(req.TeamName, ""),
+		CreatedAt:   time.Now(),
+	}
+	if err := db.DB.Create(&team).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create team"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, team)
+}
+
+type UpdateTeamStatusReq struct {
+	Status string `json:"status" binding:"required"`
+}
+
+func UpdateTeamStatus(c *gin.Context) {
+	id := c.Param("id")
+
+	var req UpdateTeamStatusReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !validTeamStatus(req.Status) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid status: " + req.Status})
+		return
+	}
+
+	var team models.Team
+	if err := db.DB.First(&team, "id = ?", id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Team not found"})
+		return
+	}
+
+	if err := db.DB.Model(&team).Update("status", req.Status).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update team status"})
+		return
+	}
+	team.Status = req.Status
+
+	WSHub.Broadcast(gin.H{
+		"type": "team_status_changed",
+		"data": gin.H{"team_id": team.ID, "status": team.Status},
+	})
+
+	c.JSON(http.StatusOK, team)
+}
+
+// RegisterAgentReq registers an agent. Optional fields left out of a
+// re-registration keep their stored values.
+type RegisterAgentReq struct {
+	ID        string  `json:"id,omitempty"` // optional client-generated ID
+	TeamID    string  `json:"team_id" binding:"required"`
+	Role      *string `json:"role"`
+	Name      string  `json:"name" binding:"required"`
+	Specialty *string `json:"specialty"`
+	Status    *string `json:"status"`
+}
+
+// RegisterAgent creates an agent, or updates it if the ID is already registered.
+func RegisterAgent(c *gin.Context) {
+	var req RegisterAgentReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if role := stringOr(req.Role, ""); role != "" && role != "lead" && role != "teammate" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role: " + role})
+		return
+	}
+
+	if err := ensureRefs(ingestRefs{TeamID: req.TeamID}); err != nil {
+		respondRefError(c, err)
+		return
+	}
+
+	var agent models.Agent
+	if req.ID != "" && db.DB.First(&agent, "id = ?", req.ID).Error == nil {
+		updates := map[string]interface{}{"team_id": req.TeamID, "name": req.Name}
+		if req.Role != nil && *req.Role != "" {
+			updates["role"] = *req.Role
+		}
+		if req.Specialty != nil {
+			updates["specialty"] = *req.Specialty
+		}
+		if req.Status != nil && *req.Status != "" {
+			updates["status"] = *req.Status
+		}
+		if err := db.DB.Model(&agent).Updates(updates).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update agent"})
+			return
+		}
+		db.DB.First(&agent, "id = ?", agent.ID)
+		c.JSON(http.StatusOK, agent)
+		return
+	}
+
+	id := req.ID
+	if id == "" {
+		id = uuid.New().String()
+	}
+
+	agent = models.Agent{
+		ID:        id,
+		TeamID:    req.TeamID,
+		Role:      stringOr(req.Role, "teammate"),
+		Name:      req.Name,
+		Specialty: stringOr(req.Specialty, ""),
+		Status:    stringOr(req.Status, "idle"),
+		CreatedAt: time.Now(),
+	}
+	if err := db.DB.Create(&agent).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create agent"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, agent)
+}
+
+type StartConversationReq struct {
+	ID        string     `json:"id,omitempty"` // optional client-generated ID
+	TeamID    string     `json:"team_id" binding:"required"`
+	AgentID   string     `json:"agent_id" binding:"required"`
+	Title     string     `json:"title"`
+	StartedAt *time.Time `json:"started_at,omitempty"`
+}
+
+// StartConversation creates a conversation. Starting an already registered
+// conversation returns it unchanged.
+func StartConversation(c *gin.Context) {
+	var req StartConversationReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.ID != "" {
+		var existing models.Conversation
+		if err := db.DB.First(&existing, "id = ?", req.ID).Error; err == nil {
+			c.JSON(http.StatusOK, existing)
+			return
+		}
+	}
+
+	if err := ensureRefs(ingestRefs{TeamID: req.TeamID, AgentID: req.AgentID}); err != nil {
+		respondRefError(c, err)
+		return
+	}
+
+	id := req.ID
+	if id == "" {
+		id = uuid.New().String()
+	}
+	startedAt := time.Now()
+	if req.StartedAt != nil {
+		startedAt = *req.StartedAt
+	}
+
+	conv := models.Conversation{
+		ID:        id,
+		TeamID:    req.TeamID,
+		AgentID:   req.AgentID,
+		Title:     req.Title,
+		StartedAt: startedAt,
+	}
+	if err := db.DB.Create(&conv).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start conversation"})
+		return
+	}
+
+	WSHub.Broadcast(gin.H{
+		"type": "conversation_started",
+		"data": conv,
+	})
+
+	c.JSON(http.StatusCreated, conv)
+}
+
+type EndConversationReq struct {
+	EndedAt *time.Time `json:"ended_at,omitempty"`
+}
+
+func EndConversation(c *gin.Context) {
+	id := c.Param("id")
+
+	var req EndConversationReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var conv models.Conversation
+	if err := db.DB.First(&conv, "id = ?", id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Conversation not found"})
+		return
+	}
+
+	endedAt := time.Now()
+	if req.EndedAt != nil {
+		endedAt = *req.EndedAt
+	}
+	if err := db.DB.Model(&conv).Update("ended_at", endedAt).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to end conversation"})
+		return
+	}
+	conv.EndedAt = &endedAt
+
+	WSHub.Broadcast(gin.H{
+		"type": "conversation_ended",
+		"data": conv,
+	})
+
+	c.JSON(http.StatusOK, conv)
+}
+
+// stringOr returns *s, or def if s is nil or empty.
+func stringOr(s *string, def string) string {
+	if s == nil || *s == "" {
+		return def
+	}
+	return *s
+}
+
+// validTeamStatus reports whether status is a known team status.
+func validTeamStatus(status string) bool {
+	switch status {
+	case "running", "stopped", "idle", "error":
+		return true
+	}
+	return false
+}
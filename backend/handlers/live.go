@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"agent-observer/agentstate"
+	"agent-observer/db"
+	"agent-observer/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// LiveToolCall is a tool call that has started but has no result yet.
+type LiveToolCall struct {
+	SpanID       string    `json:"span_id"`
+	ToolName     string    `json:"tool_name"`
+	InputSummary string    `json:"input_summary"`
+	StartedAt    time.Time `json:"started_at"`
+	RunningMs    int64     `json:"running_ms"`
+}
+
+// LiveTokenUsage is the token usage of an agent's most recent model call.
+// ContextTokens is everything the model saw: fresh input plus cached prompt.
+type LiveTokenUsage struct {
+	InputTokens   int       `json:"input_tokens"`
+	OutputTokens  int       `json:"output_tokens"`
+	CacheCreation int       `json:"cache_creation"`
+	CacheRead     int       `json:"cache_read"`
+	ContextTokens int       `json:"context_tokens"`
+	At            time.Time `json:"at"`
+}
+
+type LiveAgent struct {
+	models.Agent
+	TeamName      string          `json:"team_name"`
+	InFlightTools []LiveToolCall  `json:"in_flight_tools"`
+	LastText      string          `json:"last_text,omitempty"`
+	LastTextAt    *time.Time      `json:"last_text_at,omitempty"`
+	TokenUsage    *LiveTokenUsage `json:"token_usage,omitempty"`
+}
+
+// GetLive lists every agent that is working right now, with what it is
+// doing: in-flight tool calls, its last text and its current context
+// usage. Agents that went quiet more than StaleAfter ago are left out even
+// before the evaluator marks them stale.
+func GetLive(c *gin.Context) {
+	cutoff := time.Now().Add(-agentstate.StaleAfter)
+	var agents []models.Agent
+	if err := db.DB.Where("status IN ? AND last_activity_at >= ?", agentstate.ActiveStatuses, cutoff).
+		Order("last_activity_at DESC").Find(&agents).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch agents"})
+		return
+	}
+
+	now := time.Now()
+	teamNames := make(map[string]string)
+	result := make([]LiveAgent, 0, len(agents))
+	for _, agent := range agents {
+		name, ok := teamNames[agent.TeamID]
+		if !ok {
+			var team models.Team
+			if db.DB.First(&team, "id = ?", agent.TeamID).Error == nil {
+				name = team.Name
+			}
+			teamNames[agent.TeamID] = name
+		}
+
+		live := LiveAgent{
+			Agent:         agent,
+			TeamName:      name,
+			InFlightTools: inFlightTools(agent.ID, now),
+			TokenUsage:    latestTokenUsage(agent.ID),
+		}
+
+		var last models.Message
+		if err := db.DB.Where("agent_id = ? AND role IN ? AND content != ''", agent.ID, []string{"agent", "teammate_message"}).
+			Order("created_at DESC").First(&last).Error; err == nil {
+			live.LastText = truncate(last.Content, 300)
+			live.LastTextAt = &last.CreatedAt
+		}
+
+		result = append(result, live)
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// inFlightTools returns an agent's open tool spans, oldest first.
+func inFlightTools(agentID string, now time.Time) []LiveToolCall {
+	var traces []models.Trace
+	db.DB.Where("agent_id = ? AND span_name LIKE ? AND end_time IS NULL", agentID, "tool.%").
+		Order("start_time ASC").Find(&traces)
+
+	calls := make([]LiveToolCall, 0, len(traces))
+	for _, t := range traces {
+		var attrs struct {
+			ToolName string                 `json:"tool_name"`
+			Input    map[string]interface{} `json:"input"`
+		}
+		_ = json.Unmarshal(t.Attributes, &attrs)
+		if attrs.ToolName == "" {
+			attrs.ToolName = strings.TrimPrefix(t.SpanName, "tool.")
+		}
+
+		calls = append(calls, LiveToolCall{
+			SpanID:       t.ID,
+			ToolName:     attrs.ToolName,
+			InputSummary: summarizeToolInput(attrs.ToolName, attrs.Input),
+			StartedAt:    t.StartTime,
+			RunningMs:    now.Sub(t.StartTime).Milliseconds(),
+		})
+	}
+	return calls
+}
+
+// latestTokenUsage returns the usage recorded on an agent's most recent
+// message that has any, or nil.
+func latestTokenUsage(agentID string) *LiveTokenUsage {
+	var msg models.Message
+	if err := db.DB.Where("agent_id = ? AND json_extract(raw_thoughts, '$.token_usage') IS NOT NULL", agentID).
+		Order("created_at DESC").First(&msg).Error; err != nil {
+		return nil
+	}
+
+	var thoughts struct {
+		TokenUsage struct {
+			Input         int `json:"input"`
+			Output        int `json:"output"`
+			CacheCreation int `json:"cache_creation"`
+			CacheRead     int `json:"cache_read"`
+		} `json:"token_usage"`
+	}
+	if err := json.Unmarshal(msg.RawThoughts, &thoughts); err != nil {
+		return nil
+	}
+	u := thoughts.TokenUsage
+	return &LiveTokenUsage{
+		InputTokens:   u.Input,
+		OutputTokens:  u.Output,
+		CacheCreation: u.CacheCreation,
+		CacheRead:     u.CacheRead,
+		ContextTokens: u.Input + u.CacheCreation + u.CacheRead,
+		At:            msg.CreatedAt,
+	}
+}
+
+// summarizeToolInput returns a one-line description of a tool call's input:
+// the command, path, pattern or URL it works on, or the raw input as JSON.
+func summarizeToolInput(toolName string, input map[string]interface{}) string {
+	for _, key := range []string{"command", "file_path", "notebook_path", "pattern", "url", "query", "description", "prompt"} {
+		if v, ok := input[key].(string); ok && v != "" {
+			return truncate(strings.Join(strings.Fields(v), " "), 200)
+		}
+	}
+	if len(input) == 0 {
+		return ""
+	}
+	b, err := json.Marshal(input)
+	if err != nil {
+		return fmt.Sprintf("%s(...)", toolName)
+	}
+	return truncate(string(b), 200)
+}
+
+// truncate shortens s to at most n bytes without splitting a UTF-8 character.
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n] + "..."
+}
@@ -13,6 +13,7 @@ import (
 )
 
 type LogMessageReq struct {
+	ID             string         `json:"id,omitempty"` // optional client-generated ID
 	ConversationID string         `json:"conversation_id" binding:"required"`
 	TeamID         string         `json:"team_id" binding:"required"`
 	AgentID        *string        `json:"agent_id,omitempty"`
@@ -28,8 +29,34 @@ func LogMessage(c *gin.Context) {
 		return
 	}
 
+	// Retries of an already stored message return the stored record.
+	if id, ok := lookupIdempotencyKey(c, idempotencyKindMessage); ok {
+		req.ID = id
+	}
+	if req.ID != "" {
+		var existing models.Message
+		if err := db.DB.First(&existing, "id = ?", req.ID).Error; err == nil {
+			c.JSON(http.StatusOK, existing)
+			return
+		}
+	}
+
+	refs := ingestRefs{TeamID: req.TeamID, ConversationID: req.ConversationID}
+	if req.AgentID != nil {
+		refs.AgentID = *req.AgentID
+	}
+	if err := ensureRefs(refs); err != nil {
+		respondRefError(c, err)
+		return
+	}
+
+	id := req.ID
+	if id == "" {
+		id = uuid.New().String()
+	}
+
 	msg := models.Message{
-		ID:             uuid.New().String(),
+		ID:             id,
 		ConversationID: req.ConversationID,
 		TeamID:         req.TeamID,
 		AgentID:        req.AgentID,
@@ -40,9 +67,16 @@ func LogMessage(c *gin.Context) {
 	}
 
 	if err := db.DB.Create(&msg).Error; err != nil {
+		// A concurrent retry may have inserted the same ID first.
+		var existing models.Message
+		if db.DB.First(&existing, "id = ?", msg.ID).Error == nil {
+			c.JSON(http.StatusOK, existing)
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log message"})
 		return
 	}
+	saveIdempotencyKey(c, idempotencyKindMessage, msg.ID)
 
 	// Broadcast to WebSocket clients
 	WSHub.Broadcast(gin.H{
@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"agent-observer/db"
+	"agent-observer/diff"
+	"agent-observer/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetTeamPatch returns the file changes a session's agents made as unified
+// diffs, oldest first, optionally restricted to agent_id. With
+// ?format=patch the combined patch is downloaded as a .patch file.
+func GetTeamPatch(c *gin.Context) {
+	id := c.Param("id")
+
+	var team models.Team
+	if err := db.DB.First(&team, "id = ?", id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Team not found"})
+		return
+	}
+
+	var include func(models.FileTouch) bool
+	if agentID := c.Query("agent_id"); agentID != "" {
+		include = func(t models.FileTouch) bool { return t.AgentID == agentID }
+	}
+	respondPatch(c, id, id, include)
+}
+
+// GetAgentPatch returns the file changes one agent made. The rest of its
+// team's file activity is replayed too, so edits get line numbers from
+// files other agents read.
+func GetAgentPatch(c *gin.Context) {
+	id := c.Param("id")
+
+	var agent models.Agent
+	if err := db.DB.First(&agent, "id = ?", id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Agent not found"})
+		return
+	}
+
+	respondPatch(c, id, agent.TeamID, func(t models.FileTouch) bool { return t.AgentID == id })
+}
+
+func respondPatch(c *gin.Context, name, teamID string, include func(models.FileTouch) bool) {
+	var touches []models.FileTouch
+	if err := db.DB.Where("team_id = ?", teamID).Order("created_at ASC").Find(&touches).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch file changes"})
+		return
+	}
+
+	root := c.Query("root")
+	changes := diff.Reconstruct(touches, include, func(path string) string { return patchPath(path, root) })
+	patch := diff.Patch(changes)
+
+	if c.Query("format") == "patch" {
+		setAttachment(c, name+".patch")
+		c.Data(http.StatusOK, "text/x-patch; charset=utf-8", []byte(patch))
+		return
+	}
+
+	if changes == nil {
+		changes = []diff.Change{}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"changes": changes,
+		"patch":   patch,
+	})
+}
+
+// setAttachment makes the response a download saved as filename. Team
+// names can hold quotes or non-ASCII characters, so the header parameter
+// is quoted or RFC 2231 encoded as needed.
+func setAttachment(c *gin.Context, filename string) {
+	disposition := mime.FormatMediaType("attachment", map[string]string{"filename": filename})
+	if disposition == "" {
+		disposition = "attachment"
+	}
+	c.Header("Content-Disposition", disposition)
+}
+
+// patchPath makes a file path relative to root for diff headers. Paths
+// outside root, or any path when root is empty, keep their full path
+// without the leading slash.
+func patchPath(path, root string) string {
+	if root != "" {
+		if rel, err := filepath.Rel(filepath.Clean(root), path); err == nil && !strings.HasPrefix(rel, "..") {
+			return filepath.ToSlash(rel)
+		}
+	}
+	return strings.TrimPrefix(filepath.ToSlash(path), "/")
+}
@@ -13,15 +13,51 @@ import (
 
 type TeamWithStats struct {
 	models.Team
-	AgentCount        int64 `json:"agent_count"`
-	ConversationCount int64 `json:"conversation_count"`
-	MessageCount      int64 `json:"message_count"`
+	AgentCount        int64  `json:"agent_count"`
+	ConversationCount int64  `json:"conversation_count"`
+	MessageCount      int64  `json:"message_count"`
+	Outcome           string `json:"outcome,omitempty"` // of the most recent conversation
 }
 
+// TeamGroup is a Claude Code agent team: its synced sessions, the members
+// from its config with the session each is running as, its shared task
+// list and its members' inboxes.
+type TeamGroup struct {
+	Teams      []TeamWithStats       `json:"teams"`
+	Members    []TeamGroupMember     `json:"members"`
+	Tasks      []models.TeamTask     `json:"tasks"`
+	TaskCounts map[string]int        `json:"task_counts"` // by status
+	Inbox      []models.InboxMessage `json:"inbox"`
+	EndedAt    *time.Time            `json:"ended_at,omitempty"` // set once Claude Code cleaned up the team
+}
+
+// TeamGroupMember is a team member linked to its latest synced session.
+type TeamGroupMember struct {
+	models.TeamMember
+	TeamID  string `json:"team_id,omitempty"`
+	AgentID string `json:"agent_id,omitempty"` // the session's lead agent
+	Status  string `json:"status,omitempty"`
+}
+
+// ListTeams lists teams, most recent first. ?outcome= keeps teams with a
+// conversation that ended that way.
 func ListTeams(c *gin.Context) {
+	query := db.DB.Order("created_at DESC")
+	if v := c.Query("outcome"); v != "" {
+		valid := false
+		for _, o := range models.Outcomes {
+			valid = valid || o == v
+		}
+		if !valid {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid outcome: " + v})
+			return
+		}
+		query = query.Where("id IN (?)", db.DB.Model(&models.Conversation{}).Select("team_id").Where("outcome = ?", v))
+	}
+
 	var teams []models.Team
 	// Sort by most recently created (which corresponds to most recently active for synced sessions)
-	if err := db.DB.Order("created_at DESC").Find(&teams).Error; err != nil {
+	if err := query.Find(&teams).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch teams"})
 		return
 	}
@@ -32,12 +68,15 @@ func ListTeams(c *gin.Context) {
 		db.DB.Model(&models.Agent{}).Where("team_id = ?", team.ID).Count(&agentCount)
 		db.DB.Model(&models.Conversation{}).Where("team_id = ?", team.ID).Count(&convCount)
 		db.DB.Model(&models.Message{}).Where("team_id = ?", team.ID).Count(&msgCount)
+		var outcome string
+		db.DB.Model(&models.Conversation{}).Where("team_id = ?", team.ID).Order("started_at DESC").Limit(1).Pluck("outcome", &outcome)
 
 		result = append(result, TeamWithStats{
 			Team:              team,
 			AgentCount:        agentCount,
 			ConversationCount: convCount,
 			MessageCount:      msgCount,
+			Outcome:           outcome,
 		})
 	}
 
@@ -78,6 +117,7 @@ type CreateTeamReq struct {
 	CreatedBy   string `json:"created_by"`
 }
 
+// GetTeamGroup returns a Claude Code agent team, see TeamGroup.
 func GetTeamGroup(c *gin.Context) {
 	teamName := c.Param("teamName")
 
@@ -87,14 +127,14 @@ func GetTeamGroup(c *gin.Context) {
 		return
 	}
 
-	var result []TeamWithStats
+	result := TeamGroup{Teams: []TeamWithStats{}, Members: []TeamGroupMember{}, TaskCounts: map[string]int{}}
 	for _, team := range teams {
 		var agentCount, convCount, msgCount int64
 		db.DB.Model(&models.Agent{}).Where("team_id = ?", team.ID).Count(&agentCount)
 		db.DB.Model(&models.Conversation{}).Where("team_id = ?", team.ID).Count(&convCount)
 		db.DB.Model(&models.Message{}).Where("team_id = ?", team.ID).Count(&msgCount)
 
-		result = append(result, TeamWithStats{
+		result.Teams = append(result.Teams, TeamWithStats{
 			Team:              team,
 			AgentCount:        agentCount,
 			ConversationCount: convCount,
@@ -102,9 +142,56 @@ func GetTeamGroup(c *gin.Context) {
 		})
 	}
 
+	var members []models.TeamMember
+	if err := db.DB.Where("team_name = ?", teamName).Order("joined_at ASC").Find(&members).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch team members"})
+		return
+	}
+	for _, m := range members {
+		if m.EndedAt != nil {
+			result.EndedAt = m.EndedAt
+		}
+		member := TeamGroupMember{TeamMember: m}
+		// teams are newest first, so a member's latest session wins.
+		for _, team := range teams {
+			lead := teamLead(team)
+			if lead == nil {
+				continue
+			}
+			if (m.IsLead && m.SessionID == team.ID) || (m.SessionID == "" && lead.Name == m.Name) {
+				member.TeamID, member.AgentID, member.Status = team.ID, lead.ID, lead.Status
+				break
+			}
+		}
+		result.Members = append(result.Members, member)
+	}
+
+	if err := db.DB.Where("list_id = ?", teamName).Order("CAST(task_id AS INTEGER), task_id").Find(&result.Tasks).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch team tasks"})
+		return
+	}
+	for _, t := range result.Tasks {
+		result.TaskCounts[t.Status]++
+	}
+
+	if err := db.DB.Where("team_name = ?", teamName).Order("created_at ASC").Find(&result.Inbox).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch inbox messages"})
+		return
+	}
+
 	c.JSON(http.StatusOK, result)
 }
 
+// teamLead returns a synced team's lead agent.
+func teamLead(team models.Team) *models.Agent {
+	for i := range team.Agents {
+		if team.Agents[i].Role == "lead" {
+			return &team.Agents[i]
+		}
+	}
+	return nil
+}
+
 func CreateTeam(c *gin.Context) {
 	var req CreateTeamReq
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"agent-observer/db"
+	"agent-observer/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+type TestRunView struct {
+	models.TestRun
+	AgentName string `json:"agent_name"`
+}
+
+// TestTrajectory summarizes an agent's test runs in order.
+type TestTrajectory struct {
+	AgentID      string     `json:"agent_id"`
+	AgentName    string     `json:"agent_name"`
+	Runs         int        `json:"runs"`
+	FirstStatus  string     `json:"first_status"`
+	LastStatus   string     `json:"last_status"`
+	EndedGreen   bool       `json:"ended_green"` // the last finished run passed
+	FirstGreenAt *time.Time `json:"first_green_at,omitempty"`
+	// Fixed are tests that failed in some run and weren't failing in the
+	// last finished one.
+	Fixed        []string `json:"fixed"`
+	StillFailing []string `json:"still_failing"`
+}
+
+// GetTeamTests returns a session's test runs, oldest first, and per-agent
+// trajectories showing whether each agent got its tests to pass.
+// Optionally restricted to agent_id.
+func GetTeamTests(c *gin.Context) {
+	id := c.Param("id")
+
+	query := db.DB.Where("team_id = ?", id)
+	if v := c.Query("agent_id"); v != "" {
+		query = query.Where("agent_id = ?", v)
+	}
+	var runs []models.TestRun
+	if err := query.Order("created_at ASC").Find(&runs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch test runs"})
+		return
+	}
+
+	var agents []models.Agent
+	db.DB.Where("team_id = ?", id).Find(&agents)
+	names := make(map[string]string, len(agents))
+	for _, a := range agents {
+		names[a.ID] = a.Name
+	}
+
+	views := make([]TestRunView, 0, len(runs))
+	byAgent := make(map[string][]models.TestRun)
+	var order []string
+	for _, r := range runs {
+		views = append(views, TestRunView{TestRun: r, AgentName: names[r.AgentID]})
+		if _, ok := byAgent[r.AgentID]; !ok {
+			order = append(order, r.AgentID)
+		}
+		byAgent[r.AgentID] = append(byAgent[r.AgentID], r)
+	}
+
+	trajectories := make([]TestTrajectory, 0, len(order))
+	for _, agentID := range order {
+		t := testTrajectory(byAgent[agentID])
+		t.AgentID, t.AgentName = agentID, names[agentID]
+		trajectories = append(trajectories, t)
+	}
+
+	finalStatus := ""
+	for i := len(runs) - 1; i >= 0; i-- {
+		if runs[i].Status != models.TestRunRunning {
+			finalStatus = runs[i].Status
+			break
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"team_id":      id,
+		"final_status": finalStatus,
+		"agents":       trajectories,
+		"runs":         views,
+	})
+}
+
+func testTrajectory(runs []models.TestRun) TestTrajectory {
+	t := TestTrajectory{Runs: len(runs), Fixed: []string{}, StillFailing: []string{}}
+	everFailed := make(map[string]bool)
+	var everOrder []string
+	var last *models.TestRun
+	for i, r := range runs {
+		if i == 0 {
+			t.FirstStatus = r.Status
+		}
+		t.LastStatus = r.Status
+		if r.Status == models.TestRunRunning {
+			continue
+		}
+		last = &runs[i]
+		if r.Status == models.TestRunPassed && t.FirstGreenAt == nil {
+			at := r.CreatedAt
+			t.FirstGreenAt = &at
+		}
+		for _, name := range failingTests(r) {
+			if !everFailed[name] {
+				everFailed[name] = true
+				everOrder = append(everOrder, name)
+			}
+		}
+	}
+	if last == nil {
+		return t
+	}
+
+	t.EndedGreen = last.Status == models.TestRunPassed
+	stillFailing := make(map[string]bool)
+	for _, name := range failingTests(*last) {
+		stillFailing[name] = true
+		t.StillFailing = append(t.StillFailing, name)
+	}
+	for _, name := range everOrder {
+		if !stillFailing[name] {
+			t.Fixed = append(t.Fixed, name)
+		}
+	}
+	return t
+}
+
+func failingTests(r models.TestRun) []string {
+	var names []string
+	_ = json.Unmarshal(r.FailingTests, &names)
+	return names
+}
@@ -0,0 +1,240 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"agent-observer/db"
+	"agent-observer/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TodoProgress is how far an agent is through its todo list.
+type TodoProgress struct {
+	Completed int     `json:"completed"`
+	Total     int     `json:"total"`
+	Pct       float64 `json:"pct"` // 0-100
+}
+
+// TodoChange is an item that a version of the todo list added, removed or
+// moved to another status. From is empty for an added item, To for a
+// removed one.
+type TodoChange struct {
+	Content string `json:"content"`
+	From    string `json:"from,omitempty"`
+	To      string `json:"to,omitempty"`
+}
+
+type TodoVersion struct {
+	models.TodoSnapshot
+	Pct     float64      `json:"pct"`
+	Changes []TodoChange `json:"changes"`
+}
+
+type TodoTimeline struct {
+	AgentID   string        `json:"agent_id"`
+	AgentName string        `json:"agent_name,omitempty"`
+	Progress  *TodoProgress `json:"progress"`
+	Versions  []TodoVersion `json:"versions"`
+}
+
+// TodoPlanItem is how a todo item fared: when it was planned, how long it
+// stayed in progress and how it ended.
+type TodoPlanItem struct {
+	Content      string     `json:"content"`
+	ActiveForm   string     `json:"active_form,omitempty"`
+	Status       string     `json:"status"`  // its last status, or "dropped" if removed before completion
+	Planned      bool       `json:"planned"` // in the agent's first todo list
+	AddedAt      time.Time  `json:"added_at"`
+	AddedIn      int        `json:"added_in"` // version
+	StartedAt    *time.Time `json:"started_at,omitempty"`
+	CompletedAt  *time.Time `json:"completed_at,omitempty"`
+	InProgressMs int64      `json:"in_progress_ms"`
+	Open         bool       `json:"open"` // still in progress; timed up to the agent's last activity
+}
+
+type TodoPlan struct {
+	AgentID   string         `json:"agent_id"`
+	AgentName string         `json:"agent_name,omitempty"`
+	Planned   int            `json:"planned"`
+	Added     int            `json:"added"` // items not in the first list
+	Completed int            `json:"completed"`
+	Dropped   int            `json:"dropped"`
+	Items     []TodoPlanItem `json:"items"`
+}
+
+const todoDropped = "dropped"
+
+// GetAgentTodos returns every version of an agent's todo list, each with
+// what changed since the one before.
+func GetAgentTodos(c *gin.Context) {
+	agent, snapshots, ok := agentTodos(c)
+	if !ok {
+		return
+	}
+
+	timeline := TodoTimeline{AgentID: agent.ID, AgentName: agent.Name, Progress: todoProgress(snapshots), Versions: []TodoVersion{}}
+	var previous []models.TodoItem
+	for _, s := range snapshots {
+		items := todoItems(s)
+		timeline.Versions = append(timeline.Versions, TodoVersion{
+			TodoSnapshot: s,
+			Pct:          pct(s.Completed, s.Total),
+			Changes:      todoChanges(previous, items),
+		})
+		previous = items
+	}
+	c.JSON(http.StatusOK, timeline)
+}
+
+// GetAgentTodoPlan compares an agent's first todo list with how the work
+// went: which items were added or dropped later, and how long each stayed
+// in progress.
+func GetAgentTodoPlan(c *gin.Context) {
+	agent, snapshots, ok := agentTodos(c)
+	if !ok {
+		return
+	}
+
+	plan := TodoPlan{AgentID: agent.ID, AgentName: agent.Name, Items: []TodoPlanItem{}}
+	index := make(map[string]int) // content to position in plan.Items
+	for i, s := range snapshots {
+		// An item in progress counts until the next version, or for the
+		// last one until the agent was last active.
+		var until time.Time
+		open := false
+		if i+1 < len(snapshots) {
+			until = snapshots[i+1].CreatedAt
+		} else if agent.LastActivityAt != nil && agent.LastActivityAt.After(s.CreatedAt) {
+			until, open = *agent.LastActivityAt, true
+		}
+
+		seen := make(map[string]bool)
+		for _, item := range todoItems(s) {
+			if seen[item.Content] {
+				continue
+			}
+			seen[item.Content] = true
+			n, ok := index[item.Content]
+			if !ok {
+				n = len(plan.Items)
+				index[item.Content] = n
+				plan.Items = append(plan.Items, TodoPlanItem{
+					Content: item.Content,
+					Planned: i == 0,
+					AddedAt: s.CreatedAt,
+					AddedIn: s.Version,
+				})
+			}
+			p := &plan.Items[n]
+			p.Status, p.Open = item.Status, false
+			if item.ActiveForm != "" {
+				p.ActiveForm = item.ActiveForm
+			}
+			switch item.Status {
+			case models.TodoInProgress:
+				if p.StartedAt == nil {
+					at := s.CreatedAt
+					p.StartedAt = &at
+				}
+				if !until.IsZero() {
+					p.InProgressMs += until.Sub(s.CreatedAt).Milliseconds()
+					p.Open = open
+				}
+			case models.TodoCompleted:
+				if p.CompletedAt == nil {
+					at := s.CreatedAt
+					p.CompletedAt = &at
+				}
+			}
+		}
+		// Agents clear finished lists, so only unfinished items that
+		// disappear were dropped.
+		for content, n := range index {
+			if !seen[content] && plan.Items[n].Status != models.TodoCompleted {
+				plan.Items[n].Status = todoDropped
+			}
+		}
+	}
+
+	for _, item := range plan.Items {
+		if item.Planned {
+			plan.Planned++
+		} else {
+			plan.Added++
+		}
+		switch item.Status {
+		case models.TodoCompleted:
+			plan.Completed++
+		case todoDropped:
+			plan.Dropped++
+		}
+	}
+	c.JSON(http.StatusOK, plan)
+}
+
+func agentTodos(c *gin.Context) (models.Agent, []models.TodoSnapshot, bool) {
+	id := c.Param("id")
+
+	var agent models.Agent
+	if err := db.DB.First(&agent, "id = ?", id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Agent not found"})
+		return agent, nil, false
+	}
+
+	var snapshots []models.TodoSnapshot
+	if err := db.DB.Where("agent_id = ?", id).Order("created_at ASC, version ASC").Find(&snapshots).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch todo snapshots"})
+		return agent, nil, false
+	}
+	return agent, snapshots, true
+}
+
+// todoProgress is the progress of an agent's latest todo list, skipping a
+// final empty list since agents clear the list once everything is done.
+// It is nil if the agent never wrote one.
+func todoProgress(snapshots []models.TodoSnapshot) *TodoProgress {
+	for i := len(snapshots) - 1; i >= 0; i-- {
+		if s := snapshots[i]; s.Total > 0 {
+			return &TodoProgress{Completed: s.Completed, Total: s.Total, Pct: pct(s.Completed, s.Total)}
+		}
+	}
+	return nil
+}
+
+func todoItems(s models.TodoSnapshot) []models.TodoItem {
+	var items []models.TodoItem
+	_ = json.Unmarshal(s.Items, &items)
+	return items
+}
+
+func todoChanges(before, after []models.TodoItem) []TodoChange {
+	old := make(map[string]string, len(before))
+	for _, item := range before {
+		old[item.Content] = item.Status
+	}
+	changes := []TodoChange{}
+	current := make(map[string]bool, len(after))
+	for _, item := range after {
+		current[item.Content] = true
+		if status, ok := old[item.Content]; !ok || status != item.Status {
+			changes = append(changes, TodoChange{Content: item.Content, From: status, To: item.Status})
+		}
+	}
+	for _, item := range before {
+		if !current[item.Content] {
+			changes = append(changes, TodoChange{Content: item.Content, From: item.Status})
+			current[item.Content] = true
+		}
+	}
+	return changes
+}
+
+func pct(n, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(n) * 100 / float64(total)
+}
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"encoding/json"
 	"net/http"
 	"time"
 
@@ -68,6 +69,7 @@ func GetAgentTraces(c *gin.Context) {
 }
 
 type LogTraceReq struct {
+	ID             string         `json:"id,omitempty"` // optional client-generated ID
 	TeamID         string         `json:"team_id" binding:"required"`
 	AgentID        string         `json:"agent_id" binding:"required"`
 	ConversationID string         `json:"conversation_id" binding:"required"`
@@ -76,6 +78,9 @@ type LogTraceReq struct {
 	Attributes     datatypes.JSON `json:"attributes,omitempty"`
 	StartTime      *time.Time     `json:"start_time,omitempty"`
 	EndTime        *time.Time     `json:"end_time,omitempty"`
+	Status         string         `json:"status,omitempty"`
+	StatusMessage  string         `json:"status_message,omitempty"`
+	Events         datatypes.JSON `json:"events,omitempty"`
 }
 
 func LogTrace(c *gin.Context) {
@@ -85,6 +90,33 @@ func LogTrace(c *gin.Context) {
 		return
 	}
 
+	if !validSpanStatus(req.Status) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid status: " + req.Status})
+		return
+	}
+
+	// Retries of an already stored span return the stored record.
+	if id, ok := lookupIdempotencyKey(c, idempotencyKindTrace); ok {
+		req.ID = id
+	}
+	if req.ID != "" {
+		var existing models.Trace
+		if err := db.DB.First(&existing, "id = ?", req.ID).Error; err == nil {
+			c.JSON(http.StatusOK, existing)
+			return
+		}
+	}
+
+	if err := ensureRefs(ingestRefs{TeamID: req.TeamID, AgentID: req.AgentID, ConversationID: req.ConversationID}); err != nil {
+		respondRefError(c, err)
+		return
+	}
+
+	id := req.ID
+	if id == "" {
+		id = uuid.New().String()
+	}
+
 	now := time.Now()
 	startTime := now
 	if req.StartTime != nil {
@@ -92,7 +124,7 @@ func LogTrace(c *gin.Context) {
 	}
 
 	trace := models.Trace{
-		ID:             uuid.New().String(),
+		ID:             id,
 		TeamID:         req.TeamID,
 		AgentID:        req.AgentID,
 		ConversationID: req.ConversationID,
@@ -101,12 +133,22 @@ func LogTrace(c *gin.Context) {
 		Attributes:     req.Attributes,
 		StartTime:      startTime,
 		EndTime:        req.EndTime,
+		Status:         req.Status,
+		StatusMessage:  req.StatusMessage,
+		Events:         req.Events,
 	}
 
 	if err := db.DB.Create(&trace).Error; err != nil {
+		// A concurrent retry may have inserted the same ID first.
+		var existing models.Trace
+		if db.DB.First(&existing, "id = ?", trace.ID).Error == nil {
+			c.JSON(http.StatusOK, existing)
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log trace"})
 		return
 	}
+	saveIdempotencyKey(c, idempotencyKindTrace, trace.ID)
 
 	// Broadcast to WebSocket clients
 	WSHub.Broadcast(gin.H{
@@ -116,3 +158,113 @@ func LogTrace(c *gin.Context) {
 
 	c.JSON(http.StatusCreated, trace)
 }
+
+type EndSpanReq struct {
+	EndTime       *time.Time             `json:"end_time,omitempty"`
+	Attributes    map[string]interface{} `json:"attributes,omitempty"`
+	Status        string                 `json:"status,omitempty"`
+	StatusMessage string                 `json:"status_message,omitempty"`
+	Events        []json.RawMessage      `json:"events,omitempty"`
+}
+
+// EndSpan closes a span. Attributes are merged into the existing ones (so
+// final values such as token counts or results can be added at the end) and
+// events are appended to any already recorded.
+func EndSpan(c *gin.Context) {
+	id := c.Param("id")
+
+	var req EndSpanReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !validSpanStatus(req.Status) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid status: " + req.Status})
+		return
+	}
+
+	var trace models.Trace
+	if err := db.DB.First(&trace, "id = ?", id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Span not found"})
+		return
+	}
+
+	// A retried end must not append its events a second time.
+	if _, ok := lookupIdempotencyKey(c, idempotencyKindSpanEnd); ok {
+		c.JSON(http.StatusOK, trace)
+		return
+	}
+
+	endTime := time.Now()
+	if req.EndTime != nil {
+		endTime = *req.EndTime
+	}
+	updates := map[string]interface{}{
+		"end_time": endTime,
+	}
+
+	if len(req.Attributes) > 0 {
+		attrs := make(map[string]interface{})
+		if len(trace.Attributes) > 0 {
+			if err := json.Unmarshal(trace.Attributes, &attrs); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read span attributes"})
+				return
+			}
+		}
+		for k, v := range req.Attributes {
+			attrs[k] = v
+		}
+		b, err := json.Marshal(attrs)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid attributes"})
+			return
+		}
+		updates["attributes"] = datatypes.JSON(b)
+	}
+
+	if len(req.Events) > 0 {
+		var events []json.RawMessage
+		if len(trace.Events) > 0 {
+			if err := json.Unmarshal(trace.Events, &events); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read span events"})
+				return
+			}
+		}
+		events = append(events, req.Events...)
+		b, err := json.Marshal(events)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid events"})
+			return
+		}
+		updates["events"] = datatypes.JSON(b)
+	}
+
+	if req.Status != "" {
+		updates["status"] = req.Status
+		updates["status_message"] = req.StatusMessage
+	}
+
+	if err := db.DB.Model(&trace).Updates(updates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to end span"})
+		return
+	}
+	db.DB.First(&trace, "id = ?", id)
+	saveIdempotencyKey(c, idempotencyKindSpanEnd, trace.ID)
+
+	// Broadcast to WebSocket clients
+	WSHub.Broadcast(gin.H{
+		"type": "span_ended",
+		"data": trace,
+	})
+
+	c.JSON(http.StatusOK, trace)
+}
+
+// validSpanStatus reports whether status is empty or a known span status.
+func validSpanStatus(status string) bool {
+	switch status {
+	case "", models.SpanStatusUnset, models.SpanStatusOK, models.SpanStatusError:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,266 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"agent-observer/db"
+	"agent-observer/dispatch"
+	"agent-observer/models"
+	"agent-observer/webhook"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// WebhookWithSecret is a webhook along with its signing secret, returned
+// only when the webhook is created or its secret changes.
+type WebhookWithSecret struct {
+	models.Webhook
+	Secret string `json:"secret"`
+}
+
+type CreateWebhookReq struct {
+	Name   string   `json:"name"`
+	URL    string   `json:"url" binding:"required"`
+	Events []string `json:"events" binding:"required"`
+	Secret string   `json:"secret"` // generated when empty
+	Active *bool    `json:"active"` // default true
+}
+
+type UpdateWebhookReq struct {
+	Name   *string  `json:"name"`
+	URL    *string  `json:"url"`
+	Events []string `json:"events"`
+	Secret *string  `json:"secret"` // "" generates a new one
+	Active *bool    `json:"active"`
+}
+
+// ListWebhooks lists the webhook subscriptions, oldest first.
+func ListWebhooks(c *gin.Context) {
+	hooks := []models.Webhook{}
+	if err := db.DB.Order("created_at ASC").Find(&hooks).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch webhooks"})
+		return
+	}
+
+	c.JSON(http.StatusOK, hooks)
+}
+
+// CreateWebhook subscribes a URL to lifecycle events. The response is the
+// only one that includes the signing secret.
+func CreateWebhook(c *gin.Context) {
+	var req CreateWebhookReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	events, ok := webhookEvents(c, req.Events)
+	if !ok || !validWebhookURL(c, req.Name, req.URL) {
+		return
+	}
+
+	now := time.Now()
+	hook := models.Webhook{
+		ID:        uuid.New().String(),
+		Name:      req.Name,
+		URL:       req.URL,
+		Secret:    req.Secret,
+		Events:    events,
+		Active:    req.Active == nil || *req.Active,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if hook.Secret == "" {
+		hook.Secret = newWebhookSecret()
+	}
+	if err := db.DB.Create(&hook).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create webhook"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, WebhookWithSecret{Webhook: hook, Secret: hook.Secret})
+}
+
+// GetWebhook returns a webhook subscription.
+func GetWebhook(c *gin.Context) {
+	hook, ok := findWebhook(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, hook)
+}
+
+// UpdateWebhook changes the fields of a webhook present in the request.
+func UpdateWebhook(c *gin.Context) {
+	hook, ok := findWebhook(c)
+	if !ok {
+		return
+	}
+
+	var req UpdateWebhookReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Name != nil {
+		hook.Name = *req.Name
+	}
+	if req.URL != nil {
+		hook.URL = *req.URL
+		if !validWebhookURL(c, hook.Name, hook.URL) {
+			return
+		}
+	}
+	if req.Events != nil {
+		if hook.Events, ok = webhookEvents(c, req.Events); !ok {
+			return
+		}
+	}
+	if req.Secret != nil {
+		hook.Secret = *req.Secret
+		if hook.Secret == "" {
+			hook.Secret = newWebhookSecret()
+		}
+	}
+	if req.Active != nil {
+		hook.Active = *req.Active
+	}
+	hook.UpdatedAt = time.Now()
+
+	if err := db.DB.Save(&hook).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update webhook"})
+		return
+	}
+
+	if req.Secret != nil {
+		c.JSON(http.StatusOK, WebhookWithSecret{Webhook: hook, Secret: hook.Secret})
+		return
+	}
+	c.JSON(http.StatusOK, hook)
+}
+
+// DeleteWebhook removes a webhook subscription and its delivery log.
+func DeleteWebhook(c *gin.Context) {
+	hook, ok := findWebhook(c)
+	if !ok {
+		return
+	}
+
+	if err := db.DB.Where("webhook_id = ?", hook.ID).Delete(&models.WebhookDelivery{}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete webhook deliveries"})
+		return
+	}
+	if err := db.DB.Delete(&hook).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete webhook"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// ListWebhookDeliveries lists a webhook's deliveries, newest first.
+// Filters: status and event. limit defaults to 50, at most 500.
+func ListWebhookDeliveries(c *gin.Context) {
+	hook, ok := findWebhook(c)
+	if !ok {
+		return
+	}
+
+	query := db.DB.Where("webhook_id = ?", hook.ID)
+	if v := c.Query("status"); v != "" {
+		if v != models.DeliveryPending && v != models.DeliveryDelivered && v != models.DeliveryFailed {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid status: " + v})
+			return
+		}
+		query = query.Where("status = ?", v)
+	}
+	if v := c.Query("event"); v != "" {
+		query = query.Where("event_type = ?", v)
+	}
+	limit := 50
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit: " + v})
+			return
+		}
+		limit = min(n, 500)
+	}
+
+	deliveries := []models.WebhookDelivery{}
+	if err := query.Order("created_at DESC").Limit(limit).Find(&deliveries).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch deliveries"})
+		return
+	}
+
+	c.JSON(http.StatusOK, deliveries)
+}
+
+// ReplayWebhookDelivery sends a past delivery's event to the webhook again
+// as a new delivery, and returns it once it succeeds or runs out of retries.
+func ReplayWebhookDelivery(c *gin.Context) {
+	hook, ok := findWebhook(c)
+	if !ok {
+		return
+	}
+
+	var original models.WebhookDelivery
+	if err := db.DB.First(&original, "id = ? AND webhook_id = ?", c.Param("deliveryId"), hook.ID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Delivery not found"})
+		return
+	}
+
+	delivery, err := dispatch.Replay(c.Request.Context(), hook, original)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to replay delivery"})
+		return
+	}
+
+	c.JSON(http.StatusOK, delivery)
+}
+
+func findWebhook(c *gin.Context) (models.Webhook, bool) {
+	var hook models.Webhook
+	if err := db.DB.First(&hook, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+		return hook, false
+	}
+	return hook, true
+}
+
+// webhookEvents validates the event types of a subscription.
+func webhookEvents(c *gin.Context, types []string) ([]byte, bool) {
+	if len(types) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "events must not be empty; one or more of " + strings.Join(dispatch.EventTypes, ", ")})
+		return nil, false
+	}
+	for _, t := range types {
+		if !dispatch.IsEventType(t) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event: " + t + "; one of " + strings.Join(dispatch.EventTypes, ", ")})
+			return nil, false
+		}
+	}
+	events, _ := json.Marshal(types)
+	return events, true
+}
+
+func validWebhookURL(c *gin.Context, name, url string) bool {
+	if err := (webhook.Target{Name: name, URL: url}).Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+	return true
+}
+
+func newWebhookSecret() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
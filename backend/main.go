@@ -2,14 +2,21 @@ package main
 
 import (
 	"log"
-	"net/http"
+	"os"
 	"time"
 
+	"agent-observer/agentstate"
+	"agent-observer/alerts"
+	"agent-observer/analyzer"
+	"agent-observer/audit"
 	"agent-observer/datasync"
 	"agent-observer/db"
+	"agent-observer/dispatch"
+	"agent-observer/events"
 	"agent-observer/handlers"
 	"agent-observer/parser"
 	"agent-observer/scanner"
+	"agent-observer/toolattrs"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
@@ -19,11 +26,85 @@ func main() {
 	// Initialize database
 	db.InitDB()
 
+	// Let ingest auto-create unknown teams, agents and conversations
+	if os.Getenv("OBSERVER_LENIENT_INGEST") == "true" {
+		handlers.LenientIngest = true
+		log.Println("Lenient ingest enabled: unknown references will be auto-created")
+	}
+
+	// Forward bus events (status transitions, ...) to WebSocket clients
+	events.Subscribe(func(e events.Event) {
+		handlers.WSHub.Broadcast(gin.H{
+			"type": e.Type,
+			"data": e.Data,
+		})
+	})
+
+	// Context usage fractions at which agents are flagged, e.g. "0.8,0.95"
+	if v := os.Getenv("OBSERVER_CONTEXT_THRESHOLDS"); v != "" {
+		thresholds, err := handlers.ParseThresholds(v)
+		if err != nil {
+			log.Printf("Warning: ignoring OBSERVER_CONTEXT_THRESHOLDS: %v", err)
+		} else {
+			handlers.ContextThresholds = thresholds
+		}
+	}
+
+	// Extra tool attribute extractors, see toolattrs.LoadConfig
+	if path := os.Getenv("OBSERVER_TOOL_ATTRS_FILE"); path != "" {
+		if err := toolattrs.LoadConfig(path); err != nil {
+			log.Printf("Warning: %v", err)
+		}
+	}
+
+	// Longest pause within one agent's stretch of work on a file, and how
+	// long after it another agent's change still conflicts
+	if v := os.Getenv("OBSERVER_CONFLICT_WINDOW"); v != "" {
+		window, err := time.ParseDuration(v)
+		if err != nil || window <= 0 {
+			log.Printf("Warning: ignoring OBSERVER_CONFLICT_WINDOW: %q", v)
+		} else {
+			analyzer.ConflictWindow = window
+		}
+	}
+
+	// Extra or overriding command audit rules, see audit.LoadConfig
+	if path := os.Getenv("OBSERVER_AUDIT_RULES_FILE"); path != "" {
+		if err := audit.LoadConfig(path); err != nil {
+			log.Printf("Warning: %v", err)
+		}
+	}
+
+	// Alert rules and the webhooks they deliver to, see alerts.LoadConfig
+	if path := os.Getenv("OBSERVER_ALERTS_FILE"); path != "" {
+		if config, err := alerts.LoadConfig(path); err != nil {
+			log.Printf("Warning: %v", err)
+		} else {
+			alerts.SetConfig(config)
+		}
+	}
+
+	// Sessions whose agents go quiet mid-turn were abandoned
+	events.Subscribe(func(e events.Event) {
+		if t, ok := e.Data.(agentstate.Transition); ok && t.To == agentstate.Stale {
+			if err := analyzer.ExpireOutcomes(t.TeamID); err != nil {
+				log.Printf("Warning: failed to expire outcomes of team %s: %v", t.TeamID, err)
+			}
+		}
+	})
+
 	// Parse and sync all existing Claude Code sessions
 	log.Println("Starting initial sync of Claude Code sessions...")
 	if err := datasync.SyncAll(); err != nil {
 		log.Printf("Warning: initial sync encountered errors: %v", err)
 	}
+	if err := datasync.SyncAllTeams(); err != nil {
+		log.Printf("Warning: initial sync of agent teams encountered errors: %v", err)
+	}
+
+	// Deliver lifecycle events to webhooks. Subscribed after the initial
+	// sync so that sessions already on disk don't trigger deliveries.
+	dispatch.Start()
 
 	// Start the file scanner to watch for new/updated sessions
 	sc := scanner.NewScanner(parser.ClaudeDataDir)
@@ -39,12 +120,40 @@ func main() {
 			"data": gin.H{"session_id": sessionID},
 		})
 	}
+	sc.TeamsDir = parser.ClaudeTeamsDir
+	sc.TasksDir = parser.ClaudeTasksDir
+	sc.OnTeamUpdate = func(name string) {
+		log.Printf("Team files %s updated, re-syncing...", name)
+		if err := datasync.SyncTeamFiles(name); err != nil {
+			log.Printf("Error syncing team files %s: %v", name, err)
+			return
+		}
+		handlers.WSHub.Broadcast(gin.H{
+			"type": "team_files_updated",
+			"data": gin.H{"team_name": name},
+		})
+	}
 	if err := sc.Start(); err != nil {
 		log.Printf("Warning: failed to start file scanner: %v", err)
 	} else {
 		defer sc.Stop()
 	}
 
+	// Re-evaluate agent statuses so agents that stop writing go stale
+	evaluator := agentstate.NewEvaluator(30 * time.Second)
+	evaluator.Start()
+	defer evaluator.Stop()
+
+	// Flag tool calls that stay open too long
+	stuckWatcher := analyzer.NewStuckWatcher(time.Minute)
+	stuckWatcher.Start()
+	defer stuckWatcher.Stop()
+
+	// Evaluate alert rules
+	alertEvaluator := alerts.NewEvaluator(30 * time.Second)
+	alertEvaluator.Start()
+	defer alertEvaluator.Stop()
+
 	// Set up Gin router
 	r := gin.Default()
 
@@ -52,7 +161,7 @@ func main() {
 	r.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{"http://localhost:5173"},
 		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization", "Idempotency-Key"},
 		ExposeHeaders:    []string{"Content-Length"},
 		AllowCredentials: true,
 		MaxAge:           12 * time.Hour,
@@ -70,20 +179,65 @@ func main() {
 
 		// Team groups (by Claude Code teamName)
 		api.GET("/team-groups/:teamName", handlers.GetTeamGroup)
+		api.GET("/team-groups/:teamName/conflicts", handlers.ListTeamGroupConflicts)
+		api.GET("/team-groups/:teamName/graph", handlers.GetTeamGroupGraph)
 
 		// Team detail routes (use :id consistently)
 		api.GET("/teams/:id", handlers.GetTeam)
 		api.GET("/teams/:id/agents", handlers.ListAgentsByTeam)
 		api.GET("/teams/:id/conversations", handlers.ListConversationsByTeam)
+		api.GET("/teams/:id/context", handlers.GetTeamContext)
+		api.GET("/teams/:id/files", handlers.ListTeamFiles)
+		api.GET("/teams/:id/patch", handlers.GetTeamPatch)
+		api.GET("/teams/:id/conflicts", handlers.ListTeamConflicts)
+		api.GET("/teams/:id/tests", handlers.GetTeamTests)
+		api.GET("/teams/:id/graph", handlers.GetTeamGraph)
+
+		// Live view of what every agent is doing
+		api.GET("/live", handlers.GetLive)
 
 		// Agents
 		api.GET("/agents/:id", handlers.GetAgent)
 		api.GET("/agents/:id/traces", handlers.GetAgentTraces)
+		api.GET("/agents/:id/context", handlers.GetAgentContext)
+		api.GET("/agents/:id/patch", handlers.GetAgentPatch)
+		api.GET("/agents/:id/todos", handlers.GetAgentTodos)
+		api.GET("/agents/:id/todos/plan", handlers.GetAgentTodoPlan)
+
+		// Files touched by agents
+		api.GET("/files", handlers.ListFiles)
+
+		// Analytics
+		api.GET("/analytics/cache", handlers.GetCacheAnalytics)
+		api.GET("/analytics/tools", handlers.GetToolAnalytics)
+		api.GET("/analytics/errors", handlers.GetErrorAnalytics)
+
+		// Bash command audit log
+		api.GET("/audit/commands", handlers.ListCommandAudits)
+		api.GET("/audit/rules", handlers.ListAuditRules)
+
+		// Alerts
+		api.GET("/alerts", handlers.ListAlerts)
+		api.GET("/alerts/rules", handlers.ListAlertRules)
+		api.GET("/alerts/silences", handlers.ListAlertSilences)
+		api.POST("/alerts/silences", handlers.CreateAlertSilence)
+		api.DELETE("/alerts/silences/:id", handlers.ExpireAlertSilence)
+		api.POST("/alerts/test", handlers.TestAlertWebhook)
+
+		// Webhook subscriptions for lifecycle events
+		api.GET("/webhooks", handlers.ListWebhooks)
+		api.POST("/webhooks", handlers.CreateWebhook)
+		api.GET("/webhooks/:id", handlers.GetWebhook)
+		api.PATCH("/webhooks/:id", handlers.UpdateWebhook)
+		api.DELETE("/webhooks/:id", handlers.DeleteWebhook)
+		api.GET("/webhooks/:id/deliveries", handlers.ListWebhookDeliveries)
+		api.POST("/webhooks/:id/deliveries/:deliveryId/replay", handlers.ReplayWebhookDelivery)
 
 		// Conversations
 		api.GET("/conversations/:id", handlers.GetConversation)
 		api.GET("/conversations/:id/messages", handlers.GetConversationMessages)
 		api.GET("/conversations/:id/traces", handlers.GetConversationTraces)
+		api.GET("/conversations/:id/annotations", handlers.GetConversationAnnotations)
 	}
 
 	// Internal endpoints (for agentlogger SDK)
@@ -91,7 +245,17 @@ func main() {
 	{
 		internal.POST("/log_message", handlers.LogMessage)
 		internal.POST("/log_trace", handlers.LogTrace)
-		internal.PATCH("/traces/:id/end", handleEndSpan)
+		internal.PATCH("/traces/:id/end", handlers.EndSpan)
+
+		// Registration for custom (non-Claude Code) agent frameworks
+		internal.POST("/teams", handlers.RegisterTeam)
+		internal.PATCH("/teams/:id/status", handlers.UpdateTeamStatus)
+		internal.POST("/agents", handlers.RegisterAgent)
+		internal.POST("/conversations", handlers.StartConversation)
+		internal.PATCH("/conversations/:id/end", handlers.EndConversation)
+
+		// Claude Code hooks (forwarded by cmd/observer-hook)
+		internal.POST("/hooks/claude", handlers.ClaudeHook)
 	}
 
 	log.Println("Server starting on :8080")
@@ -99,22 +263,3 @@ func main() {
 		log.Fatal("Failed to start server:", err)
 	}
 }
-
-func handleEndSpan(c *gin.Context) {
-	id := c.Param("id")
-
-	var req struct {
-		EndTime time.Time `json:"end_time"`
-	}
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
-	}
-
-	if err := db.DB.Table("traces").Where("id = ?", id).Update("end_time", req.EndTime).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to end span"})
-		return
-	}
-
-	c.JSON(http.StatusOK, gin.H{"status": "ok"})
-}
@@ -0,0 +1,106 @@
+// Package modelinfo resolves static facts about Claude models, such as the
+// size of their context window and their pricing, from the model names
+// found in transcripts.
+package modelinfo
+
+import "strings"
+
+// DefaultContextWindow is used for models not in the table.
+const DefaultContextWindow = 200_000
+
+// contextWindows maps model name prefixes to context window sizes in
+// tokens. The longest matching prefix wins.
+var contextWindows = map[string]int{
+	"claude-opus-4":     200_000,
+	"claude-sonnet-4":   200_000,
+	"claude-haiku-4":    200_000,
+	"claude-3-7-sonnet": 200_000,
+	"claude-3-5-sonnet": 200_000,
+	"claude-3-5-haiku":  200_000,
+	"claude-3-opus":     200_000,
+	"claude-3-haiku":    200_000,
+}
+
+// longContextSuffix marks models run with the 1M-token context beta, e.g.
+// "claude-sonnet-4-5[1m]".
+const longContextSuffix = "[1m]"
+
+// ContextWindow returns the context window in tokens for a model name.
+func ContextWindow(model string) int {
+	model = normalize(model)
+	if strings.HasSuffix(model, longContextSuffix) {
+		return 1_000_000
+	}
+	if prefix := longestPrefix(model, contextWindows); prefix != "" {
+		return contextWindows[prefix]
+	}
+	return DefaultContextWindow
+}
+
+// Pricing is the list price of a model in USD per million tokens.
+// CacheWrite is the 5-minute cache write price.
+type Pricing struct {
+	Input      float64 `json:"input"`
+	Output     float64 `json:"output"`
+	CacheWrite float64 `json:"cache_write"`
+	CacheRead  float64 `json:"cache_read"`
+}
+
+// newPricing derives cache prices from the base input price: writes cost
+// 1.25x and reads 0.1x.
+func newPricing(input, output float64) Pricing {
+	return Pricing{
+		Input:      input,
+		Output:     output,
+		CacheWrite: input * 1.25,
+		CacheRead:  input * 0.1,
+	}
+}
+
+// Cost returns the USD cost of a call's token usage.
+func (p Pricing) Cost(input, cacheWrite, cacheRead, output int) float64 {
+	return (float64(input)*p.Input +
+		float64(cacheWrite)*p.CacheWrite +
+		float64(cacheRead)*p.CacheRead +
+		float64(output)*p.Output) / 1e6
+}
+
+// DefaultPricing is used for models not in the table.
+var DefaultPricing = newPricing(3, 15)
+
+// prices maps model name prefixes to pricing. The longest matching prefix wins.
+var prices = map[string]Pricing{
+	"claude-opus-4-5":   newPricing(5, 25),
+	"claude-opus-4":     newPricing(15, 75),
+	"claude-sonnet-4":   newPricing(3, 15),
+	"claude-haiku-4-5":  newPricing(1, 5),
+	"claude-3-7-sonnet": newPricing(3, 15),
+	"claude-3-5-sonnet": newPricing(3, 15),
+	"claude-3-5-haiku":  newPricing(0.8, 4),
+	"claude-3-opus":     newPricing(15, 75),
+	"claude-3-haiku":    newPricing(0.25, 1.25),
+}
+
+// PricingFor returns the pricing for a model name.
+func PricingFor(model string) Pricing {
+	if prefix := longestPrefix(normalize(model), prices); prefix != "" {
+		return prices[prefix]
+	}
+	return DefaultPricing
+}
+
+func normalize(model string) string {
+	return strings.ToLower(strings.TrimSpace(model))
+}
+
+// longestPrefix returns the longest key of table that model starts with,
+// or "" if none does.
+func longestPrefix[V any](model string, table map[string]V) string {
+	best := ""
+	for prefix := range table {
+		if strings.HasPrefix(model, prefix) && len(prefix) > len(best) {
+			best = prefix
+		}
+	}
+	return best
+}
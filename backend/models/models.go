@@ -11,29 +11,47 @@ type Team struct {
 	Name        string    `json:"name"`
 	Description string    `json:"description"`
 	CreatedBy   string    `json:"created_by"`
-	Status      string    `json:"status"` // running, stopped, idle
+	Status      string    `json:"status"`    // running, idle, stopped, error
 	TeamName    string    `json:"team_name"` // Claude Code team name (for grouping)
 	CreatedAt   time.Time `json:"created_at"`
 	Agents      []Agent   `json:"agents,omitempty" gorm:"foreignKey:TeamID"`
 }
 
 type Agent struct {
-	ID        string    `json:"id" gorm:"primaryKey;type:varchar(36)"`
-	TeamID    string    `json:"team_id"`
-	Role      string    `json:"role"` // lead, teammate
-	Name      string    `json:"name"`
-	Specialty string    `json:"specialty"`
-	Status    string    `json:"status"` // active, idle, error
-	CreatedAt time.Time `json:"created_at"`
+	ID             string     `json:"id" gorm:"primaryKey;type:varchar(36)"`
+	TeamID         string     `json:"team_id"`
+	Role           string     `json:"role"` // lead, teammate
+	Name           string     `json:"name"`
+	Specialty      string     `json:"specialty"`
+	Status         string     `json:"status"` // idle, thinking, running_tool, awaiting_permission, awaiting_user, errored, completed, stale
+	CreatedAt      time.Time  `json:"created_at"`
+	LastActivityAt *time.Time `json:"last_activity_at,omitempty"` // last transcript or hook event
+	HookEventAt    *time.Time `json:"hook_event_at,omitempty"`    // last Claude Code hook event that set Status
 }
 
+// Conversation outcomes.
+const (
+	OutcomeCompleted   = "completed"   // the agent finished its turn, with tests green if any ran
+	OutcomeInProgress  = "in_progress" // still working
+	OutcomeAbandoned   = "abandoned"   // went quiet mid-turn
+	OutcomeInterrupted = "interrupted" // the user interrupted or rejected a tool call
+	OutcomeErrored     = "errored"     // ended on an API error or failing tests
+	OutcomeHitLimits   = "hit_limits"  // ended on a usage, rate or context limit
+)
+
+// Outcomes lists every conversation outcome.
+var Outcomes = []string{OutcomeCompleted, OutcomeInProgress, OutcomeAbandoned, OutcomeInterrupted, OutcomeErrored, OutcomeHitLimits}
+
 type Conversation struct {
-	ID        string     `json:"id" gorm:"primaryKey;type:varchar(36)"`
-	TeamID    string     `json:"team_id"`
-	AgentID   string     `json:"agent_id"`
-	Title     string     `json:"title"`
-	StartedAt time.Time  `json:"started_at"`
-	EndedAt   *time.Time `json:"ended_at,omitempty"`
+	ID            string     `json:"id" gorm:"primaryKey;type:varchar(36)"`
+	TeamID        string     `json:"team_id"`
+	AgentID       string     `json:"agent_id"`
+	Title         string     `json:"title"`
+	StartedAt     time.Time  `json:"started_at"`
+	EndedAt       *time.Time `json:"ended_at,omitempty"`
+	Outcome       string     `json:"outcome,omitempty" gorm:"index"` // see Outcomes; empty until classified
+	OutcomeReason string     `json:"outcome_reason,omitempty"`
+	StartPending  bool       `json:"-"` // set on sessions until events.SessionStarted is published
 }
 
 type Message struct {
@@ -47,6 +65,13 @@ type Message struct {
 	CreatedAt      time.Time      `json:"created_at"`
 }
 
+// Span statuses.
+const (
+	SpanStatusUnset = "unset"
+	SpanStatusOK    = "ok"
+	SpanStatusError = "error"
+)
+
 type Trace struct {
 	ID             string         `json:"id" gorm:"primaryKey;type:varchar(36)"`
 	TeamID         string         `json:"team_id"`
@@ -57,5 +82,327 @@ type Trace struct {
 	Attributes     datatypes.JSON `json:"attributes,omitempty" gorm:"type:json"`
 	StartTime      time.Time      `json:"start_time"`
 	EndTime        *time.Time     `json:"end_time,omitempty"`
+	Status         string         `json:"status" gorm:"default:unset"` // unset, ok, error
+	StatusMessage  string         `json:"status_message,omitempty"`    // error message when status is error
+	Events         datatypes.JSON `json:"events,omitempty" gorm:"type:json"`
 	Children       []Trace        `json:"children,omitempty" gorm:"foreignKey:ParentSpanID;references:ID"`
 }
+
+// IdempotencyKey remembers which record an ingest request with a given
+// Idempotency-Key header produced, so retries return it instead of
+// inserting again. The same key may be used for each kind of request.
+type IdempotencyKey struct {
+	Key       string    `json:"key" gorm:"primaryKey"`
+	Kind      string    `json:"kind" gorm:"primaryKey"` // message, trace, span_end
+	RecordID  string    `json:"record_id"`
+	CreatedAt time.Time `json:"created_at" gorm:"index"`
+}
+
+// TurnUsage is the token usage of one model call. ContextTokens (input plus
+// cache read and creation) is the size of the context the model saw.
+type TurnUsage struct {
+	ID             string    `json:"id" gorm:"primaryKey;type:varchar(36)"` // ID of the message that ended the turn
+	TeamID         string    `json:"team_id"`
+	AgentID        string    `json:"agent_id" gorm:"index"`
+	ConversationID string    `json:"conversation_id" gorm:"index"`
+	Model          string    `json:"model"`
+	InputTokens    int       `json:"input_tokens"`
+	OutputTokens   int       `json:"output_tokens"`
+	CacheCreation  int       `json:"cache_creation"`
+	CacheRead      int       `json:"cache_read"`
+	ContextTokens  int       `json:"context_tokens"`
+	ContextWindow  int       `json:"context_window"`
+	Compacted      bool      `json:"compacted"` // first turn after the context was compacted
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// File operations recorded in FileTouch.
+const (
+	FileOpRead         = "read"
+	FileOpWrite        = "write"
+	FileOpEdit         = "edit"
+	FileOpNotebookEdit = "notebook_edit"
+)
+
+// FileEdit is one old_string -> new_string replacement of an Edit call.
+type FileEdit struct {
+	OldString  string `json:"old_string"`
+	NewString  string `json:"new_string"`
+	ReplaceAll bool   `json:"replace_all,omitempty"`
+}
+
+// FileTouch records one tool call that read or changed a file.
+type FileTouch struct {
+	ID             string         `json:"id" gorm:"primaryKey;type:varchar(36)"` // tool_use ID, same as the span ID
+	TeamID         string         `json:"team_id" gorm:"index"`
+	AgentID        string         `json:"agent_id"`
+	ConversationID string         `json:"conversation_id" gorm:"index"`
+	MessageID      string         `json:"message_id"`
+	FilePath       string         `json:"file_path" gorm:"index"`
+	Operation      string         `json:"operation"`                        // read, write, edit, notebook_edit
+	Edits          datatypes.JSON `json:"edits,omitempty" gorm:"type:json"` // []FileEdit for edit
+	Content        string         `json:"content,omitempty"`                // full content for write, new source for notebook_edit
+	Result         string         `json:"-"`                                // tool result for read (numbered lines) and write; only used to reconstruct diffs
+	Failed         bool           `json:"failed"`                           // the tool call returned an error
+	CreatedAt      time.Time      `json:"created_at"`
+}
+
+// FileConflict is two agents working on one file at the same time: their
+// stretches of changes to it, each extended by the conflict window,
+// overlap.
+type FileConflict struct {
+	ID         string         `json:"id" gorm:"primaryKey;type:varchar(36)"` // derived from the scope, path, agents and first change
+	Scope      string         `json:"scope" gorm:"index"`                    // team name, or team ID for a session without one
+	TeamName   string         `json:"team_name,omitempty"`
+	FilePath   string         `json:"file_path"`
+	AgentIDs   datatypes.JSON `json:"agent_ids" gorm:"type:json"` // []string
+	TeamIDs    datatypes.JSON `json:"team_ids" gorm:"type:json"`  // []string
+	TouchIDs   datatypes.JSON `json:"touch_ids" gorm:"type:json"` // []string, the FileTouch IDs of the changes
+	Changes    int            `json:"changes"`
+	StartedAt  time.Time      `json:"started_at"`
+	EndedAt    time.Time      `json:"ended_at"`
+	DetectedAt time.Time      `json:"detected_at"`
+}
+
+// CommandAudit records one Bash tool call for the command audit log.
+type CommandAudit struct {
+	ID             string         `json:"id" gorm:"primaryKey;type:varchar(36)"` // tool_use ID, same as the span ID
+	TeamID         string         `json:"team_id" gorm:"index"`
+	AgentID        string         `json:"agent_id" gorm:"index"`
+	ConversationID string         `json:"conversation_id" gorm:"index"`
+	Command        string         `json:"command"`
+	Description    string         `json:"description,omitempty"`
+	Cwd            string         `json:"cwd,omitempty"`
+	TimeoutMs      int            `json:"timeout_ms,omitempty"` // 0 for the default timeout
+	Background     bool           `json:"background,omitempty"`
+	Completed      bool           `json:"completed"`           // a tool_result was received
+	ExitCode       *int           `json:"exit_code,omitempty"` // nil when unknown
+	Failed         bool           `json:"failed"`
+	OutputBytes    int            `json:"output_bytes"`
+	DurationMs     int64          `json:"duration_ms,omitempty"`
+	Findings       datatypes.JSON `json:"findings,omitempty" gorm:"type:json"` // []audit.Finding
+	Severity       string         `json:"severity,omitempty" gorm:"index"`     // highest finding severity
+	CreatedAt      time.Time      `json:"created_at"`
+}
+
+// Test run statuses.
+const (
+	TestRunPassed  = "passed"
+	TestRunFailed  = "failed"
+	TestRunError   = "error"   // the tests didn't run, e.g. a build failure
+	TestRunUnknown = "unknown" // no results in the output
+	TestRunRunning = "running"
+)
+
+// TestRun is a Bash call that ran a test suite, with its parsed results.
+type TestRun struct {
+	ID             string         `json:"id" gorm:"primaryKey;type:varchar(36)"` // tool_use ID, same as the span ID
+	TeamID         string         `json:"team_id" gorm:"index"`
+	AgentID        string         `json:"agent_id" gorm:"index"`
+	ConversationID string         `json:"conversation_id" gorm:"index"`
+	Command        string         `json:"command"`
+	Framework      string         `json:"framework"`
+	Status         string         `json:"status"` // passed, failed, error, unknown, running
+	Passed         int            `json:"passed"`
+	Failed         int            `json:"failed"`
+	Skipped        int            `json:"skipped"`
+	FailingTests   datatypes.JSON `json:"failing_tests,omitempty" gorm:"type:json"` // []string
+	ExitCode       *int           `json:"exit_code,omitempty"`
+	DurationMs     int64          `json:"duration_ms,omitempty"`
+	CreatedAt      time.Time      `json:"created_at"`
+}
+
+// Interaction kinds.
+const (
+	InteractionDelegation = "delegation" // a Task call handing work to a sub-agent
+	InteractionResult     = "result"     // a sub-agent's result returned to the agent that called it
+	InteractionMessage    = "message"    // a SendMessage to a teammate
+)
+
+// Interaction is one message passed from one agent to another.
+type Interaction struct {
+	ID             string    `json:"id" gorm:"primaryKey;type:varchar(80)"` // tool_use ID and kind
+	TeamID         string    `json:"team_id" gorm:"index"`
+	ConversationID string    `json:"conversation_id" gorm:"index"`
+	FromAgentID    string    `json:"from_agent_id"`
+	ToAgentID      string    `json:"to_agent_id,omitempty"` // set when the receiving agent is known at sync time
+	Recipient      string    `json:"recipient,omitempty"`   // name the message was addressed to; "*" for a broadcast
+	Kind           string    `json:"kind"`
+	SpanID         string    `json:"span_id"`
+	Tokens         int       `json:"tokens"` // estimated from the text
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// Todo item statuses.
+const (
+	TodoPending    = "pending"
+	TodoInProgress = "in_progress"
+	TodoCompleted  = "completed"
+)
+
+// TodoItem is an item of an agent's todo list.
+type TodoItem struct {
+	Content    string `json:"content"`
+	Status     string `json:"status"`
+	ActiveForm string `json:"active_form,omitempty"` // shown while in progress, e.g. "Running tests"
+}
+
+// TodoSnapshot is an agent's todo list as a TodoWrite call left it.
+type TodoSnapshot struct {
+	ID             string         `json:"id" gorm:"primaryKey;type:varchar(36)"` // tool_use ID
+	TeamID         string         `json:"team_id" gorm:"index"`
+	AgentID        string         `json:"agent_id" gorm:"index"`
+	ConversationID string         `json:"conversation_id" gorm:"index"`
+	Version        int            `json:"version"`                // 1 for the agent's first TodoWrite
+	Items          datatypes.JSON `json:"items" gorm:"type:json"` // []TodoItem
+	Total          int            `json:"total"`
+	Completed      int            `json:"completed"`
+	InProgress     int            `json:"in_progress"`
+	CreatedAt      time.Time      `json:"created_at"`
+}
+
+// Annotation kinds.
+const (
+	AnnotationRepeatedCall    = "repeated_call"    // the same tool call several times in a row
+	AnnotationRepeatedFailure = "repeated_failure" // the same failing tool call again and again
+	AnnotationToolCycle       = "tool_cycle"       // a short pattern of tool calls repeating
+	AnnotationStuckToolCall   = "stuck_tool_call"  // a tool call open for a long time
+)
+
+// Annotation severities.
+const (
+	AnnotationWarning  = "warning"
+	AnnotationCritical = "critical"
+)
+
+// Annotation is an analyzer finding attached to a conversation.
+type Annotation struct {
+	ID             string         `json:"id" gorm:"primaryKey;type:varchar(36)"` // derived from the conversation, kind and first span
+	ConversationID string         `json:"conversation_id" gorm:"index"`
+	TeamID         string         `json:"team_id" gorm:"index"`
+	AgentID        string         `json:"agent_id"`
+	Kind           string         `json:"kind"`
+	Severity       string         `json:"severity"` // warning, critical
+	Message        string         `json:"message"`
+	SpanIDs        datatypes.JSON `json:"span_ids" gorm:"type:json"` // []string
+	Count          int            `json:"count"`                     // repetitions, or minutes open for a stuck call
+	StartedAt      time.Time      `json:"started_at"`
+	EndedAt        time.Time      `json:"ended_at"`
+	CreatedAt      time.Time      `json:"created_at"`
+	ResolvedAt     *time.Time     `json:"resolved_at,omitempty"` // no longer detected
+}
+
+// Alert statuses.
+const (
+	AlertFiring   = "firing"
+	AlertResolved = "resolved"
+)
+
+// Alert is one firing episode of an alert rule for one set of labels.
+type Alert struct {
+	ID          string         `json:"id" gorm:"primaryKey;type:varchar(36)"`
+	Rule        string         `json:"rule" gorm:"index"`
+	Fingerprint string         `json:"fingerprint" gorm:"index"` // rule and labels; one firing alert per fingerprint
+	Status      string         `json:"status" gorm:"index"`      // firing, resolved
+	Severity    string         `json:"severity"`
+	Summary     string         `json:"summary"`
+	Labels      datatypes.JSON `json:"labels" gorm:"type:json"` // map[string]string
+	Value       float64        `json:"value"`
+	Silenced    bool           `json:"silenced"` // matched a silence when it fired; cleared once notified
+	Notified    bool           `json:"notified"` // the firing notification was sent, once no silence matched
+	StartsAt    time.Time      `json:"starts_at"`
+	EndsAt      *time.Time     `json:"ends_at,omitempty"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+}
+
+// AlertSilence suppresses delivery of alerts whose labels match all of its
+// matchers while it is active. The "alertname" matcher matches the rule.
+type AlertSilence struct {
+	ID        string         `json:"id" gorm:"primaryKey;type:varchar(36)"`
+	Matchers  datatypes.JSON `json:"matchers" gorm:"type:json"` // map[string]string
+	Comment   string         `json:"comment"`
+	CreatedBy string         `json:"created_by"`
+	StartsAt  time.Time      `json:"starts_at"`
+	EndsAt    time.Time      `json:"ends_at"`
+	CreatedAt time.Time      `json:"created_at"`
+}
+
+// Webhook is a subscription that receives signed lifecycle events.
+type Webhook struct {
+	ID        string         `json:"id" gorm:"primaryKey;type:varchar(36)"`
+	Name      string         `json:"name"`
+	URL       string         `json:"url"`
+	Secret    string         `json:"-"`                       // HMAC-SHA256 key for the X-Observer-Signature header
+	Events    datatypes.JSON `json:"events" gorm:"type:json"` // []string of event types
+	Active    bool           `json:"active"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+}
+
+// Webhook delivery statuses.
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryFailed    = "failed"
+)
+
+// WebhookDelivery is one event sent to a webhook, with every attempt made.
+type WebhookDelivery struct {
+	ID          string         `json:"id" gorm:"primaryKey;type:varchar(36)"`
+	WebhookID   string         `json:"webhook_id" gorm:"index"`
+	EventType   string         `json:"event_type"`
+	Payload     datatypes.JSON `json:"payload" gorm:"type:json"`  // the event data
+	Status      string         `json:"status" gorm:"index"`       // pending, delivered, failed
+	Attempts    datatypes.JSON `json:"attempts" gorm:"type:json"` // []webhook.Attempt
+	ReplayOf    string         `json:"replay_of,omitempty"`       // ID of the delivery this one replays
+	OccurredAt  time.Time      `json:"occurred_at"`               // when the event happened
+	CreatedAt   time.Time      `json:"created_at" gorm:"index"`
+	CompletedAt *time.Time     `json:"completed_at,omitempty"`
+}
+
+// TeamMember is a member of a Claude Code agent team, from the team's
+// config file. Members are matched to the synced sessions of the team by
+// name (the sessions' agentName), the lead also by its session ID.
+type TeamMember struct {
+	ID        string     `json:"id" gorm:"primaryKey;type:varchar(255)"` // team name and member name
+	TeamName  string     `json:"team_name" gorm:"index"`
+	Name      string     `json:"name"`
+	MemberID  string     `json:"member_id"` // Claude Code's ID, e.g. "researcher@my-team"
+	AgentType string     `json:"agent_type"`
+	Model     string     `json:"model"`
+	Color     string     `json:"color,omitempty"`
+	Cwd       string     `json:"cwd,omitempty"`
+	IsLead    bool       `json:"is_lead"`
+	SessionID string     `json:"session_id,omitempty"` // the lead's session, from the config
+	JoinedAt  time.Time  `json:"joined_at"`
+	EndedAt   *time.Time `json:"ended_at,omitempty"` // when the team's directory was deleted; its rows are kept
+}
+
+// InboxMessage is a message delivered to a team member's inbox.
+type InboxMessage struct {
+	ID        string    `json:"id" gorm:"primaryKey;type:varchar(36)"`
+	TeamName  string    `json:"team_name" gorm:"index"`
+	Recipient string    `json:"recipient"`
+	Sender    string    `json:"sender"`
+	Kind      string    `json:"kind"` // message, or the type of a structured message
+	Text      string    `json:"text"`
+	Summary   string    `json:"summary,omitempty"`
+	Read      bool      `json:"read"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TeamTask is a task in a shared task list. Agent teams share the list
+// named after the team.
+type TeamTask struct {
+	ID          string         `json:"id" gorm:"primaryKey;type:varchar(255)"` // list and task ID
+	ListID      string         `json:"list_id" gorm:"index"`
+	TaskID      string         `json:"task_id"`
+	Subject     string         `json:"subject"`
+	Description string         `json:"description"`
+	ActiveForm  string         `json:"active_form,omitempty"`
+	Status      string         `json:"status"` // pending, in_progress, completed
+	Owner       string         `json:"owner,omitempty"`
+	Blocks      datatypes.JSON `json:"blocks" gorm:"type:json"`     // []string of task IDs
+	BlockedBy   datatypes.JSON `json:"blocked_by" gorm:"type:json"` // []string of task IDs
+	UpdatedAt   time.Time      `json:"updated_at"`
+}
@@ -50,6 +50,11 @@ type ParsedMessage struct {
 	Slug        string
 	TeamName    string // Claude Code team name
 	AgentName   string // Claude Code agent name
+	IsAPIError  bool   // synthetic assistant message reporting a failed API call
+	Model       string // model that produced an assistant message
+	APIMsgID    string // API message ID; shared by the lines of one streamed response
+	Compacted   bool   // user message carrying the summary that replaced compacted context
+	Cwd         string // working directory of the session when the line was written
 }
 
 // ParsedToolCall represents a tool invocation found in assistant content blocks.
@@ -58,6 +63,18 @@ type ParsedToolCall struct {
 	Name   string // Bash, Write, Read, Grep, etc.
 	Input  map[string]interface{}
 	Result string
+	// HasResult is false while the call is in flight (no tool_result yet).
+	HasResult bool
+	IsError   bool      // the tool_result was flagged is_error
+	ResultAt  time.Time // timestamp of the message carrying the result
+}
+
+// toolResult is a tool_result block collected while parsing, before it is
+// matched to its tool call.
+type toolResult struct {
+	content string
+	isError bool
+	at      time.Time
 }
 
 // TokenUsage represents token consumption for an assistant message.
@@ -206,10 +223,15 @@ type rawLine struct {
 	Message     json.RawMessage `json:"message"`
 	TeamName    string          `json:"teamName"`
 	AgentName   string          `json:"agentName"`
+	IsAPIError  bool            `json:"isApiErrorMessage"`
+	IsCompact   bool            `json:"isCompactSummary"`
+	Cwd         string          `json:"cwd"`
 }
 
 // rawMessage represents the nested message object.
 type rawMessage struct {
+	ID      string          `json:"id"`
+	Model   string          `json:"model"`
 	Role    string          `json:"role"`
 	Content json.RawMessage `json:"content"`
 	Usage   *rawUsage       `json:"usage,omitempty"`
@@ -233,6 +255,7 @@ type contentBlock struct {
 	Input     map[string]interface{} `json:"input,omitempty"`
 	ToolUseID string                 `json:"tool_use_id,omitempty"`
 	Content   json.RawMessage        `json:"content,omitempty"`
+	IsError   bool                   `json:"is_error,omitempty"`
 }
 
 // ParseJSONLFile parses a single JSONL file into messages.
@@ -246,7 +269,7 @@ func ParseJSONLFile(filepath string) ([]ParsedMessage, error) {
 
 	var messages []ParsedMessage
 	// Collect tool results so we can associate them with tool calls later
-	toolResults := make(map[string]string) // tool_use_id -> result
+	toolResults := make(map[string]toolResult) // tool_use_id -> result
 
 	scanner := bufio.NewScanner(f)
 	// Increase scanner buffer for large lines
@@ -297,6 +320,11 @@ func ParseJSONLFile(filepath string) ([]ParsedMessage, error) {
 			Slug:        raw.Slug,
 			TeamName:    raw.TeamName,
 			AgentName:   raw.AgentName,
+			IsAPIError:  raw.IsAPIError,
+			Model:       msg.Model,
+			APIMsgID:    msg.ID,
+			Compacted:   raw.IsCompact,
+			Cwd:         raw.Cwd,
 			ToolResults: make(map[string]string),
 		}
 
@@ -377,7 +405,7 @@ func parseAssistantContent(parsed *ParsedMessage, rawContent json.RawMessage) {
 }
 
 // parseUserContent extracts the user message content, which can be a string or tool_result array.
-func parseUserContent(parsed *ParsedMessage, rawContent json.RawMessage, toolResults map[string]string) {
+func parseUserContent(parsed *ParsedMessage, rawContent json.RawMessage, toolResults map[string]toolResult) {
 	if len(rawContent) == 0 {
 		return
 	}
@@ -396,7 +424,7 @@ func parseUserContent(parsed *ParsedMessage, rawContent json.RawMessage, toolRes
 			if block.Type == "tool_result" && block.ToolUseID != "" {
 				// Extract result content
 				result := extractToolResultContent(block.Content)
-				toolResults[block.ToolUseID] = result
+				toolResults[block.ToolUseID] = toolResult{content: result, isError: block.IsError, at: parsed.Timestamp}
 				parsed.ToolResults[block.ToolUseID] = result
 			}
 		}
@@ -440,11 +468,14 @@ func extractToolResultContent(raw json.RawMessage) string {
 }
 
 // associateToolResults matches tool results back to their corresponding tool calls.
-func associateToolResults(messages []ParsedMessage, toolResults map[string]string) {
+func associateToolResults(messages []ParsedMessage, toolResults map[string]toolResult) {
 	for i := range messages {
 		for j := range messages[i].ToolCalls {
 			if result, ok := toolResults[messages[i].ToolCalls[j].ID]; ok {
-				messages[i].ToolCalls[j].Result = result
+				messages[i].ToolCalls[j].Result = result.content
+				messages[i].ToolCalls[j].HasResult = true
+				messages[i].ToolCalls[j].IsError = result.isError
+				messages[i].ToolCalls[j].ResultAt = result.at
 			}
 		}
 	}
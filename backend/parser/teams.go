@@ -0,0 +1,287 @@
+package parser
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ClaudeTeamsDir and ClaudeTasksDir are where Claude Code keeps agent-team
+// configs and inboxes, and shared task lists, next to the projects
+// directory.
+var (
+	ClaudeTeamsDir = filepath.Join(filepath.Dir(filepath.Dir(ClaudeDataDir)), "teams")
+	ClaudeTasksDir = filepath.Join(filepath.Dir(filepath.Dir(ClaudeDataDir)), "tasks")
+)
+
+// ParsedTeam is an agent team's config and inboxes, from
+// teams/{name}/config.json and teams/{name}/inboxes/{member}.json.
+type ParsedTeam struct {
+	Name          string
+	Description   string
+	CreatedAt     time.Time
+	LeadAgentID   string // e.g. "team-lead@my-team"
+	LeadSessionID string // the lead's Claude Code session
+	Members       []ParsedTeamMember
+	Inbox         []ParsedInboxMessage
+}
+
+// ParsedTeamMember is a member of an agent team.
+type ParsedTeamMember struct {
+	AgentID   string // e.g. "researcher@my-team"
+	Name      string // the agentName of the member's sessions
+	AgentType string
+	Model     string
+	Color     string
+	Cwd       string
+	JoinedAt  time.Time
+}
+
+// ParsedInboxMessage is a message in a team member's inbox.
+type ParsedInboxMessage struct {
+	Recipient string // the inbox owner
+	Index     int    // position in the inbox file
+	From      string
+	Text      string
+	Summary   string
+	Kind      string // "message", or the type of a structured message (e.g. "idle_notification")
+	Read      bool
+	Timestamp time.Time
+}
+
+// ParsedTask is a task in a shared task list, from tasks/{list}/{id}.json.
+type ParsedTask struct {
+	ID          string
+	Subject     string
+	Description string
+	ActiveForm  string
+	Status      string // pending, in_progress, completed
+	Owner       string // member name
+	Blocks      []string
+	BlockedBy   []string
+	UpdatedAt   time.Time // the file's modification time
+}
+
+// flexTime reads a timestamp written either as epoch milliseconds or as
+// an RFC 3339 string.
+type flexTime time.Time
+
+func (t *flexTime) UnmarshalJSON(b []byte) error {
+	if ms, err := strconv.ParseInt(string(b), 10, 64); err == nil {
+		*t = flexTime(time.UnixMilli(ms))
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil || s == "" {
+		return nil
+	}
+	if parsed, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		*t = flexTime(parsed)
+	}
+	return nil
+}
+
+type rawTeamConfig struct {
+	Name          string   `json:"name"`
+	Description   string   `json:"description"`
+	CreatedAt     flexTime `json:"createdAt"`
+	LeadAgentID   string   `json:"leadAgentId"`
+	LeadSessionID string   `json:"leadSessionId"`
+	Members       []struct {
+		AgentID   string   `json:"agentId"`
+		Name      string   `json:"name"`
+		AgentType string   `json:"agentType"`
+		Model     string   `json:"model"`
+		Color     string   `json:"color"`
+		Cwd       string   `json:"cwd"`
+		JoinedAt  flexTime `json:"joinedAt"`
+	} `json:"members"`
+}
+
+type rawInboxMessage struct {
+	From      string   `json:"from"`
+	Text      string   `json:"text"`
+	Summary   string   `json:"summary"`
+	Read      bool     `json:"read"`
+	Timestamp flexTime `json:"timestamp"`
+}
+
+type rawTask struct {
+	ID          string   `json:"id"`
+	Subject     string   `json:"subject"`
+	Description string   `json:"description"`
+	ActiveForm  string   `json:"activeForm"`
+	Status      string   `json:"status"`
+	Owner       string   `json:"owner"`
+	Blocks      []string `json:"blocks"`
+	BlockedBy   []string `json:"blockedBy"`
+}
+
+// ScanDirsIn returns the names of the subdirectories of dir: the teams in
+// ClaudeTeamsDir or the task lists in ClaudeTasksDir. A missing dir has
+// none.
+func ScanDirsIn(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read directory %s: %w", dir, err)
+	}
+	var names []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			names = append(names, entry.Name())
+		}
+	}
+	return names, nil
+}
+
+// ParseTeamInDir parses a team's config and inboxes from a teams
+// directory. It returns nil without an error if the team has no config.
+func ParseTeamInDir(dir, name string) (*ParsedTeam, error) {
+	data, err := os.ReadFile(filepath.Join(dir, name, "config.json"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read team config %s: %w", name, err)
+	}
+	var raw rawTeamConfig
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse team config %s: %w", name, err)
+	}
+
+	team := &ParsedTeam{
+		Name:          name,
+		Description:   raw.Description,
+		CreatedAt:     time.Time(raw.CreatedAt),
+		LeadAgentID:   raw.LeadAgentID,
+		LeadSessionID: raw.LeadSessionID,
+	}
+	for _, m := range raw.Members {
+		team.Members = append(team.Members, ParsedTeamMember{
+			AgentID:   m.AgentID,
+			Name:      m.Name,
+			AgentType: m.AgentType,
+			Model:     m.Model,
+			Color:     m.Color,
+			Cwd:       m.Cwd,
+			JoinedAt:  time.Time(m.JoinedAt),
+		})
+	}
+
+	inboxDir := filepath.Join(dir, name, "inboxes")
+	entries, err := os.ReadDir(inboxDir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read inboxes of team %s: %w", name, err)
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		recipient := strings.TrimSuffix(entry.Name(), ".json")
+		data, err := os.ReadFile(filepath.Join(inboxDir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read inbox %s of team %s: %w", recipient, name, err)
+		}
+		var messages []rawInboxMessage
+		if err := json.Unmarshal(data, &messages); err != nil {
+			// Inboxes are rewritten in place; a half-written one is read
+			// again on the next change.
+			return nil, fmt.Errorf("failed to parse inbox %s of team %s: %w", recipient, name, err)
+		}
+		for i, m := range messages {
+			team.Inbox = append(team.Inbox, ParsedInboxMessage{
+				Recipient: recipient,
+				Index:     i,
+				From:      m.From,
+				Text:      m.Text,
+				Summary:   m.Summary,
+				Kind:      inboxKind(m.Text),
+				Read:      m.Read,
+				Timestamp: time.Time(m.Timestamp),
+			})
+		}
+	}
+	sort.SliceStable(team.Inbox, func(i, j int) bool { return team.Inbox[i].Timestamp.Before(team.Inbox[j].Timestamp) })
+	return team, nil
+}
+
+// inboxKind returns the type of a structured inbox message, whose text is
+// a JSON object with a "type", or "message" for plain text.
+func inboxKind(text string) string {
+	if strings.HasPrefix(strings.TrimSpace(text), "{") {
+		var structured struct {
+			Type string `json:"type"`
+		}
+		if json.Unmarshal([]byte(text), &structured) == nil && structured.Type != "" {
+			return structured.Type
+		}
+	}
+	return "message"
+}
+
+// ParseTasksInDir parses the tasks of a task list from a tasks directory.
+// Files that aren't tasks (locks, the high-water mark) are skipped; a file
+// that isn't valid JSON fails the whole list.
+func ParseTasksInDir(dir, list string) ([]ParsedTask, error) {
+	entries, err := os.ReadDir(filepath.Join(dir, list))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read task list %s: %w", list, err)
+	}
+
+	var tasks []ParsedTask
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		path := filepath.Join(dir, list, entry.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read task %s: %w", path, err)
+		}
+		var raw rawTask
+		if err := json.Unmarshal(data, &raw); err != nil {
+			// A task being rewritten is read again on the next change.
+			return nil, fmt.Errorf("failed to parse task %s: %w", path, err)
+		}
+		if raw.ID == "" {
+			continue
+		}
+		task := ParsedTask{
+			ID:          raw.ID,
+			Subject:     raw.Subject,
+			Description: raw.Description,
+			ActiveForm:  raw.ActiveForm,
+			Status:      raw.Status,
+			Owner:       raw.Owner,
+			Blocks:      raw.Blocks,
+			BlockedBy:   raw.BlockedBy,
+		}
+		if info, err := entry.Info(); err == nil {
+			task.UpdatedAt = info.ModTime()
+		}
+		tasks = append(tasks, task)
+	}
+	sort.Slice(tasks, func(i, j int) bool { return taskLess(tasks[i].ID, tasks[j].ID) })
+	return tasks, nil
+}
+
+// taskLess orders task IDs numerically when they are numbers.
+func taskLess(a, b string) bool {
+	x, errA := strconv.Atoi(a)
+	y, errB := strconv.Atoi(b)
+	if errA == nil && errB == nil {
+		return x < y
+	}
+	return a < b
+}
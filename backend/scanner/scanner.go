@@ -12,25 +12,34 @@ import (
 )
 
 // Scanner watches the Claude Code data directory for changes and triggers
-// callbacks when session files are created or modified.
+// callbacks when session files are created or modified. When TeamsDir and
+// TasksDir are set it also watches agent-team configs, inboxes and task
+// lists.
 type Scanner struct {
 	DataDir  string
 	OnUpdate func(sessionID string) // callback when a session is updated
-	watcher  *fsnotify.Watcher
-	done     chan struct{}
-	mu       sync.Mutex
-	// debounce map to avoid processing the same session multiple times in quick succession
-	pending    map[string]time.Time
-	debounceMs time.Duration
+
+	TeamsDir     string
+	TasksDir     string
+	OnTeamUpdate func(name string) // callback when a team's files or the task list of that name change
+
+	watcher *fsnotify.Watcher
+	done    chan struct{}
+	mu      sync.Mutex
+	// debounce maps to avoid processing the same session or team multiple times in quick succession
+	pending      map[string]time.Time
+	pendingTeams map[string]time.Time
+	debounceMs   time.Duration
 }
 
 // NewScanner creates a new Scanner for the given data directory.
 func NewScanner(dataDir string) *Scanner {
 	return &Scanner{
-		DataDir:    dataDir,
-		done:       make(chan struct{}),
-		pending:    make(map[string]time.Time),
-		debounceMs: 2 * time.Second,
+		DataDir:      dataDir,
+		done:         make(chan struct{}),
+		pending:      make(map[string]time.Time),
+		pendingTeams: make(map[string]time.Time),
+		debounceMs:   2 * time.Second,
 	}
 }
 
@@ -66,6 +75,22 @@ func (s *Scanner) Start() error {
 		}
 	}
 
+	// Watch team and task list directories, each one level deep, and the
+	// teams' inboxes. Claude Code creates them with the first team, so a
+	// missing one is waited for in its parent.
+	for _, dir := range []string{s.TeamsDir, s.TasksDir} {
+		if dir == "" {
+			continue
+		}
+		if _, err := os.Stat(dir); os.IsNotExist(err) {
+			if err := watcher.Add(filepath.Dir(dir)); err != nil {
+				log.Printf("Scanner not watching %s: %v", dir, err)
+			}
+			continue
+		}
+		s.addTeamDir(dir, false)
+	}
+
 	go s.watchLoop()
 	go s.debounceLoop()
 
@@ -115,6 +140,15 @@ func (s *Scanner) watchLoop() {
 			if !ok {
 				return
 			}
+			if name := s.extractTeamName(event.Name); name != "" {
+				// Tasks are deleted as well as written
+				if event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Remove|fsnotify.Rename) != 0 {
+					s.mu.Lock()
+					s.pendingTeams[name] = time.Now()
+					s.mu.Unlock()
+				}
+				continue
+			}
 			if event.Op&(fsnotify.Write|fsnotify.Create) == 0 {
 				continue
 			}
@@ -123,6 +157,9 @@ func (s *Scanner) watchLoop() {
 			if event.Op&fsnotify.Create != 0 {
 				info, err := os.Stat(event.Name)
 				if err == nil && info.IsDir() {
+					if s.addTeamDir(event.Name, true) || !isWithin(s.DataDir, event.Name) {
+						continue
+					}
 					_ = s.watcher.Add(event.Name)
 					subagentsDir := filepath.Join(event.Name, "subagents")
 					if _, err := os.Stat(subagentsDir); err == nil {
@@ -183,6 +220,15 @@ func (s *Scanner) processPending() {
 	for _, id := range ready {
 		delete(s.pending, id)
 	}
+	var readyTeams []string
+	for name, lastUpdate := range s.pendingTeams {
+		if now.Sub(lastUpdate) >= s.debounceMs {
+			readyTeams = append(readyTeams, name)
+		}
+	}
+	for _, name := range readyTeams {
+		delete(s.pendingTeams, name)
+	}
 	s.mu.Unlock()
 
 	for _, sessionID := range ready {
@@ -190,6 +236,11 @@ func (s *Scanner) processPending() {
 			s.OnUpdate(sessionID)
 		}
 	}
+	for _, name := range readyTeams {
+		if s.OnTeamUpdate != nil {
+			s.OnTeamUpdate(name)
+		}
+	}
 }
 
 // extractSessionID extracts the session ID from a file path.
@@ -216,3 +267,79 @@ func (s *Scanner) extractSessionID(path string) string {
 
 	return ""
 }
+
+// addTeamDir watches a directory of the team and task list trees: the
+// teams or tasks directory itself, a team or task list, or a team's
+// inboxes, along with the directories below it. With queue, the teams and
+// task lists found are queued for sync, since files may have been written
+// before the watch was added. It reports whether path was such a directory.
+func (s *Scanner) addTeamDir(path string, queue bool) bool {
+	for _, root := range []string{s.TeamsDir, s.TasksDir} {
+		if root == "" || !isWithin(root, path) {
+			continue
+		}
+		rel, _ := filepath.Rel(root, path)
+		parts := strings.Split(rel, string(os.PathSeparator))
+		switch {
+		case rel == ".":
+			if err := s.watcher.Add(path); err != nil {
+				log.Printf("Scanner not watching %s: %v", path, err)
+				return true
+			}
+			entries, _ := os.ReadDir(path)
+			for _, entry := range entries {
+				if entry.IsDir() {
+					s.addTeamDir(filepath.Join(path, entry.Name()), queue)
+				}
+			}
+		case len(parts) == 1:
+			_ = s.watcher.Add(path)
+			if root == s.TeamsDir {
+				inboxes := filepath.Join(path, "inboxes")
+				if _, err := os.Stat(inboxes); err == nil {
+					_ = s.watcher.Add(inboxes)
+				}
+			}
+		case root == s.TeamsDir && len(parts) == 2 && parts[1] == "inboxes":
+			_ = s.watcher.Add(path)
+		default:
+			return false
+		}
+		if queue && rel != "." {
+			s.mu.Lock()
+			s.pendingTeams[parts[0]] = time.Now()
+			s.mu.Unlock()
+		}
+		return true
+	}
+	return false
+}
+
+// isWithin reports whether path is dir or below it.
+func isWithin(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(os.PathSeparator))
+}
+
+// extractTeamName returns the team or task list a JSON file belongs to:
+// {TeamsDir}/{team}/config.json, {TeamsDir}/{team}/inboxes/{member}.json
+// or {TasksDir}/{list}/{task}.json. It returns "" for other paths.
+func (s *Scanner) extractTeamName(path string) string {
+	if !strings.HasSuffix(path, ".json") {
+		return ""
+	}
+	for _, dir := range []string{s.TeamsDir, s.TasksDir} {
+		if dir == "" {
+			continue
+		}
+		if !isWithin(dir, path) {
+			continue
+		}
+		relPath, _ := filepath.Rel(dir, path)
+		parts := strings.Split(relPath, string(os.PathSeparator))
+		if len(parts) == 2 || (dir == s.TeamsDir && len(parts) == 3 && parts[1] == "inboxes") {
+			return parts[0]
+		}
+	}
+	return ""
+}
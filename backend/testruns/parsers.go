@@ -0,0 +1,203 @@
+package testruns
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// countPattern matches "3 passed", "1 failure", ... in summary lines.
+var countPattern = regexp.MustCompile(`(\d+) (passed|passing|failed|failing|failures?|errors?|skipped|ignored|pending|todo|xfailed|xpassed)\b`)
+
+// addCounts adds the counts in a summary line to r.
+func addCounts(r *Result, summary string) {
+	for _, m := range countPattern.FindAllStringSubmatch(summary, -1) {
+		n, _ := strconv.Atoi(m[1])
+		switch m[2] {
+		case "passed", "passing", "xpassed":
+			r.Passed += n
+		case "failed", "failing", "failure", "failures", "error", "errors":
+			r.Failed += n
+		default:
+			r.Skipped += n
+		}
+	}
+}
+
+// matches returns the first submatch of every match of re in s, without
+// duplicates.
+func matches(re *regexp.Regexp, s string) []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, m := range re.FindAllStringSubmatch(s, -1) {
+		name := strings.TrimSpace(m[1])
+		if name != "" && !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+var (
+	goTestLine    = regexp.MustCompile(`(?m)^\s*--- (PASS|FAIL|SKIP): (\S+)`)
+	goPackageLine = regexp.MustCompile(`(?m)^(ok|FAIL)\s+(\S+)\s`)
+	goBuildFailed = regexp.MustCompile(`\[(?:build|setup) failed\]`)
+)
+
+func parseGo(output string) (Result, bool) {
+	var r Result
+	tests := goTestLine.FindAllStringSubmatch(output, -1)
+	// A test with subtests reports on top of them. It counts only when it
+	// failed without a failing subtest, i.e. on its own account.
+	hasSubtests, failedSubtests := make(map[string]bool), make(map[string]bool)
+	for _, m := range tests {
+		name := m[2]
+		for i := strings.LastIndex(name, "/"); i >= 0; i = strings.LastIndex(name[:i], "/") {
+			hasSubtests[name[:i]] = true
+			if m[1] == "FAIL" {
+				failedSubtests[name[:i]] = true
+			}
+		}
+	}
+	for _, m := range tests {
+		name := m[2]
+		if hasSubtests[name] && (m[1] != "FAIL" || failedSubtests[name]) {
+			continue
+		}
+		switch m[1] {
+		case "PASS":
+			r.Passed++
+		case "FAIL":
+			r.Failed++
+			r.Failing = append(r.Failing, name)
+		case "SKIP":
+			r.Skipped++
+		}
+	}
+	r.BuildFailed = goBuildFailed.MatchString(output)
+
+	packages := goPackageLine.FindAllStringSubmatch(output, -1)
+	if len(tests) == 0 {
+		// Without -v only failures are listed per test; fall back to
+		// counting packages that failed without naming a test (panics).
+		for _, m := range packages {
+			if m[1] == "FAIL" && !r.BuildFailed {
+				r.Failed++
+				r.Failing = append(r.Failing, m[2])
+			}
+		}
+	}
+	return r, len(tests) > 0 || len(packages) > 0 || r.BuildFailed
+}
+
+var (
+	pytestSummary = regexp.MustCompile(`(?m)^=*\s*((?:\d+ \w+,? ?)+)(?:in [\d.]+s)`)
+	pytestFailing = regexp.MustCompile(`(?m)^(?:FAILED|ERROR) (\S+)`)
+)
+
+func parsePytest(output string) (Result, bool) {
+	var r Result
+	summaries := pytestSummary.FindAllStringSubmatch(output, -1)
+	if len(summaries) == 0 {
+		return r, false
+	}
+	summary := summaries[len(summaries)-1][1]
+	addCounts(&r, summary)
+	r.Failing = matches(pytestFailing, output)
+	// Collection errors stop the run before any test executes.
+	r.BuildFailed = strings.Contains(summary, "error") && r.Passed == 0 && !strings.Contains(summary, "failed")
+	return r, true
+}
+
+var (
+	cargoSummary = regexp.MustCompile(`(?m)^test result: \w+\. (.*)$`)
+	cargoFailing = regexp.MustCompile(`(?m)^test (\S+) \.\.\. FAILED`)
+	nextestLine  = regexp.MustCompile(`(?m)^\s*Summary \[.*?\] \d+ tests? run: (.*)$`)
+	cargoBuild   = regexp.MustCompile(`(?m)^error(?:\[E\d+\])?: could not compile`)
+)
+
+func parseCargo(output string) (Result, bool) {
+	var r Result
+	summaries := cargoSummary.FindAllStringSubmatch(output, -1)
+	summaries = append(summaries, nextestLine.FindAllStringSubmatch(output, -1)...)
+	for _, m := range summaries {
+		addCounts(&r, m[1])
+	}
+	r.Failing = matches(cargoFailing, output)
+	r.BuildFailed = cargoBuild.MatchString(output)
+	return r, len(summaries) > 0 || r.BuildFailed
+}
+
+var (
+	jestSummary   = regexp.MustCompile(`(?m)^Tests:\s+(.*)$`)
+	jestFailing   = regexp.MustCompile(`(?m)^\s*● (.+?)\s*$`)
+	jestSuiteFail = "Test suite failed to run"
+)
+
+func parseJest(output string) (Result, bool) {
+	var r Result
+	summaries := jestSummary.FindAllStringSubmatch(output, -1)
+	for _, m := range summaries {
+		addCounts(&r, m[1])
+	}
+	for _, name := range matches(jestFailing, output) {
+		if name != jestSuiteFail && !strings.HasPrefix(name, "Console") {
+			r.Failing = append(r.Failing, name)
+		}
+	}
+	r.BuildFailed = len(summaries) == 0 && strings.Contains(output, jestSuiteFail)
+	return r, len(summaries) > 0 || r.BuildFailed
+}
+
+var (
+	vitestSummary = regexp.MustCompile(`(?m)^\s*Tests\s+(\d+ \w+.*)$`)
+	vitestFailing = regexp.MustCompile(`(?m)^\s*FAIL\s+(.+ > .+?)\s*$`)
+)
+
+func parseVitest(output string) (Result, bool) {
+	var r Result
+	summaries := vitestSummary.FindAllStringSubmatch(output, -1)
+	if len(summaries) == 0 {
+		return r, false
+	}
+	addCounts(&r, summaries[len(summaries)-1][1])
+	r.Failing = matches(vitestFailing, output)
+	return r, true
+}
+
+var (
+	mochaCount   = regexp.MustCompile(`(?m)^\s*(\d+ (?:passing|failing|pending))\b`)
+	mochaFailing = regexp.MustCompile(`(?m)^\s+\d+\) (.+)$`)
+)
+
+func parseMocha(output string) (Result, bool) {
+	var r Result
+	counts := mochaCount.FindAllStringSubmatch(output, -1)
+	for _, m := range counts {
+		addCounts(&r, m[1])
+	}
+	if r.Failed > 0 {
+		r.Failing = matches(mochaFailing, output)
+	}
+	return r, len(counts) > 0
+}
+
+var (
+	rspecSummary = regexp.MustCompile(`(?m)^(\d+) examples?, (\d+) failures?(?:, (\d+) pending)?`)
+	rspecFailing = regexp.MustCompile(`(?m)^rspec \S+ # (.+)$`)
+)
+
+func parseRSpec(output string) (Result, bool) {
+	var r Result
+	m := rspecSummary.FindStringSubmatch(output)
+	if m == nil {
+		return r, false
+	}
+	examples, _ := strconv.Atoi(m[1])
+	r.Failed, _ = strconv.Atoi(m[2])
+	r.Skipped, _ = strconv.Atoi(m[3])
+	r.Passed = examples - r.Failed - r.Skipped
+	r.Failing = matches(rspecFailing, output)
+	return r, true
+}
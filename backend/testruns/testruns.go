@@ -0,0 +1,127 @@
+// Package testruns recognizes shell commands that run a test suite and
+// extracts pass/fail/skip counts and failing test names from their output.
+package testruns
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Frameworks.
+const (
+	Go     = "go"
+	Pytest = "pytest"
+	Cargo  = "cargo"
+	Jest   = "jest"
+	Vitest = "vitest"
+	Mocha  = "mocha"
+	RSpec  = "rspec"
+	// Script is a test script (npm test, make test, ...) whose runner isn't
+	// known from the command; its output is parsed with every parser.
+	Script = "script"
+)
+
+// Result is what a test run's output says about the tests.
+type Result struct {
+	Framework string // framework whose output format matched
+	Passed    int
+	Failed    int
+	Skipped   int
+	Failing   []string // names of failing tests, in output order
+	// BuildFailed is set when the tests couldn't run at all, e.g. a
+	// compile error.
+	BuildFailed bool
+}
+
+// detectors match a simple command from its command word on.
+var detectors = []struct {
+	framework string
+	re        *regexp.Regexp
+}{
+	{Go, regexp.MustCompile(`^go\s+test\b`)},
+	{Pytest, regexp.MustCompile(`^(?:pytest|py\.test)\b|^python[\d.]*\s+-m\s+pytest\b`)},
+	{Cargo, regexp.MustCompile(`^cargo\s+(?:test|nextest)\b`)},
+	{Vitest, regexp.MustCompile(`^vitest\b`)},
+	{Jest, regexp.MustCompile(`^jest\b`)},
+	{Mocha, regexp.MustCompile(`^mocha\b`)},
+	{RSpec, regexp.MustCompile(`^rspec\b`)},
+	{Script, regexp.MustCompile(`^(?:npm|yarn|pnpm|bun)\s+(?:run\s+)?test\b|^make\s+(?:test|check)\b|^tox\b`)},
+}
+
+var (
+	// commandSeparator splits a command line into simple commands.
+	commandSeparator = regexp.MustCompile(`&&|\|\||[;|&\n]`)
+	// envAssignments are variable assignments before the command word.
+	envAssignments = regexp.MustCompile(`^(?:\w+=\S*\s+)+`)
+	// wrapper is a command that runs the command after it.
+	wrapper = regexp.MustCompile(`^(?:sudo|time|env|nice|nohup|npx|bunx|yarn|pnpm(?:\s+(?:exec|dlx))?|npm\s+exec|bundle\s+exec|poetry\s+run|pipenv\s+run|uv\s+run|timeout\s+\S+)\s+`)
+)
+
+// Detect returns the framework a command runs tests with, or "" if it
+// doesn't look like a test run. Only the command word of each simple
+// command counts, after wrappers such as npx or bundle exec, so
+// `pip install pytest` isn't a test run.
+func Detect(command string) string {
+	for _, simple := range commandSeparator.Split(command, -1) {
+		cmd := strings.Trim(strings.TrimSpace(simple), "()")
+		for {
+			cmd = commandWord(envAssignments.ReplaceAllString(strings.TrimSpace(cmd), ""))
+			for _, d := range detectors {
+				if d.re.MatchString(cmd) {
+					return d.framework
+				}
+			}
+			loc := wrapper.FindStringIndex(cmd)
+			if loc == nil {
+				break
+			}
+			cmd = cmd[loc[1]:]
+		}
+	}
+	return ""
+}
+
+// commandWord strips the directory from the command word, so
+// ./node_modules/.bin/jest is jest.
+func commandWord(cmd string) string {
+	word := cmd
+	if i := strings.IndexAny(cmd, " \t"); i >= 0 {
+		word = cmd[:i]
+	}
+	if i := strings.LastIndex(word, "/"); i >= 0 {
+		return cmd[i+1:]
+	}
+	return cmd
+}
+
+var parsers = map[string]func(string) (Result, bool){
+	Go:     parseGo,
+	Pytest: parsePytest,
+	Cargo:  parseCargo,
+	Jest:   parseJest,
+	Vitest: parseVitest,
+	Mocha:  parseMocha,
+	RSpec:  parseRSpec,
+}
+
+// parseOrder is the order Script output is tried in; vitest before jest
+// since both print a "Tests" summary.
+var parseOrder = []string{Go, Pytest, Cargo, Vitest, Jest, Mocha, RSpec}
+
+// Parse extracts test results from the output of a test run by framework.
+// ok is false if the output has no recognizable results, e.g. because it
+// was cut short.
+func Parse(framework, output string) (Result, bool) {
+	if parse, found := parsers[framework]; found {
+		r, ok := parse(output)
+		r.Framework = framework
+		return r, ok
+	}
+	for _, f := range parseOrder {
+		if r, ok := parsers[f](output); ok {
+			r.Framework = f
+			return r, true
+		}
+	}
+	return Result{Framework: framework}, false
+}
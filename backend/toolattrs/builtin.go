@@ -0,0 +1,180 @@
+package toolattrs
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"agent-observer/testruns"
+)
+
+func init() {
+	Register("Bash", bashAttrs)
+	Register("Read", readAttrs)
+	Register("Write", writeAttrs)
+	Register("Edit", editAttrs)
+	Register("MultiEdit", multiEditAttrs)
+	Register("NotebookEdit", InputFields(map[string]string{"file_path": "notebook_path"}))
+	Register("Grep", searchAttrs)
+	Register("Glob", searchAttrs)
+	Register("WebFetch", InputFields(map[string]string{"url": "url"}))
+	Register("WebSearch", InputFields(map[string]string{"query": "query"}))
+	Register("Task", InputFields(map[string]string{"subagent_type": "subagent_type", "description": "description"}))
+	RegisterPrefix("mcp__", mcpAttrs)
+}
+
+var exitCodePattern = regexp.MustCompile(`(?m)^(?:Exit code|exit status) (\d+)`)
+
+func bashAttrs(call Call) map[string]interface{} {
+	attrs := map[string]interface{}{}
+	if cmd, ok := call.Input["command"].(string); ok {
+		attrs["command"] = cmd
+		if fields := strings.Fields(cmd); len(fields) > 0 {
+			attrs["program"] = fields[0]
+		}
+	}
+	if !call.HasResult {
+		return attrs
+	}
+	if code, ok := ExitCode(call.Result, call.IsError); ok {
+		attrs["exit_code"] = code
+	}
+	cmd, _ := call.Input["command"].(string)
+	if framework := testruns.Detect(cmd); framework != "" {
+		attrs["test_framework"] = framework
+		if result, ok := testruns.Parse(framework, call.Result); ok {
+			attrs["test_framework"] = result.Framework
+			attrs["tests_passed"] = result.Passed
+			attrs["tests_failed"] = result.Failed
+			attrs["tests_skipped"] = result.Skipped
+		}
+	}
+	return attrs
+}
+
+// ExitCode returns the exit code of a Bash tool result: the reported code
+// for a failed command, 0 for one that succeeded, and ok false if a failed
+// command's code isn't in its output.
+func ExitCode(result string, isError bool) (code int, ok bool) {
+	if m := exitCodePattern.FindStringSubmatch(result); m != nil {
+		code, _ = strconv.Atoi(m[1])
+		return code, true
+	}
+	if !isError {
+		return 0, true
+	}
+	return 0, false
+}
+
+func readAttrs(call Call) map[string]interface{} {
+	attrs := InputFields(map[string]string{"file_path": "file_path"})(call)
+	if call.HasResult && !call.IsError {
+		attrs["lines_read"] = countLines(call.Result)
+	}
+	return attrs
+}
+
+func writeAttrs(call Call) map[string]interface{} {
+	attrs := InputFields(map[string]string{"file_path": "file_path"})(call)
+	if content, ok := call.Input["content"].(string); ok {
+		attrs["lines_added"] = countLines(content)
+		attrs["lines_removed"] = 0
+	}
+	return attrs
+}
+
+func editAttrs(call Call) map[string]interface{} {
+	attrs := InputFields(map[string]string{"file_path": "file_path"})(call)
+	oldStr, _ := call.Input["old_string"].(string)
+	newStr, _ := call.Input["new_string"].(string)
+	added, removed := lineDelta(oldStr, newStr)
+	attrs["lines_added"] = added
+	attrs["lines_removed"] = removed
+	return attrs
+}
+
+func multiEditAttrs(call Call) map[string]interface{} {
+	attrs := InputFields(map[string]string{"file_path": "file_path"})(call)
+	edits, _ := call.Input["edits"].([]interface{})
+	var added, removed int
+	for _, e := range edits {
+		edit, ok := e.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		oldStr, _ := edit["old_string"].(string)
+		newStr, _ := edit["new_string"].(string)
+		a, r := lineDelta(oldStr, newStr)
+		added += a
+		removed += r
+	}
+	attrs["lines_added"] = added
+	attrs["lines_removed"] = removed
+	attrs["edit_count"] = len(edits)
+	return attrs
+}
+
+var foundPattern = regexp.MustCompile(`^Found (\d+) (?:file|match|line)`)
+
+func searchAttrs(call Call) map[string]interface{} {
+	attrs := InputFields(map[string]string{"pattern": "pattern", "path": "path", "glob": "glob"})(call)
+	if !call.HasResult || call.IsError {
+		return attrs
+	}
+	result := strings.TrimSpace(call.Result)
+	switch {
+	case result == "", strings.HasPrefix(result, "No files found"), strings.HasPrefix(result, "No matches found"):
+		attrs["match_count"] = 0
+	case foundPattern.MatchString(result):
+		n, _ := strconv.Atoi(foundPattern.FindStringSubmatch(result)[1])
+		attrs["match_count"] = n
+	default:
+		attrs["match_count"] = countLines(result)
+	}
+	return attrs
+}
+
+// mcpAttrs splits MCP tool names of the form mcp__<server>__<tool>.
+func mcpAttrs(call Call) map[string]interface{} {
+	parts := strings.SplitN(strings.TrimPrefix(call.Name, "mcp__"), "__", 2)
+	attrs := map[string]interface{}{"mcp_server": parts[0]}
+	if len(parts) == 2 {
+		attrs["mcp_tool"] = parts[1]
+	}
+	return attrs
+}
+
+// countLines counts the lines of s, not counting a trailing newline.
+func countLines(s string) int {
+	s = strings.TrimSuffix(s, "\n")
+	if s == "" {
+		return 0
+	}
+	return strings.Count(s, "\n") + 1
+}
+
+// lineDelta estimates the lines an edit adds and removes: lines of the
+// replacement that weren't in the original count as added, and vice versa.
+func lineDelta(oldStr, newStr string) (added, removed int) {
+	oldLines := make(map[string]int)
+	for _, l := range strings.Split(oldStr, "\n") {
+		oldLines[l]++
+	}
+	for _, l := range strings.Split(newStr, "\n") {
+		if oldLines[l] > 0 {
+			oldLines[l]--
+		} else {
+			added++
+		}
+	}
+	for _, n := range oldLines {
+		removed += n
+	}
+	if oldStr == "" {
+		removed = 0
+	}
+	if newStr == "" {
+		added = 0
+	}
+	return added, removed
+}
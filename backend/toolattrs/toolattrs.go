@@ -0,0 +1,131 @@
+// Package toolattrs turns tool calls into flat, queryable span attributes
+// (command, file path, match count, ...) through a registry of per-tool
+// extractors. Built-in extractors cover Claude Code's tools; others can be
+// added with Register, RegisterPrefix or LoadConfig.
+package toolattrs
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Call is a finished or in-flight tool call as seen by an extractor.
+type Call struct {
+	Name      string
+	Input     map[string]interface{}
+	Result    string
+	HasResult bool
+	IsError   bool
+	Duration  time.Duration // zero while in flight
+}
+
+// Extractor returns the attributes to add to a tool call's span. Keys
+// should be snake_case so they can be queried with json_extract.
+type Extractor func(call Call) map[string]interface{}
+
+type prefixExtractor struct {
+	prefix string
+	fn     Extractor
+}
+
+var registry = struct {
+	sync.RWMutex
+	exact    map[string]Extractor
+	prefixes []prefixExtractor
+}{exact: make(map[string]Extractor)}
+
+// Register sets the extractor for a tool name, replacing any existing one.
+func Register(toolName string, fn Extractor) {
+	registry.Lock()
+	defer registry.Unlock()
+	registry.exact[toolName] = fn
+}
+
+// RegisterPrefix sets the extractor for tools whose names start with
+// prefix, used when no exact extractor matches. The longest prefix wins.
+func RegisterPrefix(prefix string, fn Extractor) {
+	registry.Lock()
+	defer registry.Unlock()
+	for i, p := range registry.prefixes {
+		if p.prefix == prefix {
+			registry.prefixes[i].fn = fn
+			return
+		}
+	}
+	registry.prefixes = append(registry.prefixes, prefixExtractor{prefix: prefix, fn: fn})
+}
+
+func lookup(toolName string) Extractor {
+	registry.RLock()
+	defer registry.RUnlock()
+	if fn, ok := registry.exact[toolName]; ok {
+		return fn
+	}
+	var best prefixExtractor
+	for _, p := range registry.prefixes {
+		if strings.HasPrefix(toolName, p.prefix) && len(p.prefix) > len(best.prefix) {
+			best = p
+		}
+	}
+	return best.fn
+}
+
+// Extract returns the attributes for a tool call: duration_ms for finished
+// calls plus whatever the tool's extractor adds. Tools without an
+// extractor only get duration_ms.
+func Extract(call Call) map[string]interface{} {
+	attrs := make(map[string]interface{})
+	if call.HasResult {
+		attrs["duration_ms"] = call.Duration.Milliseconds()
+	}
+	if fn := lookup(call.Name); fn != nil {
+		for k, v := range fn(call) {
+			attrs[k] = v
+		}
+	}
+	return attrs
+}
+
+// LoadConfig registers extractors for tools that aren't built in from a
+// JSON file mapping tool names to {attribute: input field}, e.g.
+//
+//	{"mcp__jira__create_issue": {"project": "project_key", "summary": "summary"}}
+//
+// A name ending in "*" is registered as a prefix.
+func LoadConfig(path string) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read tool attribute config %s: %w", path, err)
+	}
+	var cfg map[string]map[string]string
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return fmt.Errorf("failed to parse tool attribute config %s: %w", path, err)
+	}
+	for name, fields := range cfg {
+		fn := InputFields(fields)
+		if prefix, ok := strings.CutSuffix(name, "*"); ok {
+			RegisterPrefix(prefix, fn)
+		} else {
+			Register(name, fn)
+		}
+	}
+	return nil
+}
+
+// InputFields returns an extractor that copies input fields to attributes,
+// keyed by attribute name.
+func InputFields(fields map[string]string) Extractor {
+	return func(call Call) map[string]interface{} {
+		attrs := make(map[string]interface{})
+		for attr, field := range fields {
+			if v, ok := call.Input[field]; ok {
+				attrs[attr] = v
+			}
+		}
+		return attrs
+	}
+}
@@ -0,0 +1,90 @@
+// Package toolerrors classifies failed tool calls into categories from the
+// text of their tool_result.
+package toolerrors
+
+import (
+	"strings"
+
+	"agent-observer/toolattrs"
+)
+
+// Failure categories.
+const (
+	NonZeroExit      = "non_zero_exit"
+	UserRejected     = "user_rejected"
+	PermissionDenied = "permission_denied"
+	FileMissing      = "file_missing"
+	Timeout          = "timeout"
+	EditNoMatch      = "edit_string_not_found"
+	EditAmbiguous    = "edit_string_ambiguous"
+	Other            = "other"
+)
+
+// Categories lists every category Classify can return, most specific first.
+var Categories = []string{UserRejected, PermissionDenied, Timeout, EditNoMatch, EditAmbiguous, FileMissing, NonZeroExit, Other}
+
+// rule matches a category by case-insensitive substrings of the result.
+type rule struct {
+	category string
+	markers  []string
+}
+
+// rules are tried in order, so user rejection and permission denials win
+// over the exit code that usually accompanies them.
+var rules = []rule{
+	{UserRejected, []string{
+		"the user doesn't want to proceed with this tool use",
+		"[request interrupted by user",
+		"user rejected",
+	}},
+	{PermissionDenied, []string{
+		"permission to use",
+		"has been denied",
+		"permission denied",
+		"operation not permitted",
+		"eacces",
+		"requires approval",
+	}},
+	{Timeout, []string{
+		"command timed out",
+		"timed out after",
+		"timeout exceeded",
+		"etimedout",
+		"deadline exceeded",
+	}},
+	{EditNoMatch, []string{
+		"string to replace not found",
+		"old_string not found",
+	}},
+	{EditAmbiguous, []string{
+		"found multiple matches of the string to replace",
+	}},
+	{FileMissing, []string{
+		"file does not exist",
+		"no such file or directory",
+		"enoent",
+		"path does not exist",
+		"file not found",
+	}},
+}
+
+// Classify returns the failure category of a tool result that was flagged
+// as an error. Results that match nothing more specific are NonZeroExit if
+// they carry an exit code or come from the shell tool, otherwise Other.
+func Classify(toolName, result string) string {
+	lower := strings.ToLower(result)
+	for _, r := range rules {
+		for _, m := range r.markers {
+			if strings.Contains(lower, m) {
+				return r.category
+			}
+		}
+	}
+	if code, ok := toolattrs.ExitCode(result, true); ok && code != 0 {
+		return NonZeroExit
+	}
+	if toolName == "Bash" || toolName == "BashOutput" {
+		return NonZeroExit
+	}
+	return Other
+}
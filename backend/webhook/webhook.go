@@ -0,0 +1,177 @@
+// Package webhook renders payloads from templates and delivers them to
+// HTTP endpoints with retries.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Delivery defaults.
+const (
+	DefaultMaxRetries = 3
+	DefaultTimeout    = 10 * time.Second
+)
+
+// Backoff is the delay before the first retry; it doubles on each retry.
+var Backoff = time.Second
+
+// Target is an endpoint payloads are delivered to.
+type Target struct {
+	Name    string            `json:"name"`
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers,omitempty"`
+	// Template is a text/template for the request body, executed with the
+	// payload data. Empty sends the data as JSON.
+	Template   string `json:"template,omitempty"`
+	MaxRetries *int   `json:"max_retries,omitempty"` // nil means DefaultMaxRetries
+	Timeout    string `json:"timeout,omitempty"`     // per attempt, e.g. "5s"
+}
+
+// Attempt is one delivery attempt.
+type Attempt struct {
+	At         time.Time `json:"at"`
+	StatusCode int       `json:"status_code,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	DurationMs int64     `json:"duration_ms"`
+}
+
+// Result is the outcome of a delivery.
+type Result struct {
+	Delivered bool      `json:"delivered"`
+	Attempts  []Attempt `json:"attempts"`
+}
+
+var funcs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+}
+
+// Validate checks a target's URL, template and timeout.
+func (t Target) Validate() error {
+	if !strings.HasPrefix(t.URL, "http://") && !strings.HasPrefix(t.URL, "https://") {
+		return fmt.Errorf("webhook %s: url must be http or https", t.Name)
+	}
+	if t.Template != "" {
+		if _, err := template.New(t.Name).Funcs(funcs).Parse(t.Template); err != nil {
+			return fmt.Errorf("webhook %s: %w", t.Name, err)
+		}
+	}
+	if t.Timeout != "" {
+		if _, err := time.ParseDuration(t.Timeout); err != nil {
+			return fmt.Errorf("webhook %s: invalid timeout %q", t.Name, t.Timeout)
+		}
+	}
+	return nil
+}
+
+// Render builds the request body for data: the target's template applied
+// to data, or data as JSON. Templates can use the json, upper and lower
+// functions.
+func (t Target) Render(data interface{}) ([]byte, error) {
+	if t.Template == "" {
+		return json.Marshal(data)
+	}
+	tmpl, err := template.New(t.Name).Funcs(funcs).Parse(t.Template)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Send POSTs body to the target, retrying with exponential backoff on
+// network errors and on responses that may succeed later (408, 429 and
+// 5xx). headers are added to the target's own headers.
+func (t Target) Send(ctx context.Context, body []byte, headers map[string]string) Result {
+	retries := DefaultMaxRetries
+	if t.MaxRetries != nil {
+		retries = *t.MaxRetries
+	}
+	timeout := DefaultTimeout
+	if d, err := time.ParseDuration(t.Timeout); err == nil && d > 0 {
+		timeout = d
+	}
+	client := &http.Client{Timeout: timeout}
+
+	var result Result
+	delay := Backoff
+	for attempt := 0; attempt <= retries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return result
+			case <-time.After(delay):
+			}
+			delay *= 2
+		}
+
+		a := t.attempt(ctx, client, body, headers)
+		result.Attempts = append(result.Attempts, a)
+		if a.Error == "" {
+			result.Delivered = true
+			return result
+		}
+		if !a.retryable() {
+			return result
+		}
+	}
+	return result
+}
+
+// retryable reports whether a failed attempt is worth repeating: the
+// request never got a response, or the receiver is overloaded, timed out
+// or failed. Other 4xx responses will fail the same way again.
+func (a Attempt) retryable() bool {
+	return a.StatusCode == 0 || a.StatusCode == http.StatusRequestTimeout ||
+		a.StatusCode == http.StatusTooManyRequests || a.StatusCode >= 500
+}
+
+func (t Target) attempt(ctx context.Context, client *http.Client, body []byte, headers map[string]string) Attempt {
+	a := Attempt{At: time.Now()}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.URL, bytes.NewReader(body))
+	if err != nil {
+		a.Error = err.Error()
+		a.DurationMs = time.Since(a.At).Milliseconds()
+		return a
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "agent-observer")
+	for k, v := range t.Headers {
+		req.Header.Set(k, v)
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		a.Error = err.Error()
+		a.DurationMs = time.Since(a.At).Milliseconds()
+		return a
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	a.StatusCode = resp.StatusCode
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		a.Error = resp.Status
+	}
+	a.DurationMs = time.Since(a.At).Milliseconds()
+	return a
+}
@@ -1,5 +1,5 @@
 import axios from 'axios';
-import type { TeamWithStats, TeamDetail, AgentDetail, Agent, Conversation, Message, Trace } from '../types';
+import type { TeamWithStats, TeamDetail, TeamGroup, AgentDetail, Agent, Conversation, Message, Trace } from '../types';
 
 const api = axios.create({
   baseURL: '/api',
@@ -55,7 +55,7 @@ export async function fetchAgentTraces(agentId: string): Promise<Trace[]> {
   return data;
 }
 
-export async function fetchTeamGroup(teamName: string): Promise<TeamWithStats[]> {
-  const { data } = await api.get<TeamWithStats[]>(`/team-groups/${encodeURIComponent(teamName)}`);
+export async function fetchTeamGroup(teamName: string): Promise<TeamGroup> {
+  const { data } = await api.get<TeamGroup>(`/team-groups/${encodeURIComponent(teamName)}`);
   return data;
 }
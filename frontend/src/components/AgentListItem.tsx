@@ -1,6 +1,6 @@
 import { Bot, MessageSquare } from 'lucide-react';
 import type { Agent, Message } from '../types';
-import { cn, formatRelativeTime } from '../lib/utils';
+import { agentStatusLabels, cn, formatRelativeTime, getStatusColor } from '../lib/utils';
 
 interface AgentListItemProps {
   agent: Agent;
@@ -12,7 +12,6 @@ interface AgentListItemProps {
 export default function AgentListItem({ agent, messages, isSelected, onClick }: AgentListItemProps) {
   const lastMessage = messages.length > 0 ? messages[messages.length - 1] : null;
   const roleLabel = agent.role === 'lead' ? '主导' : '协作';
-  const statusMap: Record<string, string> = { active: '活跃', idle: '空闲', error: '错误' };
 
   return (
     <button
@@ -42,17 +41,29 @@ export default function AgentListItem({ agent, messages, isSelected, onClick }:
       {/* Status + message count */}
       <div className="flex items-center gap-2 mb-1.5 ml-6">
         <span
-          className={cn(
-            'w-1.5 h-1.5 rounded-full shrink-0',
-            agent.status === 'active' ? 'bg-green-500' : agent.status === 'error' ? 'bg-red-500' : 'bg-gray-500'
-          )}
+          className={cn('w-1.5 h-1.5 rounded-full shrink-0', getStatusColor(agent.status))}
         />
-        <span className="text-[10px] text-gray-500">{statusMap[agent.status] ?? agent.status}</span>
+        <span className="text-[10px] text-gray-500">{agentStatusLabels[agent.status] ?? agent.status}</span>
         {agent.specialty && (
           <span className="text-[10px] text-gray-600 truncate">{agent.specialty}</span>
         )}
       </div>
 
+      {/* Todo list progress */}
+      {agent.todos && (
+        <div className="flex items-center gap-2 mb-1.5 ml-6" title={`${agent.todos.completed}/${agent.todos.total} 项待办已完成`}>
+          <div className="flex-1 h-1 rounded-full bg-gray-800 overflow-hidden">
+            <div
+              className={cn('h-full rounded-full', agent.todos.pct >= 100 ? 'bg-green-500' : 'bg-blue-500')}
+              style={{ width: `${agent.todos.pct}%` }}
+            />
+          </div>
+          <span className="text-[10px] text-gray-500 shrink-0">
+            待办 {agent.todos.completed}/{agent.todos.total} · {Math.round(agent.todos.pct)}%
+          </span>
+        </div>
+      )}
+
       {/* Last message preview */}
       {lastMessage ? (
         <div className="ml-6 flex items-start gap-1.5">
@@ -1,6 +1,6 @@
 import { useEffect, useRef } from 'react';
 import type { Agent, Message } from '../types';
-import { cn } from '../lib/utils';
+import { cn, getStatusColor } from '../lib/utils';
 import MessageBubble from './MessageBubble';
 
 interface AgentPaneProps {
@@ -19,7 +19,7 @@ export default function AgentPane({ agent, messages }: AgentPaneProps) {
 
   const borderColor = agent.role === 'lead' ? 'border-l-blue-500' : 'border-l-emerald-500';
   const roleBg = agent.role === 'lead' ? 'bg-blue-500/15 text-blue-400' : 'bg-emerald-500/15 text-emerald-400';
-  const statusDot = agent.status === 'active' ? 'bg-green-500' : agent.status === 'error' ? 'bg-red-500' : 'bg-gray-500';
+  const statusDot = getStatusColor(agent.status);
 
   return (
     <div className={cn('flex flex-col h-full border-l-2 min-w-0', borderColor, 'first:border-l-0')}>
@@ -10,7 +10,7 @@ interface TeamCardProps {
 export default function TeamCard({ team }: TeamCardProps) {
   const navigate = useNavigate();
 
-  const statusMap: Record<string, string> = { running: '运行中', idle: '空闲', stopped: '已停止' };
+  const statusMap: Record<string, string> = { running: '运行中', idle: '空闲', stopped: '已停止', error: '错误' };
   const statusLabel = statusMap[team.status] ?? team.status;
 
   return (
@@ -37,7 +37,8 @@ export default function TeamCard({ team }: TeamCardProps) {
             'inline-flex items-center gap-1.5 px-2 py-0.5 rounded-full text-[10px] font-semibold uppercase shrink-0',
             team.status === 'running' && 'bg-green-500/10 text-green-400',
             team.status === 'idle' && 'bg-gray-500/10 text-gray-400',
-            team.status === 'stopped' && 'bg-red-500/10 text-red-400'
+            team.status === 'stopped' && 'bg-red-500/10 text-red-400',
+            team.status === 'error' && 'bg-red-500/10 text-red-400'
           )}
         >
           <span
@@ -45,7 +46,8 @@ export default function TeamCard({ team }: TeamCardProps) {
               'w-1.5 h-1.5 rounded-full',
               team.status === 'running' && 'bg-green-500',
               team.status === 'idle' && 'bg-gray-500',
-              team.status === 'stopped' && 'bg-red-500'
+              team.status === 'stopped' && 'bg-red-500',
+              team.status === 'error' && 'bg-red-500'
             )}
           />
           {statusLabel}
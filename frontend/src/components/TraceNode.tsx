@@ -40,13 +40,13 @@ export default function TraceNode({ trace, depth = 0, maxDuration, onSelect, sel
           <span className="w-3.5 shrink-0" />
         )}
 
-        <span className={cn('text-xs font-medium shrink-0', getSpanTextColor(trace.span_name))}>
+        <span className={cn('text-xs font-medium shrink-0', getSpanTextColor(trace.span_name, trace.status))}>
           {trace.span_name}
         </span>
 
         <div className="flex-1 mx-2 h-3 bg-gray-800/50 rounded-full overflow-hidden">
           <div
-            className={cn('h-full rounded-full transition-all duration-200', getSpanColor(trace.span_name))}
+            className={cn('h-full rounded-full transition-all duration-200', getSpanColor(trace.span_name, trace.status))}
             style={{ width: `${barWidth}%`, opacity: 0.6 }}
           />
         </div>
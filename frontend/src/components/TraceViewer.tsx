@@ -1,5 +1,5 @@
 import { useState, useMemo } from 'react';
-import { Clock, Tag, FileText } from 'lucide-react';
+import { Clock, Tag, FileText, AlertCircle, Activity } from 'lucide-react';
 import type { Trace } from '../types';
 import { formatDate, formatDuration, getSpanTextColor } from '../lib/utils';
 import TraceNode from './TraceNode';
@@ -73,7 +73,7 @@ export default function TraceViewer({ traces }: TraceViewerProps) {
         {selectedTrace ? (
           <div className="space-y-4">
             <div>
-              <h3 className={`text-base font-semibold ${getSpanTextColor(selectedTrace.span_name)}`}>
+              <h3 className={`text-base font-semibold ${getSpanTextColor(selectedTrace.span_name, selectedTrace.status)}`}>
                 {selectedTrace.span_name}
               </h3>
               <p className="text-xs text-gray-600 mt-1 font-mono">{selectedTrace.id}</p>
@@ -101,6 +101,18 @@ export default function TraceViewer({ traces }: TraceViewerProps) {
               )}
             </div>
 
+            {selectedTrace.status === 'error' && (
+              <div className="flex items-start gap-2 rounded-md border border-red-900/60 bg-red-950/40 p-3 text-sm">
+                <AlertCircle className="w-4 h-4 text-red-400 shrink-0 mt-0.5" />
+                <div>
+                  <p className="text-red-400 font-medium">错误</p>
+                  {selectedTrace.status_message && (
+                    <p className="text-red-300/80 text-xs font-mono mt-1 break-all">{selectedTrace.status_message}</p>
+                  )}
+                </div>
+              </div>
+            )}
+
             {selectedTrace.attributes && Object.keys(selectedTrace.attributes).length > 0 && (
               <div>
                 <div className="flex items-center gap-2 text-sm text-gray-400 mb-2">
@@ -113,6 +125,30 @@ export default function TraceViewer({ traces }: TraceViewerProps) {
               </div>
             )}
 
+            {selectedTrace.events && selectedTrace.events.length > 0 && (
+              <div>
+                <div className="flex items-center gap-2 text-sm text-gray-400 mb-2">
+                  <Activity className="w-4 h-4" />
+                  <span>事件</span>
+                </div>
+                <div className="space-y-2">
+                  {selectedTrace.events.map((event, i) => (
+                    <div key={i} className="bg-gray-900/60 rounded-md p-3">
+                      <div className="flex items-center justify-between text-xs">
+                        <span className={event.name === 'exception' ? 'text-red-400' : 'text-gray-300'}>{event.name}</span>
+                        <span className="text-gray-600 font-mono">{formatDate(event.timestamp)}</span>
+                      </div>
+                      {event.attributes && Object.keys(event.attributes).length > 0 && (
+                        <pre className="mt-2 text-xs text-gray-400 font-mono overflow-x-auto">
+                          {JSON.stringify(event.attributes, null, 2)}
+                        </pre>
+                      )}
+                    </div>
+                  ))}
+                </div>
+              </div>
+            )}
+
             {selectedTrace.parent_span_id && (
               <div className="text-xs text-gray-600">
                 Parent: <span className="font-mono">{selectedTrace.parent_span_id}</span>
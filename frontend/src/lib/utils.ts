@@ -40,15 +40,30 @@ export function cn(...classes: (string | boolean | undefined | null)[]): string
   return classes.filter(Boolean).join(' ');
 }
 
+export const agentStatusLabels: Record<string, string> = {
+  idle: '空闲',
+  thinking: '思考中',
+  running_tool: '执行工具',
+  awaiting_permission: '等待授权',
+  awaiting_user: '等待用户',
+  errored: '出错',
+  completed: '已完成',
+  stale: '无响应',
+};
+
 export function getStatusColor(status: string): string {
   switch (status) {
     case 'running':
-    case 'active':
+    case 'thinking':
+    case 'running_tool':
       return 'bg-green-500';
-    case 'idle':
-      return 'bg-gray-500';
+    case 'awaiting_permission':
+      return 'bg-amber-500';
+    case 'awaiting_user':
+      return 'bg-blue-500';
     case 'stopped':
     case 'error':
+    case 'errored':
       return 'bg-red-500';
     default:
       return 'bg-gray-500';
@@ -58,19 +73,24 @@ export function getStatusColor(status: string): string {
 export function getStatusTextColor(status: string): string {
   switch (status) {
     case 'running':
-    case 'active':
+    case 'thinking':
+    case 'running_tool':
       return 'text-green-400';
-    case 'idle':
-      return 'text-gray-400';
+    case 'awaiting_permission':
+      return 'text-amber-400';
+    case 'awaiting_user':
+      return 'text-blue-400';
     case 'stopped':
     case 'error':
+    case 'errored':
       return 'text-red-400';
     default:
       return 'text-gray-400';
   }
 }
 
-export function getSpanColor(spanName: string): string {
+export function getSpanColor(spanName: string, status?: string): string {
+  if (status === 'error') return 'bg-red-500';
   if (spanName.startsWith('llm')) return 'bg-purple-500';
   if (spanName.startsWith('tool')) return 'bg-blue-500';
   if (spanName.includes('decision')) return 'bg-cyan-500';
@@ -78,7 +98,8 @@ export function getSpanColor(spanName: string): string {
   return 'bg-gray-500';
 }
 
-export function getSpanTextColor(spanName: string): string {
+export function getSpanTextColor(spanName: string, status?: string): string {
+  if (status === 'error') return 'text-red-400';
   if (spanName.startsWith('llm')) return 'text-purple-400';
   if (spanName.startsWith('tool')) return 'text-blue-400';
   if (spanName.includes('decision')) return 'text-cyan-400';
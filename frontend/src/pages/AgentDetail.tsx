@@ -13,7 +13,7 @@ import { fetchAgent, fetchAgentTraces, fetchTeamConversations } from '../api/cli
 import StatsCard from '../components/StatsCard';
 import TraceViewer from '../components/TraceViewer';
 import ConversationList from '../components/ConversationList';
-import { cn, formatDate } from '../lib/utils';
+import { agentStatusLabels, cn, formatDate, getStatusColor, getStatusTextColor } from '../lib/utils';
 import type { Conversation, Trace } from '../types';
 
 export default function AgentDetail() {
@@ -96,20 +96,14 @@ export default function AgentDetail() {
                 <span
                   className={cn(
                     'inline-flex items-center gap-1 px-2 py-0.5 rounded-full text-[10px] font-medium',
-                    agent.status === 'active' && 'bg-green-500/10 text-green-400',
-                    agent.status === 'idle' && 'bg-gray-500/10 text-gray-400',
-                    agent.status === 'error' && 'bg-red-500/10 text-red-400'
+                    'bg-gray-800/60',
+                    getStatusTextColor(agent.status)
                   )}
                 >
                   <span
-                    className={cn(
-                      'w-1.5 h-1.5 rounded-full',
-                      agent.status === 'active' && 'bg-green-500',
-                      agent.status === 'idle' && 'bg-gray-500',
-                      agent.status === 'error' && 'bg-red-500'
-                    )}
+                    className={cn('w-1.5 h-1.5 rounded-full', getStatusColor(agent.status))}
                   />
-                  {agent.status === 'active' ? '活跃' : agent.status === 'idle' ? '空闲' : '错误'}
+                  {agentStatusLabels[agent.status] ?? agent.status}
                 </span>
               </div>
               <p className="text-sm text-gray-500 mt-1">{agent.specialty}</p>
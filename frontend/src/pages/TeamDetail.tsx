@@ -96,7 +96,7 @@ export default function TeamDetail() {
     return agents?.find((a) => a.id === selectedAgentId) ?? null;
   }, [agents, selectedAgentId]);
 
-  const statusMap: Record<string, string> = { running: '运行中', idle: '空闲', stopped: '已停止' };
+  const statusMap: Record<string, string> = { running: '运行中', idle: '空闲', stopped: '已停止', error: '错误' };
 
   return (
     <div className="h-full flex">
@@ -128,7 +128,8 @@ export default function TeamDetail() {
                     'inline-flex items-center gap-1 px-2 py-0.5 rounded-full text-[10px] font-semibold shrink-0',
                     team.status === 'running' && 'bg-green-500/10 text-green-400',
                     team.status === 'idle' && 'bg-gray-500/10 text-gray-400',
-                    team.status === 'stopped' && 'bg-red-500/10 text-red-400'
+                    team.status === 'stopped' && 'bg-red-500/10 text-red-400',
+                    team.status === 'error' && 'bg-red-500/10 text-red-400'
                   )}
                 >
                   <span
@@ -136,7 +137,8 @@ export default function TeamDetail() {
                       'w-1.5 h-1.5 rounded-full',
                       team.status === 'running' && 'bg-green-500',
                       team.status === 'idle' && 'bg-gray-500',
-                      team.status === 'stopped' && 'bg-red-500'
+                      team.status === 'stopped' && 'bg-red-500',
+                      team.status === 'error' && 'bg-red-500'
                     )}
                   />
                   {statusMap[team.status] ?? team.status}
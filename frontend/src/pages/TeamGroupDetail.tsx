@@ -1,7 +1,7 @@
 import { useState, useMemo } from 'react';
 import { useParams, Link, useNavigate } from 'react-router-dom';
 import { useQuery, useQueries } from '@tanstack/react-query';
-import { ArrowLeft, Users, MessageSquare, Filter } from 'lucide-react';
+import { ArrowLeft, Users, MessageSquare, Filter, ListChecks } from 'lucide-react';
 import { fetchTeamGroup, fetchConversationMessages } from '../api/client';
 import MessageBubble from '../components/MessageBubble';
 import { cn } from '../lib/utils';
@@ -13,12 +13,13 @@ export default function TeamGroupDetail() {
   const [filterAgentSessionId, setFilterAgentSessionId] = useState<string | null>(null);
 
   // Fetch all teams in this group
-  const { data: groupTeams } = useQuery({
+  const { data: group } = useQuery({
     queryKey: ['teamGroup', teamName],
     queryFn: () => fetchTeamGroup(teamName!),
     enabled: !!teamName,
     refetchInterval: 10000,
   });
+  const groupTeams = group?.teams;
 
   // For each team, fetch its conversation messages using useQueries (hook-safe)
   const messagesQueries = useQueries({
@@ -56,7 +57,13 @@ export default function TeamGroupDetail() {
     return allMessages.filter((m) => m.sourceTeamId === filterAgentSessionId);
   }, [allMessages, filterAgentSessionId]);
 
-  const statusMap: Record<string, string> = { running: '运行中', idle: '空闲', stopped: '已停止' };
+  const statusMap: Record<string, string> = { running: '运行中', idle: '空闲', stopped: '已停止', error: '错误' };
+  const taskStatusMap: Record<string, string> = { pending: '待办', in_progress: '进行中', completed: '已完成' };
+  const taskStatusColor: Record<string, string> = {
+    pending: 'bg-gray-500/10 text-gray-400',
+    in_progress: 'bg-amber-500/10 text-amber-400',
+    completed: 'bg-green-500/10 text-green-400',
+  };
 
   const totalMessages = groupTeams?.reduce((sum, t) => sum + (t.message_count ?? 0), 0) ?? 0;
   const totalAgents = groupTeams?.reduce((sum, t) => sum + (t.agent_count ?? 0), 0) ?? 0;
@@ -127,6 +134,80 @@ export default function TeamGroupDetail() {
               </div>
             ))}
           </div>
+
+          {/* Members from the team config */}
+          {group && group.members.length > 0 && (
+            <>
+              <h3 className="text-[11px] font-semibold text-gray-500 uppercase tracking-wider px-4 pt-3 pb-2 flex items-center gap-1.5">
+                <Users className="w-3 h-3" />
+                团队配置
+                {group.ended_at && (
+                  <span className="text-[10px] px-1.5 py-0.5 rounded-full bg-gray-700/50 text-gray-400 normal-case tracking-normal">已结束</span>
+                )}
+              </h3>
+              <div className="space-y-0.5 px-2 pb-2">
+                {group.members.map((m) => (
+                  <button
+                    key={m.id}
+                    disabled={!m.team_id}
+                    onClick={() => m.team_id && navigate(`/sessions/${m.team_id}`)}
+                    className="w-full text-left px-3 py-2 rounded-lg transition-all duration-200 hover:bg-gray-800/50 disabled:hover:bg-transparent disabled:cursor-default"
+                  >
+                    <div className="flex items-center gap-2">
+                      <span
+                        className={cn(
+                          'w-1.5 h-1.5 rounded-full shrink-0',
+                          m.status === 'running' ? 'bg-green-500' : 'bg-gray-600'
+                        )}
+                      />
+                      <span className="text-sm text-gray-300 truncate">{m.name}</span>
+                      {m.is_lead && (
+                        <span className="text-[10px] px-1.5 py-0.5 rounded-full bg-cyan-500/10 text-cyan-400 shrink-0">负责人</span>
+                      )}
+                      <span className="text-[10px] text-gray-500 ml-auto shrink-0">
+                        {m.team_id ? statusMap[m.status ?? ''] ?? m.status : '未同步'}
+                      </span>
+                    </div>
+                    <div className="text-[11px] text-gray-600 pl-3.5 mt-0.5 truncate">
+                      {m.agent_type}{m.model ? ` · ${m.model}` : ''}
+                    </div>
+                  </button>
+                ))}
+              </div>
+            </>
+          )}
+
+          {/* Shared task list */}
+          {group && group.tasks.length > 0 && (
+            <>
+              <h3 className="text-[11px] font-semibold text-gray-500 uppercase tracking-wider px-4 pt-3 pb-2 flex items-center gap-1.5">
+                <ListChecks className="w-3 h-3" />
+                任务列表
+                <span className="ml-auto normal-case font-normal">
+                  {group.task_counts.completed ?? 0}/{group.tasks.length} 已完成
+                </span>
+              </h3>
+              <div className="space-y-1 px-2 pb-3">
+                {group.tasks.map((t) => (
+                  <div key={t.id} className="px-3 py-2 rounded-lg bg-gray-800/30">
+                    <div className="flex items-center gap-2">
+                      <span className="text-[10px] text-gray-600 shrink-0">#{t.task_id}</span>
+                      <span className="text-xs text-gray-300 truncate">{t.subject}</span>
+                      <span className={cn('text-[10px] px-1.5 py-0.5 rounded-full shrink-0 ml-auto', taskStatusColor[t.status])}>
+                        {taskStatusMap[t.status] ?? t.status}
+                      </span>
+                    </div>
+                    {(t.owner || t.blocked_by.length > 0) && (
+                      <div className="flex items-center gap-2 mt-1 text-[11px] text-gray-600">
+                        {t.owner && <span>{t.owner}</span>}
+                        {t.blocked_by.length > 0 && <span>等待 #{t.blocked_by.join(', #')}</span>}
+                      </div>
+                    )}
+                  </div>
+                ))}
+              </div>
+            </>
+          )}
         </div>
       </div>
 
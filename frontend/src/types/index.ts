@@ -3,7 +3,7 @@ export interface Team {
   name: string;
   description: string;
   created_by: string;
-  status: 'running' | 'stopped' | 'idle';
+  status: 'running' | 'stopped' | 'idle' | 'error';
   team_name?: string;
   created_at: string;
   agents?: Agent[];
@@ -13,6 +13,62 @@ export interface TeamWithStats extends Team {
   agent_count: number;
   conversation_count: number;
   message_count: number;
+  outcome?: Outcome;
+}
+
+export interface TeamMember {
+  id: string;
+  team_name: string;
+  name: string;
+  member_id: string;
+  agent_type: string;
+  model: string;
+  color?: string;
+  cwd?: string;
+  is_lead: boolean;
+  session_id?: string;
+  joined_at: string;
+  // Set once Claude Code deleted the team's directory
+  ended_at?: string;
+  // The member's latest synced session, if any
+  team_id?: string;
+  agent_id?: string;
+  status?: string;
+}
+
+export interface TeamTask {
+  id: string;
+  list_id: string;
+  task_id: string;
+  subject: string;
+  description: string;
+  active_form?: string;
+  status: 'pending' | 'in_progress' | 'completed';
+  owner?: string;
+  blocks: string[];
+  blocked_by: string[];
+  updated_at: string;
+}
+
+export interface InboxMessage {
+  id: string;
+  team_name: string;
+  recipient: string;
+  sender: string;
+  kind: string;
+  text: string;
+  summary?: string;
+  read: boolean;
+  created_at: string;
+}
+
+export interface TeamGroup {
+  teams: TeamWithStats[];
+  members: TeamMember[];
+  tasks: TeamTask[];
+  task_counts: Record<string, number>;
+  inbox: InboxMessage[];
+  ended_at?: string;
 }
 
 export interface TeamDetail {
@@ -40,8 +96,25 @@ export interface Agent {
   role: 'lead' | 'teammate';
   name: string;
   specialty: string;
-  status: 'active' | 'idle' | 'error';
+  status:
+    | 'idle'
+    | 'thinking'
+    | 'running_tool'
+    | 'awaiting_permission'
+    | 'awaiting_user'
+    | 'errored'
+    | 'completed'
+    | 'stale';
   created_at: string;
+  last_activity_at?: string;
+  // Progress of the latest todo list, on agents listed by team
+  todos?: TodoProgress;
+}
+
+export interface TodoProgress {
+  completed: number;
+  total: number;
+  pct: number;
 }
 
 export interface Conversation {
@@ -51,8 +124,12 @@ export interface Conversation {
   title: string;
   started_at: string;
   ended_at?: string;
+  outcome?: Outcome;
+  outcome_reason?: string;
 }
 
+export type Outcome = 'completed' | 'in_progress' | 'abandoned' | 'interrupted' | 'errored' | 'hit_limits';
+
 export interface Message {
   id: string;
   conversation_id: string;
@@ -95,6 +172,15 @@ export interface Trace {
   attributes?: Record<string, unknown>;
   start_time: string;
   end_time?: string;
+  status?: 'unset' | 'ok' | 'error';
+  status_message?: string;
+  events?: SpanEvent[];
   children?: Trace[];
   duration_ms?: number;
 }
+
+export interface SpanEvent {
+  name: string;
+  timestamp: string;
+  attributes?: Record<string, unknown>;
+}